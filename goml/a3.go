@@ -49,7 +49,6 @@ func overfitting(params map[string]float64) {
 	// - first column is training error
 	// - second columns is validation error
 	result := matrix.NewMatrix(maxrun, 2, nil)
-	var err float64
 	for i := 0; i < maxrun; i++ {
 		n.Train(train_features, train_labels, params)
 
@@ -61,10 +60,8 @@ func overfitting(params map[string]float64) {
 			pred := n.Predict(train_features.Row(e))
 			copy(train.Row(e), pred)
 		}
-		err = train.ToVector().Sub(train_labels.ToVector()).Norm(2)
-		result.SetElem(i, 0, err*err/float64(256))
-		err = predict.ToVector().Sub(test_labels.ToVector()).Norm(2)
-		result.SetElem(i, 1, err*err/(float64(101)))
+		result.SetElem(i, 0, matrix.MSE(train, train_labels))
+		result.SetElem(i, 1, matrix.MSE(predict, test_labels))
 	}
 	result.SaveARFF("/tmp/overfit.dat", false)
 }
@@ -112,7 +109,6 @@ func batch_momentum(params map[string]float64) {
 	// - fifth column is validation error with batch
 	// - sixth column is time
 	result := matrix.NewMatrix(maxrun, 6, nil)
-	var err float64
 	start := time.Now()
 
 	// batch
@@ -128,10 +124,8 @@ func batch_momentum(params map[string]float64) {
 		pred := n.Predict(train_features.Row(e))
 		copy(train.Row(e), pred)
 	}
-	err = train.ToVector().Sub(train_labels.ToVector()).Norm(2)
-	result.SetElem(0, 0, err/(float64(16)))
-	err = predict.ToVector().Sub(test_labels.ToVector()).Norm(2)
-	result.SetElem(0, 1, err/math.Sqrt(float64(101)))
+	result.SetElem(0, 0, matrix.RMSE(train, train_labels))
+	result.SetElem(0, 1, matrix.RMSE(predict, test_labels))
 
 	for i := 1; i < maxrun; i++ {
 		start = time.Now()
@@ -145,10 +139,8 @@ func batch_momentum(params map[string]float64) {
 			pred := n.Predict(train_features.Row(e))
 			copy(train.Row(e), pred)
 		}
-		err = train.ToVector().Sub(train_labels.ToVector()).Norm(2)
-		result.SetElem(i, 0, err/(float64(16)))
-		err = predict.ToVector().Sub(test_labels.ToVector()).Norm(2)
-		result.SetElem(i, 1, err/math.Sqrt(float64(101)))
+		result.SetElem(i, 0, matrix.RMSE(train, train_labels))
+		result.SetElem(i, 1, matrix.RMSE(predict, test_labels))
 	}
 
 	// momentum
@@ -166,10 +158,8 @@ func batch_momentum(params map[string]float64) {
 		pred := n.Predict(train_features.Row(e))
 		copy(train.Row(e), pred)
 	}
-	err = train.ToVector().Sub(train_labels.ToVector()).Norm(2)
-	result.SetElem(0, 3, err/(float64(16)))
-	err = predict.ToVector().Sub(test_labels.ToVector()).Norm(2)
-	result.SetElem(0, 4, err/math.Sqrt(float64(101)))
+	result.SetElem(0, 3, matrix.RMSE(train, train_labels))
+	result.SetElem(0, 4, matrix.RMSE(predict, test_labels))
 
 	for i := 1; i < maxrun; i++ {
 		start = time.Now()
@@ -183,10 +173,8 @@ func batch_momentum(params map[string]float64) {
 			pred := n.Predict(train_features.Row(e))
 			copy(train.Row(e), pred)
 		}
-		err = train.ToVector().Sub(train_labels.ToVector()).Norm(2)
-		result.SetElem(i, 3, err/(float64(16)))
-		err = predict.ToVector().Sub(test_labels.ToVector()).Norm(2)
-		result.SetElem(i, 4, err/math.Sqrt(float64(101)))
+		result.SetElem(i, 3, matrix.RMSE(train, train_labels))
+		result.SetElem(i, 4, matrix.RMSE(predict, test_labels))
 	}
 	fmt.Printf("error is %v\n", result)
 	result.SaveARFF("/tmp/batch_momentum.dat", false)
@@ -0,0 +1,112 @@
+package main
+
+// benchmul compares the tiled, parallel matrix.Mul with the naive
+// triple loop it replaced, for the sizes of the MNIST MLPs: a batch of
+// images (784 features) through hidden layers of 80 and 30 units.
+//
+//	go run benchmul.go
+
+import (
+	"./matrix"
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// rows returns the rows of m so that naiveMul indexes them directly,
+// as matrix.Mul did.
+func rows(m *matrix.Matrix) []matrix.Vector {
+	r := make([]matrix.Vector, m.Rows())
+	for i := range r {
+		r[i] = m.Row(i)
+	}
+	return r
+}
+
+// naiveMul is the implementation of matrix.Mul before tiling.
+func naiveMul(m, a, b *matrix.Matrix, aTranspose, bTranspose bool) {
+	mr, ar, br := rows(m), rows(a), rows(b)
+	for i := range mr {
+		for j := range mr[i] {
+			mr[i][j] = 0.0
+			if aTranspose {
+				for k := range ar {
+					if bTranspose {
+						mr[i][j] += ar[k][i] * br[j][k]
+					} else {
+						mr[i][j] += ar[k][i] * br[k][j]
+					}
+				}
+			} else {
+				for k := range ar[i] {
+					if bTranspose {
+						mr[i][j] += ar[i][k] * br[j][k]
+					} else {
+						mr[i][j] += ar[i][k] * br[k][j]
+					}
+				}
+			}
+		}
+	}
+}
+
+func main() {
+	cases := []struct {
+		name    string
+		m, k, n int
+		aT, bT  bool
+	}{
+		// forward pass: X (batch x 784) * W^T (80 x 784)
+		{"forward 256x784 * (80x784)^T", 256, 784, 80, false, true},
+		// backward pass: delta (batch x 80) * W (80 x 784)
+		{"backward 256x80 * 80x784", 256, 80, 784, false, false},
+		// gradient: delta^T (80 x batch) * X (batch x 784)
+		{"gradient (256x80)^T * 256x784", 80, 256, 784, true, false},
+		{"square 512x512 * 512x512", 512, 512, 512, false, false},
+	}
+	fmt.Printf("GOMAXPROCS = %d\n", runtime.GOMAXPROCS(0))
+	for _, c := range cases {
+		aR, aC := c.m, c.k
+		if c.aT {
+			aR, aC = c.k, c.m
+		}
+		bR, bC := c.k, c.n
+		if c.bT {
+			bR, bC = c.n, c.k
+		}
+		a := matrix.NewMatrix(aR, aC, nil).Random(1)
+		b := matrix.NewMatrix(bR, bC, nil).Random(2)
+		out := matrix.NewMatrix(c.m, c.n, nil)
+
+		naive := testing.Benchmark(func(bb *testing.B) {
+			for i := 0; i < bb.N; i++ {
+				naiveMul(out, a, b, c.aT, c.bT)
+			}
+		})
+		tiled := testing.Benchmark(func(bb *testing.B) {
+			for i := 0; i < bb.N; i++ {
+				out.Mul(a, b, c.aT, c.bT)
+			}
+		})
+		check := matrix.NewMatrix(c.m, c.n, nil)
+		naiveMul(check, a, b, c.aT, c.bT)
+		if !check.Equal(out.Mul(a, b, c.aT, c.bT), 0) {
+			panic("benchmul: tiled and naive products differ")
+		}
+		fmt.Printf("%-32s naive %10.3f ms  tiled %10.3f ms  speedup %5.1fx\n",
+			c.name, float64(naive.NsPerOp())/1e6, float64(tiled.NsPerOp())/1e6,
+			float64(naive.NsPerOp())/float64(tiled.NsPerOp()))
+	}
+
+	// Axpb on a single image
+	a := matrix.NewMatrix(800, 784, nil).Random(3)
+	x := matrix.NewVector(784, nil)
+	x.Random(4)
+	y := matrix.NewVector(800, nil)
+	r := testing.Benchmark(func(bb *testing.B) {
+		for i := 0; i < bb.N; i++ {
+			matrix.Axpb(a, x, y)
+		}
+	})
+	fmt.Printf("%-32s %10.3f ms\n", "Axpb 800x784", float64(r.NsPerOp())/1e6)
+}
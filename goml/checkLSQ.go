@@ -0,0 +1,124 @@
+package main
+
+import (
+	"./matrix"
+	"./rand"
+	"fmt"
+	"math"
+)
+
+// normalEquations solves (Z^T*W*Z + D) b = Z^T*W*y, where Z is x with
+// a column of ones appended if intercept is set, W holds the weights
+// (nil for 1) and D is ridge on the diagonal except for the
+// intercept.
+func normalEquations(x, y *matrix.Matrix, w matrix.Vector, ridge float64,
+	intercept bool) matrix.Vector {
+	rows, cols := x.Size()
+	n := cols
+	if intercept {
+		n++
+	}
+	z := matrix.NewMatrix(rows, n, nil)
+	wy := matrix.NewMatrix(rows, 1, nil)
+	for i := 0; i < rows; i++ {
+		s := 1.0
+		if w != nil {
+			s = w[i]
+		}
+		for j := 0; j < cols; j++ {
+			z.SetElem(i, j, x.GetElem(i, j))
+		}
+		if intercept {
+			z.SetElem(i, cols, 1)
+		}
+		wy.SetElem(i, 0, s*y.GetElem(i, 0))
+	}
+	wz := matrix.NewMatrix(rows, n, nil)
+	for i := 0; i < rows; i++ {
+		s := 1.0
+		if w != nil {
+			s = w[i]
+		}
+		for j := 0; j < n; j++ {
+			wz.SetElem(i, j, s*z.GetElem(i, j))
+		}
+	}
+	a := matrix.Mul(z, wz, true, false)
+	for j := 0; j < cols; j++ {
+		a.SetElem(j, j, a.GetElem(j, j)+ridge)
+	}
+	f, err := matrix.NewCholesky(a)
+	if err != nil {
+		panic(err)
+	}
+	b, err := f.Solve(matrix.Mul(z, wy, true, false))
+	if err != nil {
+		panic(err)
+	}
+	return b.ToVector()
+}
+
+func main() {
+	r := rand.NewStream(1)
+	x := matrix.NewMatrix(20, 3, nil)
+	y := matrix.NewMatrix(20, 1, nil)
+	w := matrix.NewVector(20, nil)
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 3; j++ {
+			x.SetElem(i, j, r.Normal())
+		}
+		y.SetElem(i, 0, 2*x.GetElem(i, 0)-3*x.GetElem(i, 1)+
+			0.5*x.GetElem(i, 2)+1+0.1*r.Normal())
+		w[i] = 0.5 + r.Uniform()
+	}
+	// y2 has positive coefficients, so that NNLS is unconstrained
+	y2 := matrix.NewMatrix(20, 1, nil)
+	for i := 0; i < 20; i++ {
+		y2.SetElem(i, 0, x.GetElem(i, 0)+2*x.GetElem(i, 1)+1.5*x.GetElem(i, 2)+0.1*r.Normal())
+	}
+	cases := []struct {
+		name string
+		y    *matrix.Matrix
+		opts matrix.LSQOptions
+	}{
+		{"plain", y, matrix.LSQOptions{}},
+		{"intercept", y, matrix.LSQOptions{Intercept: true}},
+		{"ridge", y, matrix.LSQOptions{Ridge: 5}},
+		{"ridge+intercept", y, matrix.LSQOptions{Ridge: 5, Intercept: true}},
+		{"weighted", y, matrix.LSQOptions{Weights: w, Intercept: true}},
+		{"weighted ridge", y, matrix.LSQOptions{Weights: w, Ridge: 5, Intercept: true}},
+		{"nnls ridge", y2, matrix.LSQOptions{NonNegative: true, Ridge: 5}},
+	}
+	for _, c := range cases {
+		opts := c.opts
+		res, err := x.LeastSquareWith(c.y, &opts)
+		if err != nil {
+			panic(err)
+		}
+		want := normalEquations(x, c.y, c.opts.Weights, c.opts.Ridge, c.opts.Intercept)
+		got := res.Coef.ToVector()
+		diff := 0.0
+		for j := range want {
+			diff = math.Max(diff, math.Abs(got[j]-want[j]))
+		}
+		fmt.Printf("%-16s |b - b*| = %.1e, R2 = %.4f\n", c.name, diff, res.R2[0])
+		if diff > 1e-8 {
+			panic("coefficients differ from the normal equations")
+		}
+	}
+
+	// OLS and Intercept both put the intercept (1 here) last
+	res, err := x.LeastSquareWith(y, &matrix.LSQOptions{Intercept: true})
+	if err != nil {
+		panic(err)
+	}
+	got, ols := res.Coef.ToVector(), matrix.OLS(x, y)
+	diff := 0.0
+	for j := range ols {
+		diff = math.Max(diff, math.Abs(got[j]-ols[j]))
+	}
+	fmt.Printf("%-16s |b - b*| = %.1e, b = %.2f\n", "OLS", diff, ols[3])
+	if diff > 1e-8 || math.Abs(ols[3]-1) > 0.2 {
+		panic("OLS and the Intercept option disagree")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"./matrix"
+	"./rand"
+	"fmt"
+	"math"
+)
+
+// normalEquations solves (A^T*A + damp^2*I) x = A^T*b.
+func normalEquations(a *matrix.Matrix, b matrix.Vector, damp float64) matrix.Vector {
+	rows, cols := a.Size()
+	ata := matrix.Mul(a, a, true, false)
+	for j := 0; j < cols; j++ {
+		ata.SetElem(j, j, ata.GetElem(j, j)+damp*damp)
+	}
+	atb := matrix.Mul(a, matrix.NewMatrix(rows, 1, b), true, false)
+	f, err := matrix.NewCholesky(ata)
+	if err != nil {
+		panic(err)
+	}
+	x, err := f.Solve(atb)
+	if err != nil {
+		panic(err)
+	}
+	return x.ToVector()
+}
+
+// dampedResidual returns sqrt(|b - A*x|^2 + damp^2*|x|^2).
+func dampedResidual(a *matrix.Matrix, b, x matrix.Vector, damp float64) float64 {
+	r := matrix.Axmb(a, x, b).Norm(2)
+	return math.Hypot(r, damp*x.Norm(2))
+}
+
+func main() {
+	r := rand.NewStream(2021)
+	a := matrix.NewMatrix(30, 6, nil)
+	b := matrix.NewVector(30, nil)
+	for i := 0; i < 30; i++ {
+		for j := 0; j < 6; j++ {
+			a.SetElem(i, j, r.Normal())
+		}
+		b[i] = r.Normal()
+	}
+	solvers := []struct {
+		name  string
+		solve func(matrix.LinearOperator, matrix.Vector, *matrix.IterOptions) (*matrix.IterResult, error)
+	}{{"LSQR", matrix.LSQR}, {"LSMR", matrix.LSMR}}
+	for _, damp := range []float64{0, 0.7} {
+		want := normalEquations(a, b, damp)
+		for _, s := range solvers {
+			res, err := s.solve(a, b, &matrix.IterOptions{Damp: damp, Tol: 1e-12})
+			if err != nil {
+				panic(err)
+			}
+			diff := 0.0
+			for j := range want {
+				diff = math.Max(diff, math.Abs(res.X[j]-want[j]))
+			}
+			fmt.Printf("%s damp = %.1f: |x - x*| = %.1e, residual %.6f (true %.6f)\n",
+				s.name, damp, diff, res.Residual, dampedResidual(a, b, res.X, damp))
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"./matrix"
+	"./rand"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// reload writes m in ARFF format and reads it back.
+func reload(m *matrix.Matrix) *matrix.Matrix {
+	var buf bytes.Buffer
+	if err := m.WriteARFF(&buf); err != nil {
+		panic(err)
+	}
+	var r matrix.Matrix
+	if err := r.ReadARFF(&buf); err != nil {
+		panic(err)
+	}
+	return &r
+}
+
+// check reports whether the weight of each row of m is ten times its
+// first value, as in data, after a round trip through ARFF.
+func check(name string, m *matrix.Matrix) {
+	r := reload(m)
+	ok := r.Rows() == m.Rows()
+	for i := 0; ok && i < r.Rows(); i++ {
+		w := 1.0
+		if r.Weights() != nil {
+			w = r.Weights()[i]
+		}
+		want := 10 * r.GetElem(i, 0)
+		if r.GetElem(i, 0) == 0 {
+			want = 1
+		}
+		ok = w == want
+	}
+	fmt.Printf("%-16s ok = %v, weights %v\n", name, ok, []float64(r.Weights()))
+}
+
+const data = `@relation weighted
+@attribute x real
+@attribute y real
+@data
+1,2,{10}
+2,4,{20}
+3,6,{30}
+4,8,{40}
+`
+
+func load() *matrix.Matrix {
+	var m matrix.Matrix
+	if err := m.ReadARFF(strings.NewReader(data)); err != nil {
+		panic(err)
+	}
+	return &m
+}
+
+func main() {
+	check("loaded", load())
+	check("SwapRows", load().SwapRows(0, 3))
+	m := load()
+	m.PermuteRows([]int{2, 0, 3, 1})
+	check("PermuteRows", m)
+	// added rows are zero with weight 1
+	check("AddRows", load().AddRows(2))
+	check("SwapRows+AddRows", load().SwapRows(0, 2).AddRows(1))
+	m = load()
+	m.PermuteRows([]int{3, 2, 0, 1})
+	check("Permute+AddRows", m.AddRows(1))
+	m = load()
+	m.ShuffleWith(rand.NewStream(3))
+	check("Shuffle+AddRows", m.AddRows(1))
+	check("SortBy+AddRows", load().SortBy(0, true).AddRows(1))
+	check("SwapRows+AddCols", load().SwapRows(0, 2).AddCols(1))
+	m = new(matrix.Matrix)
+	m.SubMatrix(load(), []int{3, 1, 1}, []int{0, 1})
+	check("SubMatrix", m)
+	// rows past the copied ones are zero with weight 1
+	m = matrix.NewMatrix(4, 2, nil)
+	m.CopyRows(load(), []int{2}, []int{4})
+	check("CopyRows", m)
+	m = load()
+	m.Shuffle()
+	check("Shuffle", m)
+	m = load()
+	m.ShuffleWith(rand.NewStream(1))
+	check("ShuffleWith", m)
+}
@@ -48,8 +48,9 @@ func (b *Binarization) transform(inVec *matrix.Vector, outVec *matrix.Vector) {
 					"Binarization: Transform: Value out of range. Expected [0-",
 					b.vals[i]-1, "], got ", int(in[i]))
 				out[id+int(in[i])] = 1.0
-				id += b.vals[i]
 			}
+			// a missing value leaves its block at zero
+			id += b.vals[i]
 		}
 	}
 }
@@ -102,3 +103,40 @@ func (b *Binarization) TransformBatch(input, output *matrix.Matrix) {
 		b.transform(&inVec, &outVec)
 	}
 }
+
+// TransformSparse is TransformBatch with a sparse output, which saves
+// memory when there are many nominal values. The output is in CSR
+// format so that its rows can be fed to neuralNet.ActivateSparse.
+func (b *Binarization) TransformSparse(input *matrix.Matrix) *matrix.SparseMatrix {
+	matrix.Require(input.Cols() == len(b.vals), "%s %d %s %d\n",
+		"Binarization: TransformSparse: Expected input matrix of size *x",
+		len(b.vals), ", got *x", input.Cols())
+	var rows, cols []int
+	var vals []float64
+	for r := 0; r < input.Rows(); r++ {
+		in := input.Row(r)
+		id := 0
+		for i := 0; i < len(in); i++ {
+			if b.vals[i] == 1 {
+				if in[i] != 0 {
+					rows = append(rows, r)
+					cols = append(cols, id)
+					vals = append(vals, in[i])
+				}
+				id++
+			} else {
+				if in[i] != matrix.UNKNOWN_VALUE {
+					matrix.Require(int(in[i]) < b.vals[i], "%s%d%s %d\n",
+						"Binarization: TransformSparse: Value out of range. Expected [0-",
+						b.vals[i]-1, "], got ", int(in[i]))
+					rows = append(rows, r)
+					cols = append(cols, id+int(in[i]))
+					vals = append(vals, 1.0)
+				}
+				id += b.vals[i]
+			}
+		}
+	}
+	return matrix.NewSparseMatrix(input.Rows(), b.totalVals, rows, cols, vals,
+		matrix.CSR)
+}
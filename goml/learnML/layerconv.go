@@ -9,8 +9,13 @@ type layerConv struct {
 	in     Dims
 	filter Dims
 	out    Dims
+	opts   matrix.ConvOptions
 }
 
+// dim is the input dims and dims = [filter, out, stride, dilation],
+// where the last dimension of filter and out is the number of
+// channels. stride and dilation are optional and have one value per
+// input dimension (or a single value for all).
 func (l *layerConv) init(dim Dims, dims ...Dims) {
 	inDim := len(dim)
 	filterDim := len(dims[0])
@@ -28,6 +33,12 @@ func (l *layerConv) init(dim Dims, dims ...Dims) {
 	copy(l.in, dim)
 	copy(l.filter, dims[0])
 	copy(l.out, dims[1])
+	if len(dims) > 2 {
+		l.opts.Stride = append([]int{}, dims[2]...)
+	}
+	if len(dims) > 3 {
+		l.opts.Dilation = append([]int{}, dims[3]...)
+	}
 
 	// Outputs are stored in activation as usual.
 	size := 1
@@ -47,54 +58,25 @@ func (l *layerConv) init(dim Dims, dims ...Dims) {
 
 func (l *layerConv) Activate(x *matrix.Vector) *matrix.Vector {
 	in := matrix.NewTensor(*x, l.in)
-	dc := len(l.in)
-
-	sizeFilter := 1
-	for i := 0; i < dc; i++ {
-		sizeFilter *= l.filter[i]
-	}
-
-	sizeOut := 1
-	for i := 0; i < dc; i++ {
-		sizeOut *= l.out[i]
-	}
+	out := matrix.NewTensor(l.layer.activation, l.out)
+	filter := matrix.NewTensor(l.layer.weight, l.filter)
 
 	// reset l.layer.activation
 	l.layer.activation.Fill(0.0)
 
-	// Do convolution
-	var out, filter *matrix.Tensor
-	for i := 0; i < l.out[dc]; i++ {
-		out = matrix.NewTensor(l.layer.activation[i*sizeOut:(i+1)*sizeOut], l.out[:dc])
-		filter = matrix.NewTensor(l.layer.weight[i*sizeFilter:(i+1)*sizeFilter], l.filter[:dc])
-		matrix.Convolve(in, filter, out, false, 1)
-	}
+	// Do convolution of all output channels
+	matrix.ConvolveWith(in, filter, out, l.opts)
 	return &(l.layer.activation)
 }
 
-// BackProp compute Convolve(blame, weight, prevBlame)
+// BackProp computes prevBlame as the transposed convolution of blame
+// by the weight.
 func (l *layerConv) BackProp(prevBlame *matrix.Vector) {
-	var in, out, filter *matrix.Tensor
-	dc := len(l.in)
-	out = matrix.NewTensor(*prevBlame, l.in)
+	out := matrix.NewTensor(*prevBlame, l.in)
+	blame := matrix.NewTensor(l.layer.blame, l.out)
+	filter := matrix.NewTensor(l.layer.weight, l.filter)
 	(*prevBlame).Fill(0.0)
-
-	sizeFilter := 1
-	for i := 0; i < dc; i++ {
-		sizeFilter *= l.filter[i]
-	}
-
-	sizeIn := 1
-	for i := 0; i < dc; i++ {
-		sizeIn *= l.out[i]
-	}
-
-	// Do (backward) convolution
-	for i := 0; i < l.out[dc]; i++ {
-		in = matrix.NewTensor(l.layer.blame[i*sizeIn:(i+1)*sizeIn], l.out[:dc])
-		filter = matrix.NewTensor(l.layer.weight[i*sizeFilter:(i+1)*sizeFilter], l.filter[:dc])
-		matrix.Convolve(in, filter, out, true, 1)
-	}
+	matrix.ConvTranspose(blame, filter, out, l.opts)
 }
 
 // gradient = in*blame
@@ -102,26 +84,10 @@ func (l *layerConv) BackProp(prevBlame *matrix.Vector) {
 // is fo the same size as activation. Just as activation = in*weight,
 // we do the same thing here (weight) = in*(activation).
 func (l *layerConv) UpdateGradient(in *matrix.Vector, gradient *matrix.Vector) {
-	var blame, prevActivation, gt *matrix.Tensor
-	dc := len(l.in)
-	prevActivation = matrix.NewTensor(*in, l.in)
-
-	sizeGrad := 1
-	for i := 0; i < dc; i++ {
-		sizeGrad *= l.filter[i]
-	}
-
-	sizeBlame := 1
-	for i := 0; i < dc; i++ {
-		sizeBlame *= l.out[i]
-	}
-
-	// Do (backward) convolution
-	for i := 0; i < l.out[dc]; i++ {
-		blame = matrix.NewTensor(l.layer.blame[i*sizeBlame:(i+1)*sizeBlame], l.out[:dc])
-		gt = matrix.NewTensor((*gradient)[i*sizeGrad:(i+1)*sizeGrad], l.filter[:dc])
-		matrix.Convolve(prevActivation, blame, gt, false, 1)
-	}
+	prevActivation := matrix.NewTensor(*in, l.in)
+	blame := matrix.NewTensor(l.layer.blame, l.out)
+	grad := matrix.NewTensor(*gradient, l.filter)
+	matrix.ConvFilterGradient(prevActivation, blame, grad, l.opts)
 }
 
 func (l *layerConv) Name() string {
@@ -125,6 +125,64 @@ func (l *layerLinear) UpdateGradient(in *matrix.Vector, gradient *matrix.Vector)
 	//floats.Add(temp, l.layer.blame)
 }
 
+// ActivateSparse is Activate for a sparse input: only the rows of the
+// weight matrix that match the nonzero inputs are read.
+func (l *layerLinear) ActivateSparse(x *matrix.SparseVector) *matrix.Vector {
+	rows := x.Len
+	cols := len(l.layer.weight) / (rows + 1)
+	copy(l.layer.activation, l.layer.weight[rows*cols:])
+	for k, j := range x.Index {
+		xj := x.Value[k]
+		w := l.layer.weight[j*cols : (j+1)*cols]
+		for i := 0; i < cols; i++ {
+			l.layer.activation[i] += xj * w[i]
+		}
+	}
+	return &(l.layer.activation)
+}
+
+// UpdateGradientSparse is UpdateGradient for a sparse input. Without
+// regularization only the rows of the gradient that match the
+// nonzero inputs are updated.
+func (l *layerLinear) UpdateGradientSparse(in *matrix.SparseVector, gradient *matrix.Vector) {
+	cols := len(l.layer.blame)
+	rows := len(*gradient) / cols
+	bb := l.layer.blame
+
+	if l.l1 != 0 || l.l2 != 0 {
+		l1 := float64((rows - 1) * cols)
+		l2 := l.l2 / l1
+		l1 = l.l1 / l1
+		for i := 0; i < rows-1; i++ {
+			temp := (*gradient)[i*cols : (i+1)*cols]
+			w := l.layer.weight[i*cols : (i+1)*cols]
+			for j := 0; j < cols; j++ {
+				temp[j] += l2 * w[j]
+				if w[j] < 0 {
+					temp[j] -= l1
+				} else {
+					temp[j] += l1
+				}
+			}
+		}
+	}
+
+	// compute M += blame.OuterProd(x)
+	for k, i := range in.Index {
+		xi := in.Value[k]
+		temp := (*gradient)[i*cols : (i+1)*cols]
+		for j := 0; j < cols; j++ {
+			temp[j] += xi * bb[j]
+		}
+	}
+
+	// compute b += blame
+	temp := (*gradient)[(rows-1)*cols:]
+	for i := 0; i < cols; i++ {
+		temp[i] += bb[i]
+	}
+}
+
 func (l *layerLinear) Name() string {
 	return "Layer Linear"
 }
@@ -30,45 +30,35 @@ func (l *layerMaxPooling2D) init(dim Dims, dims ...Dims) {
 	l.maxid = make([]int, size)
 }
 
-func (l *layerMaxPooling2D) Activate(x *matrix.Vector) *matrix.Vector {
-	N := 1
-	for i := 2; i < len(l.out); i++ {
-		N *= l.out[i]
-	}
-
-	size := l.out[0] * l.out[1]
-
-	// Do 2D max pooling
-	var inID, outID int
-	for i := 0; i < N; i++ {
-		// loop through the 2D slice of the output
-		maxid := l.maxid[i*size : (i+1)*size]
-		out := l.layer.activation[i*size : (i+1)*size]
-		in := (*x)[i*4*size : (i+1)*4*size]
-		for r := 0; r < l.out[0]; r++ {
-			for c := 0; c < l.out[1]; c++ {
-				outID = r*l.out[0] + c
-				inID = (r<<2)*l.out[0] + (c << 1)
-				maxid[outID] = inID
-				out[outID] = in[inID]
-
-				inID++
-				if out[outID] < in[inID] {
-					maxid[outID] = inID
-					out[outID] = in[inID]
-				}
-
-				inID = ((r<<2)|2)*l.out[0] + (c << 1)
-				if out[outID] < in[inID] {
-					maxid[outID] = inID
-					out[outID] = in[inID]
-				}
+// planes returns the input and the output of the layer as tensors of
+// dims {w, h, planes}, where the planes are all the remaining
+// dimensions (e.g. channels).
+func (l *layerMaxPooling2D) planes(in, out matrix.Vector) (*matrix.Tensor, *matrix.Tensor) {
+	o := matrix.NewTensor(out, l.out).Reshape(l.out[0], l.out[1], -1)
+	i := matrix.NewTensor(in, []int{len(in)}).Reshape(2*l.out[0], 2*l.out[1], -1)
+	return i, o
+}
 
-				inID++
-				if out[outID] < in[inID] {
-					maxid[outID] = inID
-					out[outID] = in[inID]
+func (l *layerMaxPooling2D) Activate(x *matrix.Vector) *matrix.Vector {
+	in, out := l.planes(*x, l.layer.activation)
+	data := in.Data()
+
+	// Do 2D max pooling over each plane, visiting the 2x2 window in
+	// the order (0, 0), (1, 0), (0, 1), (1, 1)
+	dims := out.Dims()
+	for k := 0; k < dims[2]; k++ {
+		for r := 0; r < dims[1]; r++ {
+			for c := 0; c < dims[0]; c++ {
+				best := in.Offset(2*c, 2*r, k)
+				for _, d := range [][2]int{{1, 0}, {0, 1}, {1, 1}} {
+					id := in.Offset(2*c+d[0], 2*r+d[1], k)
+					if data[best] < data[id] {
+						best = id
+					}
 				}
+				outID := out.Offset(c, r, k)
+				l.maxid[outID] = best
+				l.layer.activation[outID] = data[best]
 			}
 		}
 	}
@@ -77,27 +67,10 @@ func (l *layerMaxPooling2D) Activate(x *matrix.Vector) *matrix.Vector {
 }
 
 func (l *layerMaxPooling2D) BackProp(prevBlame *matrix.Vector) {
-	N := 1
-	for i := 2; i < len(l.out); i++ {
-		N *= l.out[i]
-	}
-
-	size := l.out[0] * l.out[1]
-
-	// Do 2D max pooling
-	var id int
+	// route the blame of every output to the input that was the max
 	(*prevBlame).Fill(0.0)
-	for i := 0; i < N; i++ {
-		// loop through the 2D slice of the output
-		in := l.layer.blame[i*size : (i+1)*size]
-		maxid := l.maxid[i*size : (i+1)*size]
-		out := (*prevBlame)[i*4*size : (i+1)*4*size]
-		for r := 0; r < l.out[0]; r++ {
-			for c := 0; c < l.out[1]; c++ {
-				id = r*l.out[0] + c
-				out[maxid[id]] = in[id]
-			}
-		}
+	for id, b := range l.layer.blame {
+		(*prevBlame)[l.maxid[id]] = b
 	}
 }
 
@@ -2,6 +2,7 @@ package learnML
 
 import (
 	"../matrix"
+	"../optimize"
 	"../rand"
 	//"fmt"
 	//"gonum.org/v1/gonum/floats"
@@ -88,21 +89,36 @@ func (n *neuralNet) InitWeight(w []matrix.Vector) {
 	r := rand.NewRand(2162018)
 	//r := rand.NewRand(uint64(time.Now().UnixNano()))
 	for i := 0; i < len(n.layers); i++ {
-		outputCount := len(*(n.layers[i].Activation()))
-		if outputCount == 0 {
-			continue
-		}
-		inputCount := len(*(n.layers[i].Weight()))/outputCount - 1
-		max := 1.0
-		if inputCount != 0 {
-			max /= float64(inputCount)
-		}
-		if max < 0.03 {
-			max = 0.03
-		}
-		for j := 0; j < len(*(n.layers[i].Weight())); j++ {
-			(*(n.layers[i].Weight()))[j] = max * r.Normal()
-		}
+		n.initLayerWeight(i, r)
+	}
+}
+
+// InitWeightWith sets random weights drawn from streams derived from
+// r: layer i uses r.Derive(i), so its weights only depend on the seed
+// of r and its own shape, and adding a layer leaves the others as
+// they were.
+func (n *neuralNet) InitWeightWith(r *rand.Rand) {
+	for i := 0; i < len(n.layers); i++ {
+		n.initLayerWeight(i, r.Derive(uint64(i)))
+	}
+}
+
+// initLayerWeight draws the weights of layer i from r.
+func (n *neuralNet) initLayerWeight(i int, r *rand.Rand) {
+	outputCount := len(*(n.layers[i].Activation()))
+	if outputCount == 0 {
+		return
+	}
+	inputCount := len(*(n.layers[i].Weight()))/outputCount - 1
+	max := 1.0
+	if inputCount != 0 {
+		max /= float64(inputCount)
+	}
+	if max < 0.03 {
+		max = 0.03
+	}
+	for j := 0; j < len(*(n.layers[i].Weight())); j++ {
+		(*(n.layers[i].Weight()))[j] = max * r.Normal()
 	}
 }
 
@@ -133,13 +149,117 @@ func (n *neuralNet) Train(features, labels *matrix.Matrix,
 		"neuralNet.Train: Expect %s but get %d = %d\n",
 		"features.Rows() == labels.Rows()", features.Rows(), labels.Rows())
 
+	gradient := n.CreateGradient()
+	epoch(labels.Rows(), params, func(batch []int, learningRate, momentum float64) {
+		ScaleGradient(gradient, momentum)
+		for _, p := range batch {
+			x := features.Row(p)
+			y := labels.Row(p)
+			n.Activate(&x)
+			n.BackProp(y, nil)
+			n.UpdateGradient(&x, gradient)
+		}
+		n.RefineWeight(gradient, learningRate/float64(len(batch)))
+	})
+}
+
+// weightCount returns the total number of weights of the network.
+func (n *neuralNet) weightCount() int {
+	size := 0
+	for _, l := range n.layers {
+		size += len(*(l.Weight()))
+	}
+	return size
+}
+
+// Weights returns a copy of all the weights of the network, layer after
+// layer, as one vector. It is the point optimized by TrainFullBatch.
+func (n *neuralNet) Weights() matrix.Vector {
+	w := make(matrix.Vector, 0, n.weightCount())
+	for _, l := range n.layers {
+		w = append(w, *(l.Weight())...)
+	}
+	return w
+}
+
+// SetWeights sets all the weights of the network from w, in the order
+// of Weights.
+func (n *neuralNet) SetWeights(w matrix.Vector) {
+	matrix.Require(len(w) == n.weightCount(),
+		"neuralNet.SetWeights: expect %d weights but get %d\n",
+		n.weightCount(), len(w))
+	for _, l := range n.layers {
+		w = w[copy(*(l.Weight()), w):]
+	}
+}
+
+// Objective returns the mean squared error of the network over the
+// rows of features and labels as a function of its weights (see
+// Weights), with its gradient computed by backpropagation. Evaluating
+// it sets the weights of the network.
+func (n *neuralNet) Objective(features, labels *matrix.Matrix) optimize.Function {
+	matrix.Require(features.Rows() == labels.Rows(),
+		"neuralNet.Objective: Expect %s but get %d = %d\n",
+		"features.Rows() == labels.Rows()", features.Rows(), labels.Rows())
+	gradient := n.CreateGradient()
+	return optimize.Func(func(w, grad matrix.Vector) float64 {
+		n.SetWeights(w)
+		ScaleGradient(gradient, 0)
+		sse := 0.0
+		for p := 0; p < labels.Rows(); p++ {
+			x := features.Row(p)
+			y := labels.Row(p)
+			out := *(n.Activate(&x))
+			for j, v := range y {
+				sse += (v - out[j]) * (v - out[j])
+			}
+			if grad != nil {
+				n.BackProp(y, nil)
+				n.UpdateGradient(&x, gradient)
+			}
+		}
+		rows := float64(labels.Rows())
+		if grad != nil {
+			// BackProp omits the factor 2 and gives the descent
+			// direction
+			k := 0
+			for _, g := range *gradient {
+				for _, v := range g {
+					grad[k] = -2 * v / rows
+					k++
+				}
+			}
+		}
+		return sse / rows
+	})
+}
+
+// TrainFullBatch minimizes the mean squared error over all the rows of
+// features and labels with an optimize method, e.g. optimize.LBFGS,
+// starting from the current weights, and leaves the best weights found
+// in the network. Unlike Train, it runs until a stopping criterion of
+// s is met, which is reported in the result.
+func (n *neuralNet) TrainFullBatch(features, labels *matrix.Matrix,
+	method optimize.Method, s *optimize.Settings) (*optimize.Result, error) {
+	res, err := method(n.Objective(features, labels), n.Weights(), s)
+	if res != nil {
+		n.SetWeights(res.X)
+	}
+	return res, err
+}
+
+// epoch runs one epoch of minibatch training over rows examples: it
+// shuffles the examples and calls step with the indices of the
+// examples in each batch, the learning rate and the momentum, as set
+// by params (see neuralNet.Train). The batches differ in size by at
+// most one, with the larger ones first.
+func epoch(rows int, params map[string]float64,
+	step func(batch []int, learningRate, momentum float64)) {
 	seed := uint64(params["seed"])
 	learningRate := 0.03
 	batchSize := 1
 	momentum := 0.0
 
-	rows := labels.Rows()
-
 	if params["learningRate"] > 0.0 {
 		learningRate = params["learningRate"]
 	}
@@ -165,18 +285,9 @@ func (n *neuralNet) Train(features, labels *matrix.Matrix,
 		numBatch = rows / batchSize
 		largeBatch = rows % batchSize
 	}
-	gradient := n.CreateGradient()
 
-	P := make([]int, features.Rows())
-	for i := 0; i < len(P); i++ {
-		P[i] = i
-	}
 	// shuffle data
-	random := rand.NewRand(seed)
-	for r := rows; r > 1; r-- {
-		l := int(random.Next(uint64(r)))
-		P[r-1], P[l] = P[l], P[r-1]
-	}
+	P := rand.NewRand(seed).Perm(rows)
 
 	// now loop through batches
 	var start, end int
@@ -184,30 +295,13 @@ func (n *neuralNet) Train(features, labels *matrix.Matrix,
 	start = 0
 	for b := 0; b < largeBatch; b++ {
 		end = start + batchSize
-		ScaleGradient(gradient, momentum)
-		for s := start; s < end; s++ {
-			x := features.Row(P[s])
-			y := labels.Row(P[s])
-			n.Activate(&x)
-			n.BackProp(y, nil)
-			n.UpdateGradient(&x, gradient)
-		}
-		n.RefineWeight(gradient, learningRate/float64(batchSize))
-		//n.RefineWeight(gradient, learningRate)
+		step(P[start:end], learningRate, momentum)
 		start = end
 	}
 	batchSize--
 	for b := largeBatch; b < numBatch; b++ {
 		end = start + batchSize
-		ScaleGradient(gradient, momentum)
-		for s := start; s < end; s++ {
-			x := features.Row(P[s])
-			y := labels.Row(P[s])
-			n.Activate(&x)
-			n.BackProp(y, nil)
-			n.UpdateGradient(&x, gradient)
-		}
-		n.RefineWeight(gradient, learningRate/float64(batchSize))
+		step(P[start:end], learningRate, momentum)
 		start = end
 	}
 }
@@ -228,6 +322,26 @@ func (n *neuralNet) Activate(in *matrix.Vector) *matrix.Vector {
 	return activation
 }
 
+// sparseLayer is implemented by the layers that can take a sparse
+// input, i.e., layerLinear.
+type sparseLayer interface {
+	ActivateSparse(x *matrix.SparseVector) *matrix.Vector
+	UpdateGradientSparse(in *matrix.SparseVector, gradient *matrix.Vector)
+}
+
+// ActivateSparse is Activate for a sparse input, e.g. a row of a
+// matrix.SparseMatrix. The first layer must be a LayerLinear.
+func (n *neuralNet) ActivateSparse(in *matrix.SparseVector) *matrix.Vector {
+	l, ok := n.layers[0].(sparseLayer)
+	matrix.Require(ok, "neuralNet.ActivateSparse: %s cannot take a sparse input\n",
+		n.layers[0].Name())
+	activation := l.ActivateSparse(in)
+	for i := 1; i < len(n.layers); i++ {
+		activation = n.layers[i].Activate(activation)
+	}
+	return activation
+}
+
 // Predict will call neuralNet.Activate to compute predictions.
 func (n *neuralNet) Predict(in matrix.Vector) matrix.Vector {
 	return *(n.Activate(&in))
@@ -278,6 +392,20 @@ func (n *neuralNet) UpdateGradient(x *matrix.Vector,
 	}
 }
 
+// UpdateGradientSparse is UpdateGradient for a sparse input. The
+// network must have been activated with ActivateSparse and
+// backpropagated.
+func (n *neuralNet) UpdateGradientSparse(x *matrix.SparseVector,
+	g *[]matrix.Vector) {
+	gradient := *g
+	n.layers[0].(sparseLayer).UpdateGradientSparse(x, &(gradient[0]))
+	for i := 1; i < len(gradient); i++ {
+		n.layers[i].UpdateGradient(
+			n.layers[i-1].Activation(),
+			&(gradient[i]))
+	}
+}
+
 func (n *neuralNet) FilterData(featIn *matrix.Matrix, labIn *matrix.Matrix, featOut *matrix.Matrix, labOut *matrix.Matrix) {
 	panic("not implemented")
 }
@@ -0,0 +1,435 @@
+package learnML
+
+import (
+	"../matrix"
+	"../rand"
+	"math"
+)
+
+// layer32 is a layer of a neuralNet32. Activations, blames and weights
+// are stored in single precision. Gradients are float64 so that the
+// sums over a batch do not lose precision, and so are the
+// accumulators of the dot products inside a layer.
+type layer32 interface {
+	Activate(x *matrix.Vector32) *matrix.Vector32
+	BackProp(prevBlame *matrix.Vector32)
+	UpdateGradient(in *matrix.Vector32, gradient *matrix.Vector)
+	Name() string
+	Activation() *matrix.Vector32
+	Blame() *matrix.Vector32
+	Weight() *matrix.Vector32
+}
+
+// base32 holds the vectors of a layer32.
+type base32 struct {
+	activation matrix.Vector32
+	blame      matrix.Vector32
+	weight     matrix.Vector32
+}
+
+func (l *base32) UpdateGradient(in *matrix.Vector32, gradient *matrix.Vector) {}
+
+func (l *base32) Activation() *matrix.Vector32 {
+	return &(l.activation)
+}
+
+func (l *base32) Blame() *matrix.Vector32 {
+	return &(l.blame)
+}
+
+func (l *base32) Weight() *matrix.Vector32 {
+	return &(l.weight)
+}
+
+// newLayer32 creates the single precision version of a layer of type
+// t, with the same dims as NewLayer.
+func newLayer32(t LayerType, dim Dims, dims ...Dims) layer32 {
+	switch t {
+	case LayerLinear:
+		matrix.Require(len(dim) == 2, "layerLinear32: init: require dim = 2")
+		l := &layerLinear32{}
+		l.activation = make(matrix.Vector32, dim[1])
+		l.blame = make(matrix.Vector32, dim[1])
+		l.weight = make(matrix.Vector32, (dim[0]+1)*dim[1])
+		l.sum = make(matrix.Vector, dim[1])
+		if len(dims) > 0 {
+			l.l1 = float64(dims[0][0]) / float64(dims[0][1])
+		}
+		if len(dims) > 1 {
+			l.l2 = float64(dims[1][0]) / float64(dims[1][1])
+		}
+		return l
+	case LayerTanh:
+		l := &layerTanh32{}
+		l.activation = make(matrix.Vector32, dim[0])
+		l.blame = make(matrix.Vector32, dim[0])
+		return l
+	case LayerLeakyRectifier:
+		l := &layerLeakyRectifier32{}
+		l.activation = make(matrix.Vector32, dim[0])
+		l.blame = make(matrix.Vector32, dim[0])
+		return l
+	case LayerSinusoidal:
+		l := &layerSinusoidal32{numSin: dim[0]}
+		n := dim[0]
+		if len(dim) > 1 {
+			n += dim[1]
+		}
+		l.activation = make(matrix.Vector32, n)
+		l.blame = make(matrix.Vector32, n)
+		l.derivative = make(matrix.Vector32, l.numSin)
+		return l
+	default:
+		panic("Unsupported layer type for float32!!!")
+	}
+}
+
+type layerLinear32 struct {
+	base32
+	l1, l2 float64
+	sum    matrix.Vector // float64 accumulator of Activate
+}
+
+func (l *layerLinear32) Activate(xo *matrix.Vector32) *matrix.Vector32 {
+	x := *xo
+	rows := len(x)
+	cols := len(l.activation)
+	w := l.weight[rows*cols:]
+	for i := 0; i < cols; i++ {
+		l.sum[i] = float64(w[i])
+	}
+	for j := 0; j < rows; j++ {
+		xj := float64(x[j])
+		w = l.weight[j*cols : (j+1)*cols]
+		for i := 0; i < cols; i++ {
+			l.sum[i] += xj * float64(w[i])
+		}
+	}
+	for i, s := range l.sum {
+		l.activation[i] = float32(s)
+	}
+	return &(l.activation)
+}
+
+// BackProp computes prevBlame = M^t*blame.
+func (l *layerLinear32) BackProp(prevBlame *matrix.Vector32) {
+	cols := len(l.activation)
+	rows := len(l.weight) / cols
+	for i := 0; i < rows-1; i++ {
+		(*prevBlame)[i] = float32(l.blame.Dot(l.weight[i*cols : (i+1)*cols]))
+	}
+}
+
+func (l *layerLinear32) UpdateGradient(in *matrix.Vector32, gradient *matrix.Vector) {
+	x := *in
+	cols := len(l.blame)
+	rows := len(*gradient) / cols
+
+	l1 := float64((rows - 1) * cols)
+	l2 := l.l2 / l1
+	l1 = l.l1 / l1
+	bb := l.blame
+	for i := 0; i < rows-1; i++ {
+		temp := (*gradient)[i*cols : (i+1)*cols]
+		w := l.weight[i*cols : (i+1)*cols]
+		xi := float64(x[i])
+		for j := 0; j < cols; j++ {
+			temp[j] += xi*float64(bb[j]) + l2*float64(w[j])
+			if w[j] < 0 {
+				temp[j] -= l1
+			} else {
+				temp[j] += l1
+			}
+		}
+	}
+
+	// compute b += blame
+	temp := (*gradient)[(rows-1)*cols:]
+	for i := 0; i < cols; i++ {
+		temp[i] += float64(bb[i])
+	}
+}
+
+func (l *layerLinear32) Name() string {
+	return "Layer Linear (float32)"
+}
+
+type layerTanh32 struct {
+	base32
+}
+
+func (l *layerTanh32) Activate(x *matrix.Vector32) *matrix.Vector32 {
+	for i, v := range *x {
+		l.activation[i] = float32(math.Tanh(float64(v)))
+	}
+	return &(l.activation)
+}
+
+func (l *layerTanh32) BackProp(prevBlame *matrix.Vector32) {
+	v := *prevBlame
+	for i := 0; i < len(v); i++ {
+		a := l.activation[i]
+		v[i] = l.blame[i] * (1 - a*a)
+	}
+}
+
+func (l *layerTanh32) Name() string {
+	return "Layer Tanh (float32)"
+}
+
+type layerLeakyRectifier32 struct {
+	base32
+}
+
+func (l *layerLeakyRectifier32) Activate(x *matrix.Vector32) *matrix.Vector32 {
+	for i, v := range *x {
+		if v < 0 {
+			v *= 0.01
+		}
+		l.activation[i] = v
+	}
+	return &(l.activation)
+}
+
+func (l *layerLeakyRectifier32) BackProp(prevBlame *matrix.Vector32) {
+	v := *prevBlame
+	for i := 0; i < len(v); i++ {
+		v[i] = l.blame[i]
+		if l.activation[i] < 0 {
+			v[i] *= 0.01
+		}
+	}
+}
+
+func (l *layerLeakyRectifier32) Name() string {
+	return "Layer Leaky Rectifier (float32)"
+}
+
+type layerSinusoidal32 struct {
+	base32
+	numSin     int
+	derivative matrix.Vector32
+}
+
+func (l *layerSinusoidal32) Activate(x *matrix.Vector32) *matrix.Vector32 {
+	for i := 0; i < l.numSin; i++ {
+		s, c := math.Sincos(float64((*x)[i]))
+		l.activation[i] = float32(s)
+		l.derivative[i] = float32(c)
+	}
+	copy(l.activation[l.numSin:], (*x)[l.numSin:])
+	return &(l.activation)
+}
+
+func (l *layerSinusoidal32) BackProp(prevBlame *matrix.Vector32) {
+	v := *prevBlame
+	for i := 0; i < l.numSin; i++ {
+		v[i] = l.blame[i] * l.derivative[i]
+	}
+	copy(v[l.numSin:], l.blame[l.numSin:])
+}
+
+func (l *layerSinusoidal32) Name() string {
+	return "Layer Sinusoidal (float32)"
+}
+
+// neuralNet32 is a neuralNet whose activations and weights are stored
+// in single precision, which halves the memory and bandwidth of large
+// networks such as the MNIST ones. Gradients and the sums inside a
+// layer are float64. Only the LayerLinear, LayerTanh,
+// LayerLeakyRectifier and LayerSinusoidal layers are supported.
+type neuralNet32 struct {
+	layers []layer32
+}
+
+// NewNeuralNet32 creates an empty single precision neural network.
+// Layers are added with AddLayer as for NewNeuralNet.
+func NewNeuralNet32() *neuralNet32 {
+	n := neuralNet32{}
+	n.layers = make([]layer32, 0, 4)
+	return &n
+}
+
+func (n *neuralNet32) AddLayer(t LayerType, dim Dims, dims ...Dims) {
+	n.layers = append(n.layers, newLayer32(t, dim, dims...))
+}
+
+// ToFloat32 returns a single precision copy of the network with the
+// same layers and weights (rounded to float32).
+func (n *neuralNet) ToFloat32() *neuralNet32 {
+	c := NewNeuralNet32()
+	for _, l := range n.layers {
+		var c32 layer32
+		switch t := l.(type) {
+		case *layerLinear:
+			out := len(t.activation)
+			in := len(t.weight)/out - 1
+			lin := newLayer32(LayerLinear, Dims{in, out}).(*layerLinear32)
+			lin.l1, lin.l2 = t.l1, t.l2
+			c32 = lin
+		case *layerTanh:
+			c32 = newLayer32(LayerTanh, Dims{len(t.activation)})
+		case *layerLeakyRectifier:
+			c32 = newLayer32(LayerLeakyRectifier, Dims{len(t.activation)})
+		case *layerSinusoidal:
+			c32 = newLayer32(LayerSinusoidal,
+				Dims{t.numSin, len(t.activation) - t.numSin})
+		default:
+			panic("neuralNet.ToFloat32: unsupported layer " + l.Name())
+		}
+		copy(*c32.Weight(), l.Weight().ToFloat32())
+		c.layers = append(c.layers, c32)
+	}
+	return c
+}
+
+// OutDim return the dimension of the output of a neural network.
+func (n *neuralNet32) OutDim() Dims {
+	return Dims{len(*(n.layers[len(n.layers)-1].Activation()))}
+}
+
+// CopyWeight returns a double precision copy of the weights of every
+// layer, which can be passed to neuralNet.InitWeight.
+func (n *neuralNet32) CopyWeight() []matrix.Vector {
+	g := make([]matrix.Vector, len(n.layers))
+	for i := 0; i < len(n.layers); i++ {
+		g[i] = n.layers[i].Weight().ToFloat64()
+	}
+	return g
+}
+
+// InitWeight sets the weights to w, or to the same random weights as
+// neuralNet.InitWeight if w is empty.
+func (n *neuralNet32) InitWeight(w []matrix.Vector) {
+	if len(w) > 0 {
+		for i := 0; i < len(n.layers); i++ {
+			copy(*(n.layers[i].Weight()), w[i].ToFloat32())
+		}
+		return
+	}
+
+	r := rand.NewRand(2162018)
+	for i := 0; i < len(n.layers); i++ {
+		n.initLayerWeight(i, r)
+	}
+}
+
+// InitWeightWith sets the same random weights as
+// neuralNet.InitWeightWith.
+func (n *neuralNet32) InitWeightWith(r *rand.Rand) {
+	for i := 0; i < len(n.layers); i++ {
+		n.initLayerWeight(i, r.Derive(uint64(i)))
+	}
+}
+
+// initLayerWeight draws the weights of layer i from r.
+func (n *neuralNet32) initLayerWeight(i int, r *rand.Rand) {
+	weight := *(n.layers[i].Weight())
+	outputCount := len(*(n.layers[i].Activation()))
+	if outputCount == 0 {
+		return
+	}
+	inputCount := len(weight)/outputCount - 1
+	max := 1.0
+	if inputCount != 0 {
+		max /= float64(inputCount)
+	}
+	if max < 0.03 {
+		max = 0.03
+	}
+	for j := 0; j < len(weight); j++ {
+		weight[j] = float32(max * r.Normal())
+	}
+}
+
+func (n *neuralNet32) Name() string {
+	s := "\ninput => "
+	for i := 0; i < len(n.layers); i++ {
+		s += n.layers[i].Name() + " -> "
+	}
+	s += "output\n"
+	return s
+}
+
+// Train runs one epoch of training as neuralNet.Train, with the same
+// params and the same order of examples.
+func (n *neuralNet32) Train(features, labels *matrix.Matrix32,
+	params map[string]float64) {
+	matrix.Require(features.Rows() == labels.Rows(),
+		"neuralNet32.Train: Expect %s but get %d = %d\n",
+		"features.Rows() == labels.Rows()", features.Rows(), labels.Rows())
+
+	gradient := n.CreateGradient()
+	epoch(labels.Rows(), params, func(batch []int, learningRate, momentum float64) {
+		ScaleGradient(gradient, momentum)
+		for _, p := range batch {
+			x := features.Row(p)
+			n.Activate(&x)
+			n.BackProp(labels.Row(p))
+			n.UpdateGradient(&x, gradient)
+		}
+		n.RefineWeight(gradient, learningRate/float64(len(batch)))
+	})
+}
+
+// Activate activates the whole network based on the input in.
+func (n *neuralNet32) Activate(in *matrix.Vector32) *matrix.Vector32 {
+	activation := in
+	for i := 0; i < len(n.layers); i++ {
+		activation = n.layers[i].Activate(activation)
+	}
+	return activation
+}
+
+// Predict returns a copy of the output of the network for in.
+func (n *neuralNet32) Predict(in matrix.Vector32) matrix.Vector32 {
+	out := *(n.Activate(&in))
+	p := make(matrix.Vector32, len(out))
+	copy(p, out)
+	return p
+}
+
+// BackProp computes the blame of every layer for the target, without
+// the constant 2 of the derivative as neuralNet.BackProp.
+func (n *neuralNet32) BackProp(target matrix.Vector32) {
+	N := len(n.layers)
+	output := *(n.layers[N-1].Activation())
+	blame := *(n.layers[N-1].Blame())
+	for i := range blame {
+		blame[i] = target[i] - output[i]
+	}
+	for i := N - 1; i > 0; i-- {
+		n.layers[i].BackProp(n.layers[i-1].Blame())
+	}
+}
+
+// UpdateGradient adds the gradient of the last example to g. The
+// network must be activated and backpropagated.
+func (n *neuralNet32) UpdateGradient(x *matrix.Vector32, g *[]matrix.Vector) {
+	gradient := *g
+	n.layers[0].UpdateGradient(x, &(gradient[0]))
+	for i := 1; i < len(gradient); i++ {
+		n.layers[i].UpdateGradient(n.layers[i-1].Activation(), &(gradient[i]))
+	}
+}
+
+// CreateGradient returns a zero gradient in double precision.
+func (n *neuralNet32) CreateGradient() *[]matrix.Vector {
+	g := make([]matrix.Vector, len(n.layers))
+	for i := 0; i < len(n.layers); i++ {
+		g[i] = matrix.NewVector(len(*(n.layers[i].Weight())), nil)
+	}
+	return &g
+}
+
+// RefineWeight adds rate times the gradient to the weights. The update
+// is computed in float64 and rounded once.
+func (n *neuralNet32) RefineWeight(gradient *[]matrix.Vector, rate float64) {
+	g := *gradient
+	for i := 0; i < len(n.layers); i++ {
+		w := *(n.layers[i].Weight())
+		for j, v := range g[i] {
+			w[j] = float32(float64(w[j]) + rate*v)
+		}
+	}
+}
@@ -66,6 +66,15 @@ func SSE(learner SupervisedLearner, features, labels *matrix.Matrix) float64 {
 // perform m-repititions n-fold cross-validation
 func MRepNFoldCrossValidation(learner SupervisedLearner,
 	features, labels *matrix.Matrix, m, n int) matrix.Vector {
+	return MRepNFoldCrossValidationWith(learner, features, labels, m, n,
+		rand.NewRand(1982))
+}
+
+// MRepNFoldCrossValidationWith is like MRepNFoldCrossValidation but
+// shuffles the rows with r, e.g. root.Derive(experiment) to run
+// several experiments from one seed.
+func MRepNFoldCrossValidationWith(learner SupervisedLearner,
+	features, labels *matrix.Matrix, m, n int, r *rand.Rand) matrix.Vector {
 
 	matrix.Require(features.Rows() == labels.Rows(),
 		"MRepNFoldCrossValidation: features and labels must have the same number of rows\n")
@@ -85,16 +94,14 @@ func MRepNFoldCrossValidation(learner SupervisedLearner,
 		foldSize[i]++
 	}
 
-	r := rand.NewRand(1982)
 	var trainDataX, testDataX, trainDataY, testDataY matrix.Matrix
 	sse := matrix.NewVector(m, nil)
 	for i := 0; i < m; i++ {
 		// shuffling data
-		for j := rows; j > 1; j-- {
-			l := int(r.Next(uint64(j)))
-			features.SwapRows(j-1, l)
-			labels.SwapRows(j-1, l)
-		}
+		r.Shuffle(rows, func(a, b int) {
+			features.SwapRows(a, b)
+			labels.SwapRows(a, b)
+		})
 
 		// training
 
@@ -0,0 +1,615 @@
+package matrix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ARFF_DATE_FORMAT is the date pattern used by an ARFF date attribute
+// that does not specify one.
+const ARFF_DATE_FORMAT = "yyyy-MM-dd'T'HH:mm:ss"
+
+// legacyDatePatterns maps the date patterns accepted by earlier
+// versions of LoadARFF that are not valid Java patterns to the
+// patterns they meant.
+var legacyDatePatterns = map[string]string{
+	"yyyy-MM-ddTHH:mm:ss": ARFF_DATE_FORMAT,
+}
+
+// LoadARFF loads data from ARFF file to a matrix
+func (m *Matrix) LoadARFF(fileName string, tz ...string) *Matrix {
+	must(m.LoadARFFErr(fileName, tz...))
+	return m
+}
+
+// LoadARFFErr is like LoadARFF but returns an error instead of
+// panicking. Malformed content is reported as a *ParseError. The
+// receiver is only modified if the whole file is loaded successfully.
+// If fileName ends with ".gz", the file is gzip-decompressed.
+func (m *Matrix) LoadARFFErr(fileName string, tz ...string) error {
+	r, err := openFile(fileName)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return m.readARFF(r, fileName, tz...)
+}
+
+// ReadARFF loads ARFF data from r to the matrix. It is the stream
+// version of LoadARFFErr.
+func (m *Matrix) ReadARFF(r io.Reader, tz ...string) error {
+	return m.readARFF(r, "", tz...)
+}
+
+// readARFF parses ARFF data from r. fileName is only used in error
+// messages.
+//
+// Nominal and string attributes are stored as enumerated values;
+// string attributes extend their dictionary as new values are read.
+// Dates are stored as Unix time in seconds. Missing values are stored
+// as UNKNOWN_VALUE and values omitted from a sparse row are 0.
+func (m *Matrix) readARFF(r io.Reader, fileName string, tz ...string) error {
+	timeZone := DEFAULT_TIME_ZONE
+	if len(tz) > 0 {
+		timeZone = tz[0]
+	}
+	loc, err := ParseTimeZone(timeZone)
+	if err != nil {
+		return err
+	}
+
+	var t Matrix
+	t.location = loc
+	// metadata
+	t.attrName = make([]string, 0, EXTRA_NUM_CELL)
+	t.str_to_enum = make([]map[string]int, 0, EXTRA_NUM_CELL)
+	t.enum_to_str = make([]map[int]string, 0, EXTRA_NUM_CELL)
+	// layouts[j] is the Go layout of column j if it is a date.
+	layouts := make([]string, 0, EXTRA_NUM_CELL)
+
+	attrFile := bufio.NewReader(r)
+	var line string
+	parseError := func(line, col int, msg string, err error) error {
+		return &ParseError{File: fileName, Line: line, Column: col,
+			Msg: msg, Err: err}
+	}
+
+	// read attributes' names and data types.
+	lineNum := 0 // current line
+	t.cols = 0
+	inData := false
+	for !inData {
+		line, err = attrFile.ReadString('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			break
+		}
+		lineNum++
+		sc := arffScanner{s: strings.TrimRight(line, "\r\n")}
+		if sc.done() {
+			if err != nil {
+				break
+			}
+			continue
+		}
+		keyword, _, scanErr := sc.token("")
+		if scanErr != nil {
+			return parseError(lineNum, 0, "invalid header", scanErr)
+		}
+		switch strings.ToLower(keyword) {
+		case "@relation":
+			if !sc.done() {
+				t.relation, _, scanErr = sc.token("")
+				if scanErr != nil {
+					return parseError(lineNum, 0, "invalid relation name",
+						scanErr)
+				}
+			}
+		case "@attribute":
+			name, _, scanErr := sc.token("{")
+			if scanErr != nil {
+				return parseError(lineNum, 0, "invalid attribute name",
+					scanErr)
+			}
+			enum := make(map[int]string)
+			str := make(map[string]int)
+			layout := ""
+			if sc.peek() == '{' { // nominal
+				sc.pos++
+				enum[ATTR_NAME] = "nominal"
+				for sc.peek() != '}' {
+					v, _, scanErr := sc.token(",}")
+					if scanErr != nil {
+						return parseError(lineNum, 0, fmt.Sprintf(
+							"attribute %s: invalid nominal value", name),
+							scanErr)
+					}
+					if _, ok := str[v]; !ok {
+						enum[len(str)] = v
+						str[v] = len(str)
+					}
+					if sc.peek() == ',' {
+						sc.pos++
+					} else if sc.peek() != '}' {
+						return parseError(lineNum, 0, fmt.Sprintf(
+							"attribute %s: expected ',' or '}'", name), nil)
+					}
+				}
+				sc.pos++
+			} else {
+				typ, _, scanErr := sc.token("")
+				if scanErr != nil {
+					return parseError(lineNum, 0, fmt.Sprintf(
+						"attribute %s: missing data type", name), scanErr)
+				}
+				switch strings.ToLower(typ) {
+				case "integer", "real", "numeric":
+					enum[ATTR_NAME] = "real"
+				case "string":
+					enum[ATTR_NAME] = "string"
+				case "date":
+					pattern := ARFF_DATE_FORMAT
+					if !sc.done() {
+						pattern, _, scanErr = sc.token("")
+						if scanErr != nil {
+							return parseError(lineNum, 0, fmt.Sprintf(
+								"attribute %s: invalid date format", name),
+								scanErr)
+						}
+					}
+					if p, ok := legacyDatePatterns[pattern]; ok {
+						pattern = p
+					}
+					layout, scanErr = JavaDateLayout(pattern)
+					if scanErr != nil {
+						return parseError(lineNum, 0, fmt.Sprintf(
+							"attribute %s: invalid date format", name),
+							scanErr)
+					}
+					enum[ATTR_NAME] = "date"
+					enum[ATTR_FORMAT] = pattern
+				default:
+					return parseError(lineNum, 0, fmt.Sprintf(
+						"attribute %s: data type %q is not supported",
+						name, typ), nil)
+				}
+			}
+			if !sc.done() {
+				return parseError(lineNum, 0, fmt.Sprintf(
+					"attribute %s: unexpected %q", name, sc.s[sc.pos:]), nil)
+			}
+			t.attrName = append(t.attrName, name)
+			t.enum_to_str = append(t.enum_to_str, enum)
+			t.str_to_enum = append(t.str_to_enum, str)
+			layouts = append(layouts, layout)
+			t.cols++
+		case "@data":
+			inData = true
+		default:
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if t.cols == 0 {
+		return parseError(lineNum, 0, "no attribute found", nil)
+	}
+
+	// read data
+	t.matrix = make([]Vector, 0, EXTRA_NUM_CELL)
+	weight := make(Vector, 0, EXTRA_NUM_CELL)
+	weighted := false
+	row := 0
+	for inData {
+		line, err = attrFile.ReadString('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			break
+		}
+		lineNum++
+		sc := arffScanner{s: strings.TrimRight(line, "\r\n")}
+		if sc.done() {
+			if err != nil {
+				break
+			}
+			continue
+		}
+
+		fields, sparse, w, scanErr := sc.row()
+		if scanErr != nil {
+			return parseError(lineNum, 0, "invalid row", scanErr)
+		}
+		if !sparse && len(fields) != t.cols {
+			return parseError(lineNum, 0, fmt.Sprintf(
+				"wrong number of attributes: expected %d but get %d",
+				t.cols, len(fields)), nil)
+		}
+		t.sparse = t.sparse || sparse
+		t.matrix = append(t.matrix, make([]float64, t.cols))
+		for _, f := range fields {
+			j := f.idx
+			if j < 0 || j >= t.cols {
+				return parseError(lineNum, 0, fmt.Sprintf(
+					"attribute index %d out of bound [0, %d)", j, t.cols), nil)
+			}
+			if f.val == "?" && !f.quoted {
+				t.matrix[row][j] = UNKNOWN_VALUE
+				continue
+			}
+			switch t.enum_to_str[j][ATTR_NAME][0] {
+			case 'n': // nominal
+				v, ok := t.str_to_enum[j][f.val]
+				if !ok {
+					return parseError(lineNum, j+1, fmt.Sprintf(
+						"%q is not a value of attribute %s",
+						f.val, t.attrName[j]), nil)
+				}
+				t.matrix[row][j] = float64(v)
+			case 's': // string
+				v, ok := t.str_to_enum[j][f.val]
+				if !ok {
+					v = len(t.str_to_enum[j])
+					t.str_to_enum[j][f.val] = v
+					t.enum_to_str[j][v] = f.val
+				}
+				t.matrix[row][j] = float64(v)
+			case 'r': // real
+				number, parseErr := strconv.ParseFloat(f.val, 64)
+				if errors.Is(parseErr, strconv.ErrSyntax) {
+					return parseError(lineNum, j+1,
+						"not a valid real number", parseErr)
+				}
+				t.matrix[row][j] = number
+			case 'd': // date
+				d, parseErr := time.ParseInLocation(layouts[j], f.val, loc)
+				if parseErr != nil {
+					return parseError(lineNum, j+1, "not a valid date",
+						parseErr)
+				}
+				t.matrix[row][j] = timeToUnix(d)
+			}
+		}
+		wv := 1.0
+		if w != "" {
+			var parseErr error
+			wv, parseErr = strconv.ParseFloat(w, 64)
+			if parseErr != nil {
+				return parseError(lineNum, 0, "not a valid instance weight",
+					parseErr)
+			}
+			weighted = true
+		}
+		weight = append(weight, wv)
+		row++
+		if err != nil {
+			break
+		}
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+	t.rows = row
+	t.data = make(Vector, t.rows*t.cols)
+	for i := 0; i < t.rows; i++ {
+		copy(t.data[i*t.cols:], t.matrix[i])
+		t.matrix[i] = t.data[i*t.cols : (i+1)*t.cols]
+	}
+	if weighted {
+		t.weight = weight
+	}
+	*m = t
+	return nil
+}
+
+// SaveARFF save data from a matrix to an ARFF file.
+func (m *Matrix) SaveARFF(fileName string, headerOn ...bool) {
+	must(m.SaveARFFErr(fileName, headerOn...))
+}
+
+// SaveARFFErr is like SaveARFF but returns an error instead of
+// panicking when the file cannot be written. If fileName ends with
+// ".gz", the file is gzip-compressed.
+func (m *Matrix) SaveARFFErr(fileName string, headerOn ...bool) error {
+	w, err := createFile(fileName)
+	if err != nil {
+		return err
+	}
+	if err = m.WriteARFF(w, headerOn...); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// WriteARFF writes the matrix in ARFF format to w. The header
+// (relation and attributes) is written unless headerOn[0] is false.
+// Rows are written in sparse format if SetSparseARFF(true) was called
+// or the matrix was loaded from a sparse ARFF file. Instance weights
+// other than 1 are written after each row.
+func (m *Matrix) WriteARFF(w io.Writer, headerOn ...bool) error {
+	if err := m.checkWeights("WriteARFF"); err != nil {
+		return err
+	}
+	header := true
+	if len(headerOn) > 0 {
+		header = headerOn[0]
+	}
+	loc := m.timeLocation()
+	layouts := make([]string, m.cols)
+	for i := 0; i < m.cols; i++ {
+		if m.enum_to_str[i][ATTR_NAME][0] == 'd' {
+			var err error
+			layouts[i], err = JavaDateLayout(m.datePattern(i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	file := bufio.NewWriter(w)
+	if header {
+		file.WriteString("@relation " + arffQuote(m.relation) + "\n\n")
+		for i := 0; i < m.cols; i++ {
+			file.WriteString("@attribute " + arffQuote(m.attrName[i]) + "\t")
+			s := m.enum_to_str[i][ATTR_NAME]
+			switch s[0] {
+			case 'n': // nominal
+				file.WriteString("{")
+				for j := 0; j < len(m.str_to_enum[i]); j++ {
+					if j > 0 {
+						file.WriteString(",")
+					}
+					file.WriteString(arffQuote(m.enum_to_str[i][j]))
+				}
+				file.WriteString("}\n")
+			case 'r': // real
+				file.WriteString("real\n")
+			case 's': // string
+				file.WriteString("string\n")
+			default: // date
+				file.WriteString("date " + arffQuote(m.datePattern(i)) + "\n")
+			}
+		}
+		file.WriteString("\n@data\n")
+	}
+	// write data
+	for i := 0; i < m.rows; i++ {
+		if m.sparse {
+			file.WriteString("{")
+		}
+		first := true
+		for j := 0; j < m.cols; j++ {
+			v := m.matrix[i][j]
+			if m.sparse && v == 0 {
+				continue
+			}
+			if !first {
+				file.WriteString(",")
+			}
+			first = false
+			if m.sparse {
+				file.WriteString(strconv.Itoa(j) + " ")
+			}
+			var s string
+			if v == UNKNOWN_VALUE {
+				s = "?"
+			} else {
+				switch m.enum_to_str[j][ATTR_NAME][0] {
+				case 'n', 's': // nominal, string
+					s = arffQuote(m.enum_to_str[j][int(v)])
+				case 'r': // real
+					s = strconv.FormatFloat(v, 'g', -1, 64)
+				default: // date
+					s = arffQuote(unixToTime(v, loc).Format(layouts[j]))
+				}
+			}
+			file.WriteString(s)
+		}
+		if m.sparse {
+			file.WriteString("}")
+		}
+		if m.weight != nil && m.weight[i] != 1 {
+			file.WriteString(",{" +
+				strconv.FormatFloat(m.weight[i], 'g', -1, 64) + "}")
+		}
+		file.WriteString("\n")
+	}
+	return file.Flush()
+}
+
+// datePattern returns the date pattern of column c.
+func (m *Matrix) datePattern(c int) string {
+	if p, ok := m.enum_to_str[c][ATTR_FORMAT]; ok {
+		return p
+	}
+	return "yyyy-MM-dd HH:mm:ss"
+}
+
+// arffQuote quotes s if it cannot be written as a bare ARFF token.
+func arffQuote(s string) string {
+	if s != "" && s != "?" && !strings.ContainsAny(s, " \t\r\n,{}%'\"\\") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		case '\n':
+			b.WriteString("\\n")
+		case '\r':
+			b.WriteString("\\r")
+		case '\t':
+			b.WriteString("\\t")
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// arffScanner splits a line of an ARFF file into tokens. Tokens are
+// separated by white space or by the characters given to token. A
+// token may be quoted by ' or " in which case the escape sequences
+// \n, \r, \t, \\, \' and \" are recognized. A % outside of quotes
+// starts a comment that runs to the end of the line.
+type arffScanner struct {
+	s   string
+	pos int
+}
+
+// arffField is a value in a row of an ARFF file together with the
+// index of its attribute.
+type arffField struct {
+	idx    int
+	val    string
+	quoted bool
+}
+
+// skipSpace skips white space and comments.
+func (sc *arffScanner) skipSpace() {
+	for sc.pos < len(sc.s) && (sc.s[sc.pos] == ' ' || sc.s[sc.pos] == '\t') {
+		sc.pos++
+	}
+	if sc.pos < len(sc.s) && sc.s[sc.pos] == '%' {
+		sc.pos = len(sc.s)
+	}
+}
+
+// done reports whether there is no token left on the line.
+func (sc *arffScanner) done() bool {
+	sc.skipSpace()
+	return sc.pos >= len(sc.s)
+}
+
+// peek returns the next non-space character or 0 at the end of line.
+func (sc *arffScanner) peek() byte {
+	if sc.done() {
+		return 0
+	}
+	return sc.s[sc.pos]
+}
+
+// token returns the next token and whether it was quoted. An unquoted
+// token ends at white space, at a comment or at any character in
+// stop.
+func (sc *arffScanner) token(stop string) (string, bool, error) {
+	if sc.done() {
+		return "", false, errors.New("unexpected end of line")
+	}
+	q := sc.s[sc.pos]
+	if q == '"' || q == '\'' {
+		var b strings.Builder
+		for sc.pos++; sc.pos < len(sc.s); sc.pos++ {
+			c := sc.s[sc.pos]
+			if c == q {
+				sc.pos++
+				return b.String(), true, nil
+			}
+			if c == '\\' && sc.pos+1 < len(sc.s) {
+				sc.pos++
+				c = sc.s[sc.pos]
+				switch c {
+				case 'n':
+					c = '\n'
+				case 'r':
+					c = '\r'
+				case 't':
+					c = '\t'
+				}
+			}
+			b.WriteByte(c)
+		}
+		return "", false, errors.New("unterminated quoted string")
+	}
+	start := sc.pos
+	for sc.pos < len(sc.s) {
+		c := sc.s[sc.pos]
+		if c == ' ' || c == '\t' || c == '%' || strings.IndexByte(stop, c) >= 0 {
+			break
+		}
+		sc.pos++
+	}
+	if sc.pos == start {
+		return "", false, fmt.Errorf("unexpected %q", sc.s[sc.pos])
+	}
+	return sc.s[start:sc.pos], false, nil
+}
+
+// row scans a data row. It returns the fields of the row, whether the
+// row is in sparse format, and the instance weight ("" if there is
+// none). Fields of a dense row are indexed by their position.
+func (sc *arffScanner) row() ([]arffField, bool, string, error) {
+	fields := make([]arffField, 0, EXTRA_NUM_CELL)
+	sparse := sc.peek() == '{'
+	if sparse {
+		sc.pos++
+		for sc.peek() != '}' {
+			idx, _, err := sc.token(",}")
+			if err != nil {
+				return nil, true, "", err
+			}
+			j, err := strconv.Atoi(idx)
+			if err != nil {
+				return nil, true, "", err
+			}
+			val, quoted, err := sc.token(",}")
+			if err != nil {
+				return nil, true, "", err
+			}
+			fields = append(fields, arffField{j, val, quoted})
+			if sc.peek() == ',' {
+				sc.pos++
+			} else if sc.peek() != '}' {
+				return nil, true, "", errors.New("expected ',' or '}'")
+			}
+		}
+		sc.pos++
+		if sc.peek() == ',' {
+			sc.pos++
+		}
+	} else {
+		for {
+			val, quoted, err := sc.token(",{")
+			if err != nil {
+				return nil, false, "", err
+			}
+			fields = append(fields, arffField{len(fields), val, quoted})
+			// values may also be separated by white space only
+			if sc.peek() == ',' {
+				sc.pos++
+			}
+			if c := sc.peek(); c == 0 || c == '{' {
+				break
+			}
+		}
+	}
+
+	// instance weight
+	weight := ""
+	if sc.peek() == '{' {
+		sc.pos++
+		var err error
+		if weight, _, err = sc.token("}"); err != nil {
+			return nil, sparse, "", err
+		}
+		if sc.peek() != '}' {
+			return nil, sparse, "", errors.New("expected '}'")
+		}
+		sc.pos++
+	}
+	if !sc.done() {
+		return nil, sparse, "", fmt.Errorf("unexpected %q", sc.s[sc.pos:])
+	}
+	return fields, sparse, weight, nil
+}
@@ -0,0 +1,376 @@
+package matrix
+
+import (
+	"math"
+)
+
+// Elementwise arithmetic on vectors and matrices. The value of
+// UNKNOWN_VALUE is not treated specially: it takes part in the
+// arithmetic as any other number. Use Describe for statistics that skip
+// unknown values.
+
+// MulElem returns the elementwise (Hadamard) product of a and b.
+func (a Vector) MulElem(b Vector) Vector {
+	s := make(Vector, len(a))
+	copy(s, a)
+	return s.MulElemInPlace(b)
+}
+
+// DivElem returns the elementwise quotient a / b.
+func (a Vector) DivElem(b Vector) Vector {
+	s := make(Vector, len(a))
+	copy(s, a)
+	return s.DivElemInPlace(b)
+}
+
+// AddInPlace adds b to a and returns a.
+func (a Vector) AddInPlace(b Vector) Vector {
+	a.add(b, 1)
+	return a
+}
+
+// SubInPlace subtracts b from a and returns a.
+func (a Vector) SubInPlace(b Vector) Vector {
+	a.add(b, -1)
+	return a
+}
+
+// MulElemInPlace multiplies a by b elementwise and returns a.
+func (a Vector) MulElemInPlace(b Vector) Vector {
+	Require(len(a) == len(b),
+		"MulElem: dimension mismatch: len(a) == len(b)\n")
+	for i := range a {
+		a[i] *= b[i]
+	}
+	return a
+}
+
+// DivElemInPlace divides a by b elementwise and returns a.
+func (a Vector) DivElemInPlace(b Vector) Vector {
+	Require(len(a) == len(b),
+		"DivElem: dimension mismatch: len(a) == len(b)\n")
+	for i := range a {
+		a[i] /= b[i]
+	}
+	return a
+}
+
+// AddScalar adds c to every element of v and returns v.
+func (v Vector) AddScalar(c float64) Vector {
+	for i := range v {
+		v[i] += c
+	}
+	return v
+}
+
+// Apply replaces every element x of v by f(x) and returns v.
+func (v Vector) Apply(f func(float64) float64) Vector {
+	for i, x := range v {
+		v[i] = f(x)
+	}
+	return v
+}
+
+// Map returns a new vector with f applied to every element of v.
+func (v Vector) Map(f func(float64) float64) Vector {
+	s := make(Vector, len(v))
+	for i, x := range v {
+		s[i] = f(x)
+	}
+	return s
+}
+
+// Sum returns the sum of the elements of v.
+func (v Vector) Sum() float64 {
+	s := float64(0)
+	for _, x := range v {
+		s += x
+	}
+	return s
+}
+
+// Mean returns the mean of the elements of v, or NaN if v is empty.
+func (v Vector) Mean() float64 {
+	if len(v) == 0 {
+		return math.NaN()
+	}
+	return v.Sum() / float64(len(v))
+}
+
+// Axis selects the direction of a reduction or a broadcast.
+type Axis int
+
+const (
+	// PerColumn gives one value per column: Sum(PerColumn) sums down
+	// each column, and a vector broadcast PerColumn has one element
+	// for every column.
+	PerColumn Axis = iota
+
+	// PerRow gives one value per row: Sum(PerRow) sums across each
+	// row, and a vector broadcast PerRow has one element for every
+	// row.
+	PerRow
+)
+
+// elementwise calls op on every row i of m, a and b to store the
+// result in row i of m. The receiver is resized if needed, see sized.
+func (m *Matrix) elementwise(name string, a, b *Matrix,
+	op func(i int, dst, x, y Vector)) error {
+	if a.rows != b.rows || a.cols != b.cols {
+		return &DimensionError{Op: name, Rows: a.rows, Cols: a.cols,
+			R: b.rows, C: b.cols}
+	}
+	if (m != a && m.sharesData(a)) || (m != b && m.sharesData(b)) {
+		t := NewMatrix(a.rows, a.cols, nil)
+		t.elementwise(name, a, b, op)
+		m.sized(a)
+		for i := 0; i < m.rows; i++ {
+			copy(m.matrix[i], t.matrix[i])
+		}
+		return nil
+	}
+	m.sized(a)
+	for i := 0; i < m.rows; i++ {
+		op(i, m.matrix[i], a.matrix[i], b.matrix[i])
+	}
+	return nil
+}
+
+// sized makes m the size of a. The data and metadata of m are kept if
+// it already has that size; otherwise m becomes a new matrix with the
+// column names of a.
+func (m *Matrix) sized(a *Matrix) {
+	if m.rows == a.rows && m.cols == a.cols && m.matrix != nil {
+		return
+	}
+	*m = *NewMatrix(a.rows, a.cols, nil)
+	m.relation = a.relation
+	copy(m.attrName, a.attrName)
+}
+
+// Add stores a + b in the receiver and returns it. The receiver may be
+// one of the operands, so m.Add(m, b) adds b to m in place.
+func (m *Matrix) Add(a, b *Matrix) *Matrix {
+	must(m.AddErr(a, b))
+	return m
+}
+
+// AddErr is like Add but returns a *DimensionError instead of
+// panicking when a and b do not have the same size.
+func (m *Matrix) AddErr(a, b *Matrix) error {
+	return m.elementwise("Add", a, b, func(_ int, dst, x, y Vector) {
+		for j := range dst {
+			dst[j] = x[j] + y[j]
+		}
+	})
+}
+
+// Sub stores a - b in the receiver and returns it.
+func (m *Matrix) Sub(a, b *Matrix) *Matrix {
+	must(m.SubErr(a, b))
+	return m
+}
+
+// SubErr is like Sub but returns an error instead of panicking.
+func (m *Matrix) SubErr(a, b *Matrix) error {
+	return m.elementwise("Sub", a, b, func(_ int, dst, x, y Vector) {
+		for j := range dst {
+			dst[j] = x[j] - y[j]
+		}
+	})
+}
+
+// MulElem stores the elementwise (Hadamard) product of a and b in the
+// receiver and returns it.
+func (m *Matrix) MulElem(a, b *Matrix) *Matrix {
+	must(m.MulElemErr(a, b))
+	return m
+}
+
+// MulElemErr is like MulElem but returns an error instead of
+// panicking.
+func (m *Matrix) MulElemErr(a, b *Matrix) error {
+	return m.elementwise("MulElem", a, b, func(_ int, dst, x, y Vector) {
+		for j := range dst {
+			dst[j] = x[j] * y[j]
+		}
+	})
+}
+
+// DivElem stores the elementwise quotient a / b in the receiver and
+// returns it.
+func (m *Matrix) DivElem(a, b *Matrix) *Matrix {
+	must(m.DivElemErr(a, b))
+	return m
+}
+
+// DivElemErr is like DivElem but returns an error instead of
+// panicking.
+func (m *Matrix) DivElemErr(a, b *Matrix) error {
+	return m.elementwise("DivElem", a, b, func(_ int, dst, x, y Vector) {
+		for j := range dst {
+			dst[j] = x[j] / y[j]
+		}
+	})
+}
+
+// Add returns a new matrix a + b.
+func Add(a, b *Matrix) *Matrix {
+	var c Matrix
+	return c.Add(a, b)
+}
+
+// Sub returns a new matrix a - b.
+func Sub(a, b *Matrix) *Matrix {
+	var c Matrix
+	return c.Sub(a, b)
+}
+
+// MulElem returns a new matrix with the elementwise product of a and
+// b.
+func MulElem(a, b *Matrix) *Matrix {
+	var c Matrix
+	return c.MulElem(a, b)
+}
+
+// DivElem returns a new matrix with the elementwise quotient a / b.
+func DivElem(a, b *Matrix) *Matrix {
+	var c Matrix
+	return c.DivElem(a, b)
+}
+
+// AddScalar adds c to every element of m and returns m.
+func (m *Matrix) AddScalar(c float64) *Matrix {
+	for i := 0; i < m.rows; i++ {
+		m.matrix[i].AddScalar(c)
+	}
+	return m
+}
+
+// Apply stores f applied to every element of a in the receiver and
+// returns it. Use m.Apply(m, f) to apply f in place.
+func (m *Matrix) Apply(a *Matrix, f func(float64) float64) *Matrix {
+	m.elementwise("Apply", a, a, func(_ int, dst, x, _ Vector) {
+		for j, v := range x {
+			dst[j] = f(v)
+		}
+	})
+	return m
+}
+
+// broadcast stores op applied to the elements of a and the matching
+// elements of v in the receiver.
+func (m *Matrix) broadcast(name string, a *Matrix, v Vector, axis Axis,
+	op func(x, y float64) float64) *Matrix {
+	if axis == PerRow {
+		Require(len(v) == a.rows,
+			"%s: dimension mismatch: %d rows and a vector of length %d\n",
+			name, a.rows, len(v))
+	} else {
+		Require(len(v) == a.cols,
+			"%s: dimension mismatch: %d columns and a vector of length %d\n",
+			name, a.cols, len(v))
+	}
+	m.elementwise(name, a, a, func(i int, dst, x, _ Vector) {
+		if axis == PerRow {
+			for j := range dst {
+				dst[j] = op(x[j], v[i])
+			}
+		} else {
+			for j := range dst {
+				dst[j] = op(x[j], v[j])
+			}
+		}
+	})
+	return m
+}
+
+// AddBroadcast stores a plus the vector v in the receiver and returns
+// it. With axis PerColumn, v[j] is added to column j; with PerRow,
+// v[i] is added to row i. For instance
+//
+//	m.SubBroadcast(m, m.Mean(PerColumn), PerColumn)
+//
+// centers the columns of m.
+func (m *Matrix) AddBroadcast(a *Matrix, v Vector, axis Axis) *Matrix {
+	return m.broadcast("AddBroadcast", a, v, axis,
+		func(x, y float64) float64 { return x + y })
+}
+
+// SubBroadcast is like AddBroadcast but subtracts v.
+func (m *Matrix) SubBroadcast(a *Matrix, v Vector, axis Axis) *Matrix {
+	return m.broadcast("SubBroadcast", a, v, axis,
+		func(x, y float64) float64 { return x - y })
+}
+
+// MulBroadcast is like AddBroadcast but multiplies by v.
+func (m *Matrix) MulBroadcast(a *Matrix, v Vector, axis Axis) *Matrix {
+	return m.broadcast("MulBroadcast", a, v, axis,
+		func(x, y float64) float64 { return x * y })
+}
+
+// DivBroadcast is like AddBroadcast but divides by v.
+func (m *Matrix) DivBroadcast(a *Matrix, v Vector, axis Axis) *Matrix {
+	return m.broadcast("DivBroadcast", a, v, axis,
+		func(x, y float64) float64 { return x / y })
+}
+
+// Sum returns the sums of the columns (axis PerColumn) or of the rows
+// (axis PerRow) of m.
+func (m *Matrix) Sum(axis Axis) Vector {
+	if axis == PerRow {
+		s := make(Vector, m.rows)
+		for i := 0; i < m.rows; i++ {
+			s[i] = m.matrix[i].Sum()
+		}
+		return s
+	}
+	s := make(Vector, m.cols)
+	for i := 0; i < m.rows; i++ {
+		s.add(m.matrix[i], 1)
+	}
+	return s
+}
+
+// Mean returns the means of the columns (axis PerColumn) or of the
+// rows (axis PerRow) of m. Unlike ColumnMean, unknown values are not
+// skipped.
+func (m *Matrix) Mean(axis Axis) Vector {
+	s := m.Sum(axis)
+	n := m.rows
+	if axis == PerRow {
+		n = m.cols
+	}
+	return s.Scale(1 / float64(n))
+}
+
+// SumAll returns the sum of all elements of m.
+func (m *Matrix) SumAll() float64 {
+	s := float64(0)
+	for i := 0; i < m.rows; i++ {
+		s += m.matrix[i].Sum()
+	}
+	return s
+}
+
+// MSE returns the mean squared error between the predictions and the
+// targets, averaged over all elements.
+func MSE(predictions, targets *Matrix) float64 {
+	Require(predictions.rows == targets.rows && predictions.cols == targets.cols,
+		"MSE: dimension mismatch: %d-by-%d and %d-by-%d\n",
+		predictions.rows, predictions.cols, targets.rows, targets.cols)
+	s := float64(0)
+	for i := 0; i < predictions.rows; i++ {
+		t := targets.matrix[i]
+		for j, p := range predictions.matrix[i] {
+			d := p - t[j]
+			s += d * d
+		}
+	}
+	return s / float64(predictions.rows*predictions.cols)
+}
+
+// RMSE returns the square root of MSE.
+func RMSE(predictions, targets *Matrix) float64 {
+	return math.Sqrt(MSE(predictions, targets))
+}
@@ -0,0 +1,309 @@
+package matrix
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Location returns the time zone used to parse and print the dates of
+// m.
+func (m *Matrix) Location() *time.Location {
+	return m.timeLocation()
+}
+
+// SetLocation sets the time zone used to print and save the dates of
+// m. Dates are instants, so the stored values do not change: a date
+// loaded as 10:00 at -06:00 prints as 16:00 with time.UTC. A nil loc
+// restores DEFAULT_TIME_ZONE. Use ParseTimeZone to get a location from
+// a string such as "+05:30" or "America/Chicago".
+func (m *Matrix) SetLocation(loc *time.Location) {
+	m.location = loc
+}
+
+// ReinterpretTimeZone changes the time zone of m to loc keeping the
+// wall clock of every date, i.e. it fixes dates that were parsed in
+// the wrong time zone: a date loaded as 10:00 at -06:00 (the default
+// of LoadARFF) becomes 10:00 in loc. Dates that do not exist in loc,
+// e.g. in a daylight saving gap, are normalized as by time.Date.
+func (m *Matrix) ReinterpretTimeZone(loc *time.Location) {
+	Require(loc != nil, "ReinterpretTimeZone: nil location\n")
+	old := m.timeLocation()
+	for j := 0; j < m.cols; j++ {
+		if !m.isDate(j) {
+			continue
+		}
+		for i := 0; i < m.rows; i++ {
+			v := &m.matrix[i][j]
+			if *v == UNKNOWN_VALUE {
+				continue
+			}
+			t := unixToTime(*v, old)
+			*v = timeToUnix(time.Date(t.Year(), t.Month(), t.Day(), t.Hour(),
+				t.Minute(), t.Second(), t.Nanosecond(), loc))
+		}
+	}
+	m.location = loc
+}
+
+// isDate reports whether column j holds dates.
+func (m *Matrix) isDate(j int) bool {
+	t := m.enum_to_str[j][ATTR_NAME]
+	return len(t) > 0 && t[0] == 'd'
+}
+
+// SetAttrType converts column col to the type typ, one of "real",
+// "nominal", "string" and "date", and returns m.
+//
+// A real or date column becomes nominal (or string) with one value
+// per distinct name, the number or date as it prints (see WriteTable),
+// coded in increasing order; dates within the same second share a
+// value. A nominal column becomes real if
+// every value name is a number, and a date if every name is a date in
+// TIME_FORMAT in the location of m. A real column becomes a date by
+// reading its values as Unix time in seconds, and a date column
+// becomes real the same way. Nominal and string columns only differ by
+// the way they are saved. Unknown values stay unknown, and NaN becomes
+// unknown in a nominal column.
+//
+// The column gets a new dictionary: views of m that were taken before
+// see the new values with the old dictionary.
+func (m *Matrix) SetAttrType(col int, typ string) *Matrix {
+	must(m.SetAttrTypeErr(col, typ))
+	return m
+}
+
+// SetAttrTypeErr is like SetAttrType but returns an error instead of
+// panicking, e.g. when a nominal value is not a number. m is only
+// modified if the conversion succeeds.
+func (m *Matrix) SetAttrTypeErr(col int, typ string) error {
+	if col < 0 || col >= m.cols {
+		return &IndexError{Op: "SetAttrType", Index: col, Len: m.cols}
+	}
+	from := m.GetAttrType(col)
+	switch {
+	case typ != "real" && typ != "nominal" && typ != "string" && typ != "date":
+		return fmt.Errorf("matrix: SetAttrType: unknown type %q", typ)
+	case from == typ:
+		return nil
+	case m.isNominal(col) && (typ == "nominal" || typ == "string"):
+		m.enum_to_str[col][ATTR_NAME] = typ
+		return nil
+	case m.isNominal(col):
+		return m.nominalToNumber(col, typ)
+	case typ == "nominal" || typ == "string":
+		m.numberToNominal(col, typ)
+		return nil
+	}
+	// real <-> date: the values are Unix times either way
+	m.str_to_enum[col] = make(map[string]int)
+	m.enum_to_str[col] = map[int]string{ATTR_NAME: typ}
+	return nil
+}
+
+// numberToNominal converts the real or date column col to typ.
+func (m *Matrix) numberToNominal(col int, typ string) {
+	var values []float64
+	seen := make(map[float64]bool)
+	for i := 0; i < m.rows; i++ {
+		if v := m.matrix[i][col]; v != UNKNOWN_VALUE && !math.IsNaN(v) && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	sort.Float64s(values)
+	code := make(map[float64]int, len(values))
+	se := make(map[string]int, len(values))
+	es := make(map[int]string, len(values)+1)
+	for _, v := range values {
+		var s string
+		if m.isDate(col) {
+			s = unixToTime(v, m.timeLocation()).Format(TIME_FORMAT)
+		} else {
+			s = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		// values that print alike share a code
+		k, ok := se[s]
+		if !ok {
+			k = len(se)
+			se[s] = k
+			es[k] = s
+		}
+		code[v] = k
+	}
+	es[ATTR_NAME] = typ
+	for i := 0; i < m.rows; i++ {
+		switch v := m.matrix[i][col]; {
+		case math.IsNaN(v):
+			m.matrix[i][col] = UNKNOWN_VALUE
+		case v != UNKNOWN_VALUE:
+			m.matrix[i][col] = float64(code[v])
+		}
+	}
+	m.str_to_enum[col], m.enum_to_str[col] = se, es
+}
+
+// nominalToNumber converts the nominal column col to "real" or "date"
+// by parsing its value names.
+func (m *Matrix) nominalToNumber(col int, typ string) error {
+	value := make(map[int]float64, len(m.str_to_enum[col]))
+	for s, e := range m.str_to_enum[col] {
+		var v float64
+		var err error
+		if typ == "date" {
+			var t time.Time
+			t, err = time.ParseInLocation(TIME_FORMAT, s, m.timeLocation())
+			v = timeToUnix(t)
+		} else {
+			v, err = strconv.ParseFloat(s, 64)
+		}
+		if err != nil {
+			return fmt.Errorf("matrix: SetAttrType: column %d (%s): value %q is not a %s: %v",
+				col, m.attrName[col], s, typ, err)
+		}
+		value[e] = v
+	}
+	for i := 0; i < m.rows; i++ {
+		if v := m.matrix[i][col]; v != UNKNOWN_VALUE {
+			m.matrix[i][col] = value[int(v)]
+		}
+	}
+	m.str_to_enum[col] = make(map[string]int)
+	m.enum_to_str[col] = map[int]string{ATTR_NAME: typ}
+	return nil
+}
+
+// requireNominal panics if column col does not hold nominal or string
+// values.
+func (m *Matrix) requireNominal(op string, col int) {
+	Require(col >= 0 && col < m.cols,
+		"%s: index out of bound: c = %d\n", op, col)
+	Require(m.isNominal(col),
+		"%s: column %d (%s) is %s, not nominal\n", op, col,
+		m.attrName[col], m.GetAttrType(col))
+}
+
+// Values returns the names of the values of the nominal column col,
+// indexed by their codes.
+func (m *Matrix) Values(col int) []string {
+	m.requireNominal("Values", col)
+	names := make([]string, 0, len(m.str_to_enum[col]))
+	for _, e := range m.sortedCodes(col) {
+		names = append(names, m.enum_to_str[col][e])
+	}
+	return names
+}
+
+// sortedCodes returns the codes of the values of column col in
+// increasing order.
+func (m *Matrix) sortedCodes(col int) []int {
+	codes := make([]int, 0, len(m.str_to_enum[col]))
+	for _, e := range m.str_to_enum[col] {
+		codes = append(codes, e)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// AddValue adds the value name to the nominal column col, if it is not
+// there yet, and returns its code. The dictionary is shared with the
+// views of m.
+func (m *Matrix) AddValue(col int, name string) int {
+	m.requireNominal("AddValue", col)
+	if e, ok := m.str_to_enum[col][name]; ok {
+		return e
+	}
+	e := 0
+	for _, c := range m.str_to_enum[col] {
+		if c >= e {
+			e = c + 1
+		}
+	}
+	m.str_to_enum[col][name] = e
+	m.enum_to_str[col][e] = name
+	return e
+}
+
+// RenameValue renames the value old of the nominal column col to name,
+// keeping its code. The dictionary is shared with the views of m.
+func (m *Matrix) RenameValue(col int, old, name string) *Matrix {
+	must(m.RenameValueErr(col, old, name))
+	return m
+}
+
+// RenameValueErr is like RenameValue but returns an error instead of
+// panicking when old is not a value of the column or name already is;
+// use MergeValues to merge two values.
+func (m *Matrix) RenameValueErr(col int, old, name string) error {
+	m.requireNominal("RenameValue", col)
+	e, ok := m.str_to_enum[col][old]
+	if !ok {
+		return fmt.Errorf("matrix: RenameValue: column %d (%s) has no value %q",
+			col, m.attrName[col], old)
+	}
+	if old == name {
+		return nil
+	}
+	if _, ok := m.str_to_enum[col][name]; ok {
+		return fmt.Errorf("matrix: RenameValue: column %d (%s) already has a value %q",
+			col, m.attrName[col], name)
+	}
+	delete(m.str_to_enum[col], old)
+	m.str_to_enum[col][name] = e
+	m.enum_to_str[col][e] = name
+	return nil
+}
+
+// MergeValues merges the given values of the nominal column col into
+// the value into, which is added if needed, and returns m. The data
+// are recoded and the codes are renumbered 0, 1, ... in their previous
+// order, so that ValueCount stays the number of codes. Names that are
+// not values of the column are ignored.
+//
+// The column gets a new dictionary: views of m that were taken before
+// see the new codes with the old dictionary.
+func (m *Matrix) MergeValues(col int, into string, names ...string) *Matrix {
+	m.requireNominal("MergeValues", col)
+	merged := make(map[string]bool, len(names))
+	for _, s := range names {
+		merged[s] = s != into
+	}
+	old := m.enum_to_str[col]
+	target := -1
+	if e, ok := m.str_to_enum[col][into]; ok {
+		target = e
+	}
+	se := make(map[string]int)
+	es := map[int]string{ATTR_NAME: old[ATTR_NAME]}
+	if p, ok := old[ATTR_FORMAT]; ok {
+		es[ATTR_FORMAT] = p
+	}
+	add := func(s string) int {
+		if e, ok := se[s]; ok {
+			return e
+		}
+		e := len(se)
+		se[s], es[e] = e, s
+		return e
+	}
+	recode := make(map[int]int)
+	for _, e := range m.sortedCodes(col) {
+		if s := old[e]; merged[s] || e == target {
+			recode[e] = add(into)
+		} else {
+			recode[e] = add(s)
+		}
+	}
+	add(into)
+	for i := 0; i < m.rows; i++ {
+		if v := m.matrix[i][col]; v != UNKNOWN_VALUE {
+			if e, ok := recode[int(v)]; ok {
+				m.matrix[i][col] = float64(e)
+			}
+		}
+	}
+	m.str_to_enum[col], m.enum_to_str[col] = se, es
+	return m
+}
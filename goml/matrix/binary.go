@@ -0,0 +1,456 @@
+package matrix
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+	"unsafe"
+)
+
+// The binary format of a matrix is, in little-endian order:
+//
+//	magic    "GOMX"
+//	version  uint32
+//	flags    uint32 (BINARY_WEIGHTED, BINARY_SPARSE)
+//	relation string
+//	location string and int32 offset in seconds
+//	rows     uint64
+//	cols     uint64
+//	for each column:
+//	  name, type and date pattern as strings
+//	  number of values as uint32 and (uint32, string) pairs
+//	padding  so that the data starts at a multiple of 8 bytes
+//	data     rows*cols float64 in row-major order
+//	weight   rows float64 if BINARY_WEIGHTED is set
+//
+// Strings are written as their length (uint32) followed by their
+// bytes.
+const (
+	BINARY_MAGIC   = "GOMX"
+	BINARY_VERSION = 1
+
+	BINARY_WEIGHTED = 1
+	BINARY_SPARSE   = 2
+)
+
+// binWriter writes the primitive types of the binary format and keeps
+// the first error and the number of bytes written.
+type binWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (bw *binWriter) write(b []byte) {
+	if bw.err != nil {
+		return
+	}
+	var n int
+	n, bw.err = bw.w.Write(b)
+	bw.n += int64(n)
+}
+
+func (bw *binWriter) uint32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	bw.write(b[:])
+}
+
+func (bw *binWriter) uint64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	bw.write(b[:])
+}
+
+func (bw *binWriter) string(s string) {
+	bw.uint32(uint32(len(s)))
+	bw.write([]byte(s))
+}
+
+func (bw *binWriter) vector(v Vector) {
+	buf := make([]byte, 8*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint64(buf[8*i:], math.Float64bits(x))
+	}
+	bw.write(buf)
+}
+
+// binReader reads the primitive types of the binary format and keeps
+// the first error and the number of bytes read.
+type binReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (br *binReader) read(b []byte) {
+	if br.err != nil {
+		return
+	}
+	var n int
+	n, br.err = io.ReadFull(br.r, b)
+	br.n += int64(n)
+	if br.err == io.EOF {
+		br.err = io.ErrUnexpectedEOF
+	}
+}
+
+func (br *binReader) uint32() uint32 {
+	var b [4]byte
+	br.read(b[:])
+	return binary.LittleEndian.Uint32(b[:])
+}
+
+func (br *binReader) uint64() uint64 {
+	var b [8]byte
+	br.read(b[:])
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+func (br *binReader) string() string {
+	n := br.uint32()
+	if br.err != nil {
+		return ""
+	}
+	if n > 1<<30 {
+		br.err = errors.New("matrix: binary: string too long")
+		return ""
+	}
+	b := make([]byte, n)
+	br.read(b)
+	return string(b)
+}
+
+func (br *binReader) vector(v Vector) {
+	buf := make([]byte, 8*4096)
+	for i := 0; i < len(v) && br.err == nil; {
+		n := len(v) - i
+		if n > 4096 {
+			n = 4096
+		}
+		br.read(buf[:8*n])
+		for k := 0; k < n; k++ {
+			v[i+k] = math.Float64frombits(
+				binary.LittleEndian.Uint64(buf[8*k:]))
+		}
+		i += n
+	}
+}
+
+// vectorN reads n values into a new Vector that grows as the values
+// arrive, so that a corrupt size fails at the end of the input instead
+// of allocating memory for values that are not there.
+func (br *binReader) vectorN(n int) Vector {
+	const chunk = 1 << 16
+	c := n
+	if c > chunk {
+		c = chunk
+	}
+	v := make(Vector, 0, c)
+	for len(v) < n && br.err == nil {
+		k := n - len(v)
+		if k > chunk {
+			k = chunk
+		}
+		v = append(v, make(Vector, k)...)
+		br.vector(v[len(v)-k:])
+	}
+	return v
+}
+
+// maxBinaryElems bounds the number of values of the data block and the
+// weights, so that their size in bytes fits an int.
+const maxBinaryElems = math.MaxInt / 8
+
+// WriteBinary writes the matrix and its metadata to w in a compact
+// binary format. See BINARY_MAGIC for the layout.
+func (m *Matrix) WriteBinary(w io.Writer) error {
+	if err := m.checkWeights("WriteBinary"); err != nil {
+		return err
+	}
+	bw := &binWriter{w: bufio.NewWriter(w)}
+	bw.write([]byte(BINARY_MAGIC))
+	bw.uint32(BINARY_VERSION)
+	var flags uint32
+	if m.weight != nil {
+		flags |= BINARY_WEIGHTED
+	}
+	if m.sparse {
+		flags |= BINARY_SPARSE
+	}
+	bw.uint32(flags)
+	bw.string(m.relation)
+	if m.location != nil {
+		_, offset := time.Now().In(m.location).Zone()
+		bw.string(m.location.String())
+		bw.uint32(uint32(int32(offset)))
+	} else {
+		bw.string("")
+		bw.uint32(0)
+	}
+	bw.uint64(uint64(m.rows))
+	bw.uint64(uint64(m.cols))
+	for j := 0; j < m.cols; j++ {
+		bw.string(m.attrName[j])
+		bw.string(m.enum_to_str[j][ATTR_NAME])
+		bw.string(m.enum_to_str[j][ATTR_FORMAT])
+		values := make([]int, 0, len(m.str_to_enum[j]))
+		for _, v := range m.str_to_enum[j] {
+			values = append(values, v)
+		}
+		sort.Ints(values)
+		bw.uint32(uint32(len(values)))
+		for _, v := range values {
+			bw.uint32(uint32(v))
+			bw.string(m.enum_to_str[j][v])
+		}
+	}
+	bw.write(make([]byte, (8-bw.n%8)%8))
+	for i := 0; i < m.rows; i++ {
+		bw.vector(m.matrix[i])
+	}
+	if m.weight != nil {
+		bw.vector(m.weight)
+	}
+	if bw.err != nil {
+		return bw.err
+	}
+	return bw.w.Flush()
+}
+
+// readBinaryHeader reads everything up to the data block. It returns
+// a matrix with its metadata but without data, the flags and the
+// number of bytes read.
+func readBinaryHeader(r io.Reader) (*Matrix, uint32, int64, error) {
+	br := &binReader{r: r}
+	magic := make([]byte, len(BINARY_MAGIC))
+	br.read(magic)
+	if br.err == nil && string(magic) != BINARY_MAGIC {
+		return nil, 0, br.n, errors.New("matrix: binary: bad magic number")
+	}
+	version := br.uint32()
+	if br.err == nil && version != BINARY_VERSION {
+		return nil, 0, br.n, fmt.Errorf(
+			"matrix: binary: unsupported version %d", version)
+	}
+	flags := br.uint32()
+
+	var t Matrix
+	t.relation = br.string()
+	name := br.string()
+	offset := int32(br.uint32())
+	if name != "" {
+		loc, err := ParseTimeZone(name)
+		if err != nil {
+			loc = time.FixedZone(name, int(offset))
+		}
+		t.location = loc
+	}
+	rows := br.uint64()
+	cols := br.uint64()
+	if br.err != nil {
+		return nil, 0, br.n, br.err
+	}
+	// rows*(cols+1) values for the data and the weights
+	if cols > 1<<30 || (cols == 0 && rows > 0) ||
+		(cols > 0 && rows > maxBinaryElems/(cols+1)) {
+		return nil, 0, br.n, &ParseError{
+			Msg: fmt.Sprintf("binary: bad dimensions %d-by-%d", rows, cols)}
+	}
+	t.rows, t.cols = int(rows), int(cols)
+	t.sparse = flags&BINARY_SPARSE != 0
+	// the metadata grows column by column, as for the data
+	c := t.cols
+	if c > 1024 {
+		c = 1024
+	}
+	t.attrName = make([]string, 0, c)
+	t.str_to_enum = make([]map[string]int, 0, c)
+	t.enum_to_str = make([]map[int]string, 0, c)
+	for j := 0; j < t.cols && br.err == nil; j++ {
+		t.attrName = append(t.attrName, br.string())
+		t.str_to_enum = append(t.str_to_enum, make(map[string]int))
+		t.enum_to_str = append(t.enum_to_str, make(map[int]string))
+		t.enum_to_str[j][ATTR_NAME] = br.string()
+		if f := br.string(); f != "" {
+			t.enum_to_str[j][ATTR_FORMAT] = f
+		}
+		n := br.uint32()
+		for k := uint32(0); k < n && br.err == nil; k++ {
+			v := int(br.uint32())
+			s := br.string()
+			t.str_to_enum[j][s] = v
+			t.enum_to_str[j][v] = s
+		}
+		if br.err == nil && t.enum_to_str[j][ATTR_NAME] == "" {
+			br.err = errors.New("matrix: binary: missing attribute type")
+		}
+	}
+	br.read(make([]byte, (8-br.n%8)%8))
+	return &t, flags, br.n, br.err
+}
+
+// ReadBinary reads a matrix written by WriteBinary from r. The
+// receiver is only modified if the whole matrix is read successfully.
+func (m *Matrix) ReadBinary(r io.Reader) error {
+	r = bufio.NewReader(r)
+	t, flags, _, err := readBinaryHeader(r)
+	if err != nil {
+		return err
+	}
+	br := &binReader{r: r}
+	t.data = br.vectorN(t.rows * t.cols)
+	if flags&BINARY_WEIGHTED != 0 {
+		t.weight = br.vectorN(t.rows)
+	}
+	if br.err == io.ErrUnexpectedEOF {
+		return &ParseError{Msg: fmt.Sprintf(
+			"binary: data of %d-by-%d matrix", t.rows, t.cols), Err: br.err}
+	}
+	if br.err != nil {
+		return br.err
+	}
+	t.matrix = make([]Vector, t.rows)
+	for i := 0; i < t.rows; i++ {
+		t.matrix[i] = t.data[i*t.cols : (i+1)*t.cols]
+	}
+	*m = *t
+	return nil
+}
+
+// SaveBinary saves the matrix to a file in the binary format. If
+// fileName ends with ".gz", the file is gzip-compressed.
+func (m *Matrix) SaveBinary(fileName string) error {
+	w, err := createFile(fileName)
+	if err != nil {
+		return err
+	}
+	if err = m.WriteBinary(w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// LoadBinary loads a matrix saved by SaveBinary. If fileName ends
+// with ".gz", the file is gzip-decompressed.
+func (m *Matrix) LoadBinary(fileName string) error {
+	r, err := openFile(fileName)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	err = m.ReadBinary(r)
+	if e, ok := err.(*ParseError); ok {
+		e.File = fileName
+	}
+	return err
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (m *Matrix) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(8*m.rows*m.cols + 64*m.cols + 64)
+	if err := m.WriteBinary(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (m *Matrix) UnmarshalBinary(data []byte) error {
+	return m.ReadBinary(bytes.NewReader(data))
+}
+
+// GobEncode implements gob.GobEncoder so that a Matrix, including its
+// unexported metadata, can be sent with encoding/gob.
+func (m *Matrix) GobEncode() ([]byte, error) {
+	return m.MarshalBinary()
+}
+
+// GobDecode implements gob.GobDecoder.
+func (m *Matrix) GobDecode(data []byte) error {
+	return m.UnmarshalBinary(data)
+}
+
+// MapBinary maps a file saved by SaveBinary (without compression)
+// into memory and returns a matrix whose data is read directly from
+// the mapping, so that datasets larger than memory can be used
+// without copying. The matrix is read-only: writing to it crashes the
+// program. The mapping is released by calling Close on the returned
+// io.Closer, after which the matrix must not be used. On platforms
+// without mmap, or on big-endian machines, the file is read into
+// memory instead.
+func MapBinary(fileName string) (*Matrix, io.Closer, error) {
+	b, closer, err := mmapFile(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, flags, n, err := readBinaryHeader(bytes.NewReader(b))
+	if err != nil {
+		closer.Close()
+		if e, ok := err.(*ParseError); ok {
+			e.File = fileName
+		}
+		return nil, nil, err
+	}
+	// the header check keeps these sizes within an int
+	size := 8 * int64(t.rows) * int64(t.cols)
+	need := size
+	if flags&BINARY_WEIGHTED != 0 {
+		need += 8 * int64(t.rows)
+	}
+	if int64(len(b))-n < need {
+		closer.Close()
+		return nil, nil, &ParseError{File: fileName, Msg: fmt.Sprintf(
+			"binary: %d bytes of data for %d-by-%d matrix, need %d",
+			int64(len(b))-n, t.rows, t.cols, need), Err: io.ErrUnexpectedEOF}
+	}
+	t.data = bytesToVector(b[n : n+size])
+	if flags&BINARY_WEIGHTED != 0 {
+		t.weight = bytesToVector(b[n+size : n+need])
+	}
+	t.matrix = make([]Vector, t.rows)
+	for i := 0; i < t.rows; i++ {
+		t.matrix[i] = t.data[i*t.cols : (i+1)*t.cols]
+	}
+	return t, closer, nil
+}
+
+// nopCloser is returned by MapBinary when the file is read instead of
+// mapped.
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// readWholeFile is the fallback of mmapFile.
+func readWholeFile(fileName string) ([]byte, io.Closer, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, nopCloser{}, nil
+}
+
+// decodeVector decodes b as little-endian float64 into a new Vector.
+func decodeVector(b []byte) Vector {
+	v := make(Vector, len(b)/8)
+	for i := range v {
+		v[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[8*i:]))
+	}
+	return v
+}
+
+// littleEndian reports whether the machine stores float64 in
+// little-endian order, so that mapped data can be used as is.
+func littleEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
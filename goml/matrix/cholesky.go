@@ -0,0 +1,125 @@
+package matrix
+
+import (
+	"math"
+)
+
+// Cholesky is the Cholesky factorization A = LL* of a symmetric
+// positive definite matrix A, where L is lower triangular with a
+// positive diagonal.
+type Cholesky struct {
+	l *Matrix
+}
+
+// isSymmetric reports whether a is square and symmetric up to a
+// tolerance relative to its largest element.
+func isSymmetric(a *Matrix) bool {
+	if a.rows != a.cols {
+		return false
+	}
+	max := 0.0
+	for i := 0; i < a.rows; i++ {
+		for j := 0; j < a.cols; j++ {
+			max = math.Max(max, math.Abs(a.matrix[i][j]))
+		}
+	}
+	tol := float64(a.rows) * max * 1e-12
+	for i := 0; i < a.rows; i++ {
+		for j := i + 1; j < a.cols; j++ {
+			if math.Abs(a.matrix[i][j]-a.matrix[j][i]) > tol {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NewCholesky computes the Cholesky factorization of a. It returns
+// ErrNotSymmetric if a is not symmetric and ErrNotPositiveDefinite if
+// a is not positive definite, which makes it a cheap test of positive
+// definiteness.
+func NewCholesky(a *Matrix) (*Cholesky, error) {
+	if a.rows != a.cols {
+		return nil, ErrNotSquare
+	}
+	if !isSymmetric(a) {
+		return nil, ErrNotSymmetric
+	}
+	n := a.rows
+	l := NewMatrix(n, n, nil)
+	for j := 0; j < n; j++ {
+		lj := l.matrix[j]
+		d := a.matrix[j][j] - lj[:j].Dot(lj[:j])
+		if !(d > 0) {
+			return nil, ErrNotPositiveDefinite
+		}
+		d = math.Sqrt(d)
+		lj[j] = d
+		for i := j + 1; i < n; i++ {
+			li := l.matrix[i]
+			li[j] = (a.matrix[i][j] - li[:j].Dot(lj[:j])) / d
+		}
+	}
+	return &Cholesky{l: l}, nil
+}
+
+// L returns the lower triangular factor.
+func (f *Cholesky) L() *Matrix {
+	return f.l.clone()
+}
+
+// Det returns the determinant of A.
+func (f *Cholesky) Det() float64 {
+	return math.Exp(f.LogDet())
+}
+
+// LogDet returns the natural logarithm of the determinant of A, which
+// does not overflow for large matrices as Det does.
+func (f *Cholesky) LogDet() float64 {
+	s := 0.0
+	for i := 0; i < f.l.rows; i++ {
+		s += math.Log(f.l.matrix[i][i])
+	}
+	return 2 * s
+}
+
+// Solve returns the solution X of AX = B. It returns a
+// *DimensionError if B does not have as many rows as A.
+func (f *Cholesky) Solve(b *Matrix) (*Matrix, error) {
+	n := f.l.rows
+	if b.rows != n {
+		return nil, &DimensionError{Op: "Cholesky.Solve", Rows: n, Cols: n,
+			R: b.rows, C: b.cols}
+	}
+	x := b.clone()
+	l := f.l.matrix
+	// forward substitution with L
+	for k := 0; k < n; k++ {
+		x.matrix[k].Scale(1 / l[k][k])
+		for i := k + 1; i < n; i++ {
+			if c := l[i][k]; c != 0 {
+				for t := 0; t < x.cols; t++ {
+					x.matrix[i][t] -= c * x.matrix[k][t]
+				}
+			}
+		}
+	}
+	// backward substitution with L*
+	for k := n - 1; k >= 0; k-- {
+		x.matrix[k].Scale(1 / l[k][k])
+		for i := 0; i < k; i++ {
+			if c := l[k][i]; c != 0 {
+				for t := 0; t < x.cols; t++ {
+					x.matrix[i][t] -= c * x.matrix[k][t]
+				}
+			}
+		}
+	}
+	return x, nil
+}
+
+// Inverse returns the inverse of A.
+func (f *Cholesky) Inverse() *Matrix {
+	x, _ := f.Solve(identity(f.l.rows))
+	return x
+}
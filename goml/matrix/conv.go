@@ -0,0 +1,556 @@
+package matrix
+
+import "math/cmplx"
+
+// Padding selects how much the input of ConvolveWith is padded, and
+// thus the dims of the output.
+type Padding int
+
+const (
+	// PaddingAuto centers the filter so that the output has the dims
+	// of out, as Convolve does. It is the only mode that needs out.
+	PaddingAuto Padding = iota
+
+	// PaddingValid uses no padding: the filter stays inside the input.
+	PaddingValid
+
+	// PaddingSame pads so that the output has ceil(in/stride) elements
+	// along each axis, with the extra element after if the padding is
+	// odd.
+	PaddingSame
+
+	// PaddingFull pads by the dilated filter size minus one on both
+	// sides, so that every overlap of the filter and the input counts.
+	PaddingFull
+
+	// PaddingExplicit pads by ConvOptions.Pad.
+	PaddingExplicit
+)
+
+// Boundary selects the values of the input outside of its bounds.
+type Boundary int
+
+const (
+	// BoundaryZero pads with zeros.
+	BoundaryZero Boundary = iota
+
+	// BoundaryReflect mirrors the input without repeating the edge:
+	// x2 x1 | x0 x1 x2 ...
+	BoundaryReflect
+
+	// BoundaryReplicate repeats the edge: x0 x0 | x0 x1 x2 ...
+	BoundaryReplicate
+)
+
+// ConvMethod selects the algorithm of ConvolveWith.
+type ConvMethod int
+
+const (
+	// ConvAuto uses ConvFFT for very large filters, ConvIm2Col for
+	// large filters and ConvDirect otherwise.
+	ConvAuto ConvMethod = iota
+
+	// ConvDirect sums over the filter for every output element.
+	ConvDirect
+
+	// ConvIm2Col copies the input patches into a matrix (see Im2Col)
+	// and computes all output channels with one blocked matrix
+	// multiply. It uses memory proportional to the output size times
+	// the filter size.
+	ConvIm2Col
+
+	// ConvFFT multiplies the Fourier transforms of the padded input and
+	// of the filter (see FFTN). Its cost does not depend on the filter
+	// size, which makes it the fastest for long 1-D signals and big
+	// filters. Results differ from ConvDirect by rounding only.
+	ConvFFT
+)
+
+// im2colMin is the number of filter elements (times the number of
+// channels) from which ConvAuto uses ConvIm2Col, and fftMin the number
+// of filter elements from which it uses ConvFFT.
+const (
+	im2colMin = 64
+	fftMin    = 512
+)
+
+// ConvOptions holds the options of ConvolveWith. The zero value gives
+// the convolution of Convolve with a stride of 1.
+//
+// Stride and Dilation hold one value per axis; a nil slice means 1 and
+// a slice of length 1 applies to every axis. A dilation of d spreads
+// the filter elements d apart, so a filter of size f covers d*(f-1)+1
+// input elements.
+type ConvOptions struct {
+	Stride   []int
+	Dilation []int
+	Padding  Padding
+	Pad      [][2]int // padding before and after each axis, for PaddingExplicit
+	Boundary Boundary
+	Flip     bool // flip the filter along every axis
+	Method   ConvMethod
+}
+
+// axisValue returns the value of a per-axis option for axis i.
+func axisValue(v []int, i int) int {
+	switch len(v) {
+	case 0:
+		return 1
+	case 1:
+		return v[0]
+	default:
+		return v[i]
+	}
+}
+
+// convPlan maps the output and filter elements of a convolution to
+// the input elements they read.
+type convPlan struct {
+	in, filter, out []int // dims of the spatial axes
+
+	// index[i][o*filter[i]+k] is the input position read along axis i
+	// by output position o and filter position k, or -1 if it is a
+	// zero of the padding.
+	index [][]int
+	flip  bool
+
+	boundary Boundary
+
+	// stride, dilation and padding before each axis
+	stride, dilation, before []int
+}
+
+// newConvPlan computes the plan of a convolution. out is only used
+// (and required) by PaddingAuto.
+func newConvPlan(in, filter, out []int, opts *ConvOptions) *convPlan {
+	dc := len(in)
+	Require(len(filter) == dc,
+		"Convolve: Expected tensors with the same number of dimensions\n")
+	p := &convPlan{in: in, filter: filter, out: make([]int, dc),
+		index: make([][]int, dc), flip: opts.Flip, stride: make([]int, dc),
+		dilation: make([]int, dc), before: make([]int, dc), boundary: opts.Boundary}
+	for i := 0; i < dc; i++ {
+		s, d := axisValue(opts.Stride, i), axisValue(opts.Dilation, i)
+		Require(s > 0 && d > 0,
+			"Convolve: stride and dilation must be positive\n")
+		span := d*(filter[i]-1) + 1
+		var before int
+		switch opts.Padding {
+		case PaddingAuto:
+			Require(len(out) == dc, "Convolve: PaddingAuto needs the dims of out\n")
+			p.out[i] = out[i]
+			before = (s*(out[i]-1) + span - in[i]) / 2
+		case PaddingValid:
+			Require(in[i] >= span,
+				"Convolve: filter span %d larger than input %d\n", span, in[i])
+			p.out[i] = (in[i]-span)/s + 1
+		case PaddingSame:
+			p.out[i] = (in[i] + s - 1) / s
+			if total := (p.out[i]-1)*s + span - in[i]; total > 0 {
+				before = total / 2
+			}
+		case PaddingFull:
+			before = span - 1
+			p.out[i] = (in[i]+span-2)/s + 1
+		case PaddingExplicit:
+			Require(len(opts.Pad) == dc,
+				"Convolve: PaddingExplicit needs %d pads but got %d\n", dc, len(opts.Pad))
+			before = opts.Pad[i][0]
+			p.out[i] = (in[i]+opts.Pad[i][0]+opts.Pad[i][1]-span)/s + 1
+		default:
+			panic("Convolve: unknown padding")
+		}
+		if p.out[i] < 0 {
+			p.out[i] = 0
+		}
+		p.stride[i], p.dilation[i], p.before[i] = s, d, before
+		idx := make([]int, p.out[i]*filter[i])
+		for o := 0; o < p.out[i]; o++ {
+			for k := 0; k < filter[i]; k++ {
+				idx[o*filter[i]+k] = boundary(o*s+k*d-before, in[i], opts.Boundary)
+			}
+		}
+		p.index[i] = idx
+	}
+	return p
+}
+
+// boundary maps a position of the padded input to a position of the
+// input of size n, or -1 for a zero.
+func boundary(pos, n int, b Boundary) int {
+	if pos >= 0 && pos < n {
+		return pos
+	}
+	switch b {
+	case BoundaryReplicate:
+		if pos < 0 {
+			return 0
+		}
+		return n - 1
+	case BoundaryReflect:
+		if n == 1 {
+			return 0
+		}
+		period := 2 * (n - 1)
+		pos %= period
+		if pos < 0 {
+			pos += period
+		}
+		if pos >= n {
+			pos = period - pos
+		}
+		return pos
+	default:
+		return -1
+	}
+}
+
+// each calls f for every output element o, in column-major order,
+// with the filter elements ks and the input elements js that it
+// multiplies. Indices are flat column-major offsets; ks accounts for
+// the flip of the filter.
+func (p *convPlan) each(f func(o int, ks, js []int)) {
+	dc := len(p.in)
+	osize, fsize := p.sizes()
+	if osize == 0 {
+		return
+	}
+	step := columnMajor(p.in)
+	oidx := make([]int, dc)
+	kidx := make([]int, dc)
+	ks := make([]int, fsize)
+	js := make([]int, fsize)
+	for o := 0; o < osize; o++ {
+		n := 0
+		for k := 0; k < fsize; k++ {
+			j := 0
+			inside := true
+			for i := 0; i < dc; i++ {
+				pos := p.index[i][oidx[i]*p.filter[i]+kidx[i]]
+				if pos < 0 {
+					inside = false
+					break
+				}
+				j += pos * step[i]
+			}
+			if inside {
+				ks[n] = k
+				if p.flip {
+					ks[n] = fsize - 1 - k
+				}
+				js[n] = j
+				n++
+			}
+			for i := 0; i < dc; i++ {
+				kidx[i]++
+				if kidx[i] < p.filter[i] {
+					break
+				}
+				kidx[i] = 0
+			}
+		}
+		f(o, ks[:n], js[:n])
+		for i := 0; i < dc; i++ {
+			oidx[i]++
+			if oidx[i] < p.out[i] {
+				break
+			}
+			oidx[i] = 0
+		}
+	}
+}
+
+// sizes returns the number of output and filter elements.
+func (p *convPlan) sizes() (osize, fsize int) {
+	osize, fsize = 1, 1
+	for i := range p.in {
+		osize *= p.out[i]
+		fsize *= p.filter[i]
+	}
+	return
+}
+
+// ConvOutDims returns the dims of the output of ConvolveWith for an
+// input and a filter with the given dims. It cannot be used with
+// PaddingAuto, where the output dims are chosen by the caller.
+func ConvOutDims(in, filter []int, opts ConvOptions) []int {
+	Require(opts.Padding != PaddingAuto,
+		"ConvOutDims: PaddingAuto has no output dims of its own\n")
+	return newConvPlan(in, filter, nil, &opts).out
+}
+
+// channels splits the dims of a filter (or an output) into the dc
+// spatial dims and the number of channels, which is the extra last
+// dim if there is one and 1 otherwise.
+func channels(dims []int, dc int, name string) ([]int, int) {
+	if len(dims) == dc+1 {
+		return dims[:dc], dims[dc]
+	}
+	Require(len(dims) == dc,
+		"Convolve: %s must have %d or %d dims but has %d\n",
+		name, dc, dc+1, len(dims))
+	return dims, 1
+}
+
+// convSetup checks the dims of a convolution of in by filter into out
+// and returns its plan and number of channels.
+func convSetup(in, filter, out *Tensor, opts *ConvOptions) (*convPlan, int) {
+	dc := len(in.dims)
+	fdims, c := channels(filter.dims, dc, "filter")
+	var odims []int
+	if out != nil {
+		var oc int
+		odims, oc = channels(out.dims, dc, "out")
+		Require(oc == c && len(out.dims) == len(filter.dims),
+			"Convolve: filter has %d channels but out has %d\n", c, oc)
+	}
+	p := newConvPlan(in.dims, fdims, odims, opts)
+	if out != nil {
+		for i := range odims {
+			Require(odims[i] == p.out[i],
+				"Convolve: out has dims %v but the convolution gives %v\n",
+				odims, p.out)
+		}
+	}
+	return p, c
+}
+
+// ConvolveWith adds the convolution (a cross-correlation unless
+// opts.Flip is set) of in and filter to out and returns out. It
+// generalizes Convolve with per-axis strides, dilation, padding modes
+// and boundaries; Convolve(in, filter, out, flip, stride) is
+// ConvolveWith(in, filter, out, ConvOptions{Stride: []int{stride},
+// Flip: flip}).
+//
+// filter and out may have one more dimension than in, the last one,
+// in which case it indexes output channels: channel c of out is the
+// convolution of in with channel c of filter. If out is nil, a new
+// zero tensor of the right dims is allocated, except for PaddingAuto.
+func ConvolveWith(in, filter, out *Tensor, opts ConvOptions) *Tensor {
+	p, c := convSetup(in, filter, out, &opts)
+	if out == nil {
+		dims := append([]int{}, p.out...)
+		if len(filter.dims) > len(in.dims) {
+			dims = append(dims, c)
+		}
+		out = NewTensor(nil, dims)
+	}
+	Require(out.IsContiguous(), "Convolve: out must be contiguous\n")
+	inData, fData, outData := in.Data(), filter.Data(), out.Data()
+	osize, fsize := p.sizes()
+
+	method := opts.Method
+	if method == ConvAuto {
+		switch {
+		case fsize >= fftMin:
+			method = ConvFFT
+		case fsize*c >= im2colMin:
+			method = ConvIm2Col
+		default:
+			method = ConvDirect
+		}
+	}
+	switch method {
+	case ConvFFT:
+		p.fftConv(inData, fData, outData, c)
+	case ConvIm2Col:
+		cols := p.im2col(inData)
+		w := NewMatrix(c, fsize, fData)
+		r := NewMatrix(c, osize, nil)
+		r.mul(w, cols, false, true)
+		outData.add(r.data, 1)
+	case ConvDirect:
+		p.each(func(o int, ks, js []int) {
+			for ch := 0; ch < c; ch++ {
+				w := fData[ch*fsize : (ch+1)*fsize]
+				val := 0.0
+				for t, k := range ks {
+					val += inData[js[t]] * w[k]
+				}
+				outData[ch*osize+o] += val
+			}
+		})
+	default:
+		panic("ConvolveWith: unknown method")
+	}
+	return out
+}
+
+// fftConv adds the convolution of in by the c channels of filter to
+// out using FFTs. Along each axis the input is padded (with its
+// boundary) to the L = (out-1)*stride + span elements that the output
+// reads, and the filter is spread by its dilation, so that the
+// cross-correlation sum_k x[y+k]*w[k] for y = o*stride is a circular
+// one of any length n >= L without wrap-around.
+func (p *convPlan) fftConv(in, filter, out Vector, c int) {
+	dc := len(p.in)
+	osize, fsize := p.sizes()
+	if osize == 0 {
+		return
+	}
+	n := make([]int, dc)
+	size := 1
+	pos := make([][]int, dc) // input position of each padded position
+	for i := range n {
+		span := p.dilation[i]*(p.filter[i]-1) + 1
+		l := (p.out[i]-1)*p.stride[i] + span
+		pos[i] = make([]int, l)
+		for x := range pos[i] {
+			pos[i][x] = boundary(x-p.before[i], p.in[i], p.boundary)
+		}
+		n[i] = 1
+		for n[i] < l {
+			n[i] <<= 1
+		}
+		size *= n[i]
+	}
+	nstep, step := columnMajor(n), columnMajor(p.in)
+
+	x := make([]complex128, size)
+	box := make([]int, dc)
+	for i := range box {
+		box[i] = len(pos[i])
+	}
+	eachIndex(box, func(idx []int) {
+		j, f := 0, 0
+		for i, v := range idx {
+			if pos[i][v] < 0 {
+				return
+			}
+			j += pos[i][v] * step[i]
+			f += v * nstep[i]
+		}
+		x[f] = complex(in[j], 0)
+	})
+	fftn(x, n, false)
+
+	w := make([]complex128, size)
+	for ch := 0; ch < c; ch++ {
+		fw := filter[ch*fsize : (ch+1)*fsize]
+		for i := range w {
+			w[i] = 0
+		}
+		k := 0
+		eachIndex(p.filter, func(idx []int) {
+			f := 0
+			for i, v := range idx {
+				f += v * p.dilation[i] * nstep[i]
+			}
+			if p.flip {
+				w[f] = complex(fw[fsize-1-k], 0)
+			} else {
+				w[f] = complex(fw[k], 0)
+			}
+			k++
+		})
+		fftn(w, n, false)
+		for i := range w {
+			w[i] = x[i] * cmplx.Conj(w[i])
+		}
+		fftn(w, n, true)
+		o := ch * osize
+		eachIndex(p.out, func(idx []int) {
+			f := 0
+			for i, v := range idx {
+				f += v * p.stride[i] * nstep[i]
+			}
+			out[o] += real(w[f]) / float64(size)
+			o++
+		})
+	}
+}
+
+// eachIndex calls f with every multi-index of an array with the given
+// dims, in column-major order.
+func eachIndex(dims []int, f func(idx []int)) {
+	for _, d := range dims {
+		if d == 0 {
+			return
+		}
+	}
+	idx := make([]int, len(dims))
+	for {
+		f(idx)
+		i := 0
+		for ; i < len(dims); i++ {
+			idx[i]++
+			if idx[i] < dims[i] {
+				break
+			}
+			idx[i] = 0
+		}
+		if i == len(dims) {
+			return
+		}
+	}
+}
+
+// im2col returns the matrix whose row o holds the input elements
+// multiplied by the filter elements for output element o.
+func (p *convPlan) im2col(in Vector) *Matrix {
+	osize, fsize := p.sizes()
+	cols := NewMatrix(osize, fsize, nil)
+	p.each(func(o int, ks, js []int) {
+		row := cols.matrix[o]
+		for t, k := range ks {
+			row[k] = in[js[t]]
+		}
+	})
+	return cols
+}
+
+// Im2Col returns the patches of in read by a filter with the given
+// dims, one row per output element of ConvolveWith in column-major
+// order and one column per filter element. Padding zeros are stored
+// as 0. The convolution with a filter f is then the product of the
+// result and f.Data(). PaddingAuto cannot be used.
+func Im2Col(in *Tensor, filterDims []int, opts ConvOptions) *Matrix {
+	Require(opts.Padding != PaddingAuto,
+		"Im2Col: PaddingAuto has no output dims of its own\n")
+	return newConvPlan(in.dims, filterDims, nil, &opts).im2col(in.Data())
+}
+
+// ConvTranspose adds to inGrad the transpose of ConvolveWith applied
+// to outGrad: every output element of the convolution of inGrad by
+// filter spreads its value back to the input elements it read. This is
+// the backpropagation of ConvolveWith to its input. With
+// BoundaryReflect or BoundaryReplicate an input element can be read
+// several times and receives every contribution. inGrad must be
+// contiguous.
+func ConvTranspose(outGrad, filter, inGrad *Tensor, opts ConvOptions) *Tensor {
+	p, c := convSetup(inGrad, filter, outGrad, &opts)
+	Require(inGrad.IsContiguous(), "ConvTranspose: inGrad must be contiguous\n")
+	gData, fData, inData := outGrad.Data(), filter.Data(), inGrad.Data()
+	osize, fsize := p.sizes()
+	p.each(func(o int, ks, js []int) {
+		for ch := 0; ch < c; ch++ {
+			w := fData[ch*fsize : (ch+1)*fsize]
+			g := gData[ch*osize+o]
+			for t, k := range ks {
+				inData[js[t]] += g * w[k]
+			}
+		}
+	})
+	return inGrad
+}
+
+// ConvFilterGradient adds to grad the gradient with respect to the
+// filter of ConvolveWith(in, filter, out, opts) given the gradient
+// outGrad of out. grad has the dims of the filter and must be
+// contiguous.
+func ConvFilterGradient(in, outGrad, grad *Tensor, opts ConvOptions) *Tensor {
+	p, c := convSetup(in, grad, outGrad, &opts)
+	Require(grad.IsContiguous(), "ConvFilterGradient: grad must be contiguous\n")
+	inData, gData, wData := in.Data(), outGrad.Data(), grad.Data()
+	osize, fsize := p.sizes()
+	p.each(func(o int, ks, js []int) {
+		for ch := 0; ch < c; ch++ {
+			w := wData[ch*fsize : (ch+1)*fsize]
+			g := gData[ch*osize+o]
+			for t, k := range ks {
+				w[k] += inData[js[t]] * g
+			}
+		}
+	})
+	return grad
+}
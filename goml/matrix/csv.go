@@ -0,0 +1,457 @@
+package matrix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVOptions controls how LoadCSV and SaveCSV read and write delimited
+// text. The zero value describes a comma separated file with a header
+// line, double quotes, and the usual missing value tokens.
+type CSVOptions struct {
+	// Delimiter separates the fields of a record. It defaults to ','
+	// or to '\t' if the file name ends with ".tsv" or ".tsv.gz".
+	Delimiter byte
+
+	// Quote encloses fields that contain the delimiter, the quote or
+	// a line break. A quote inside a quoted field is doubled. It
+	// defaults to '"'.
+	Quote byte
+
+	// NoHeader tells that the first record is data rather than the
+	// names of the columns. Columns are then named col_0, col_1, ...
+	NoHeader bool
+
+	// Missing lists the tokens that denote a missing value. They are
+	// loaded as UNKNOWN_VALUE. It defaults to "", "?", "NA", "N/A",
+	// "NaN" and "null". SaveCSV writes Missing[0] for UNKNOWN_VALUE,
+	// quoted if it is empty and the matrix has one column, since blank
+	// lines are skipped.
+	Missing []string
+
+	// DateFormats lists the date patterns, in Java SimpleDateFormat
+	// syntax as in ARFF, tried when inferring date columns. It
+	// defaults to "yyyy-MM-dd HH:mm:ss", "yyyy-MM-dd'T'HH:mm:ss" and
+	// "yyyy-MM-dd".
+	DateFormats []string
+
+	// MaxNominal is the maximum number of distinct values of a
+	// nominal column. Columns with more distinct values are loaded
+	// as string columns. 0 means no limit.
+	MaxNominal int
+
+	// TimeZone is the time zone used to parse dates. It defaults to
+	// DEFAULT_TIME_ZONE.
+	TimeZone string
+}
+
+// defaultCSVMissing is the default value of CSVOptions.Missing.
+var defaultCSVMissing = []string{"", "?", "NA", "N/A", "NaN", "null"}
+
+// defaultCSVDateFormats is the default value of
+// CSVOptions.DateFormats.
+var defaultCSVDateFormats = []string{"yyyy-MM-dd HH:mm:ss",
+	"yyyy-MM-dd'T'HH:mm:ss", "yyyy-MM-dd"}
+
+// withDefaults returns a copy of opts with all unset fields filled
+// in. opts may be nil.
+func (opts *CSVOptions) withDefaults(fileName string) CSVOptions {
+	var o CSVOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Delimiter == 0 {
+		o.Delimiter = ','
+		if strings.HasSuffix(fileName, ".tsv") ||
+			strings.HasSuffix(fileName, ".tsv.gz") {
+			o.Delimiter = '\t'
+		}
+	}
+	if o.Quote == 0 {
+		o.Quote = '"'
+	}
+	if o.Missing == nil {
+		o.Missing = defaultCSVMissing
+	}
+	if o.DateFormats == nil {
+		o.DateFormats = defaultCSVDateFormats
+	}
+	if o.TimeZone == "" {
+		o.TimeZone = DEFAULT_TIME_ZONE
+	}
+	return o
+}
+
+// LoadCSV loads delimited text from a file to a matrix. The type of
+// each column (real, date, nominal or string) is inferred from its
+// values. opts may be nil.
+func (m *Matrix) LoadCSV(fileName string, opts *CSVOptions) *Matrix {
+	must(m.LoadCSVErr(fileName, opts))
+	return m
+}
+
+// LoadCSVErr is like LoadCSV but returns an error instead of
+// panicking. If fileName ends with ".gz", the file is
+// gzip-decompressed.
+func (m *Matrix) LoadCSVErr(fileName string, opts *CSVOptions) error {
+	r, err := openFile(fileName)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return m.readCSV(r, fileName, opts.withDefaults(fileName))
+}
+
+// ReadCSV loads delimited text from r to the matrix. It is the stream
+// version of LoadCSVErr.
+func (m *Matrix) ReadCSV(r io.Reader, opts *CSVOptions) error {
+	return m.readCSV(r, "", opts.withDefaults(""))
+}
+
+// readCSV parses delimited text from r. fileName is only used in
+// error messages.
+func (m *Matrix) readCSV(r io.Reader, fileName string, o CSVOptions) error {
+	loc, err := ParseTimeZone(o.TimeZone)
+	if err != nil {
+		return err
+	}
+	parseError := func(line, col int, msg string, err error) error {
+		return &ParseError{File: fileName, Line: line, Column: col,
+			Msg: msg, Err: err}
+	}
+
+	// read all records
+	cr := csvReader{r: bufio.NewReader(r), delim: o.Delimiter,
+		quote: o.Quote}
+	var names []string
+	records := make([][]string, 0, EXTRA_NUM_CELL)
+	lines := make([]int, 0, EXTRA_NUM_CELL)
+	for {
+		line := cr.line + 1
+		rec, err := cr.record()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return parseError(cr.line, 0, "invalid record", err)
+		}
+		if cr.blank {
+			continue
+		}
+		if names == nil && !o.NoHeader {
+			names = rec
+			continue
+		}
+		records = append(records, rec)
+		lines = append(lines, line)
+	}
+
+	cols := len(names)
+	if o.NoHeader && len(records) > 0 {
+		cols = len(records[0])
+	}
+	if cols == 0 {
+		return parseError(cr.line, 0, "no column found", nil)
+	}
+	for i, rec := range records {
+		if len(rec) != cols {
+			return parseError(lines[i], 0, fmt.Sprintf(
+				"wrong number of fields: expected %d but get %d",
+				cols, len(rec)), nil)
+		}
+	}
+
+	missing := make(map[string]bool)
+	for _, s := range o.Missing {
+		missing[s] = true
+	}
+
+	t := NewMatrix(len(records), cols, nil)
+	t.location = loc
+	if names != nil {
+		t.ChangeAttrName(names)
+	}
+	if fileName != "" {
+		t.relation = fileName
+	}
+	for j := 0; j < cols; j++ {
+		typ, layout := inferCSVColumn(records, j, missing, o, loc)
+		switch typ {
+		case "nominal", "string":
+			values := make([]string, 0, EXTRA_NUM_CELL)
+			seen := make(map[string]bool)
+			for _, rec := range records {
+				if !missing[rec[j]] && !seen[rec[j]] {
+					seen[rec[j]] = true
+					values = append(values, rec[j])
+				}
+			}
+			sort.Strings(values)
+			if o.MaxNominal > 0 && len(values) > o.MaxNominal {
+				typ = "string"
+			}
+			for k, v := range values {
+				t.str_to_enum[j][v] = k
+				t.enum_to_str[j][k] = v
+			}
+		case "date":
+			t.enum_to_str[j][ATTR_FORMAT] = layout
+			layout, _ = JavaDateLayout(layout)
+		}
+		t.enum_to_str[j][ATTR_NAME] = typ
+
+		for i, rec := range records {
+			s := rec[j]
+			if missing[s] {
+				t.matrix[i][j] = UNKNOWN_VALUE
+				continue
+			}
+			switch typ[0] {
+			case 'n', 's': // nominal, string
+				t.matrix[i][j] = float64(t.str_to_enum[j][s])
+			case 'd': // date
+				d, err := time.ParseInLocation(layout, s, loc)
+				if err != nil {
+					return parseError(lines[i], j+1, "not a valid date", err)
+				}
+				t.matrix[i][j] = timeToUnix(d)
+			default: // real
+				v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+				if errors.Is(err, strconv.ErrSyntax) {
+					return parseError(lines[i], j+1,
+						"not a valid real number", err)
+				}
+				t.matrix[i][j] = v
+			}
+		}
+	}
+	*m = *t
+	return nil
+}
+
+// inferCSVColumn returns the type of column j of records. If the
+// column is a date, it also returns its date pattern. A column is
+// real if all its values are numbers, a date if all its values match
+// one of the date formats and nominal otherwise.
+func inferCSVColumn(records [][]string, j int, missing map[string]bool,
+	o CSVOptions, loc *time.Location) (string, string) {
+	isReal := true
+	for _, rec := range records {
+		if missing[rec[j]] {
+			continue
+		}
+		if _, err := strconv.ParseFloat(strings.TrimSpace(rec[j]), 64); errors.Is(err, strconv.ErrSyntax) {
+			isReal = false
+			break
+		}
+	}
+	if isReal {
+		return "real", ""
+	}
+
+	for _, pattern := range o.DateFormats {
+		layout, err := JavaDateLayout(pattern)
+		if err != nil {
+			continue
+		}
+		isDate := true
+		for _, rec := range records {
+			if missing[rec[j]] {
+				continue
+			}
+			if _, err := time.ParseInLocation(layout, rec[j], loc); err != nil {
+				isDate = false
+				break
+			}
+		}
+		if isDate {
+			return "date", pattern
+		}
+	}
+	return "nominal", ""
+}
+
+// SaveCSV saves the matrix to a file as delimited text. The first line
+// holds the names of the columns unless opts.NoHeader is true. opts
+// may be nil.
+func (m *Matrix) SaveCSV(fileName string, opts *CSVOptions) {
+	must(m.SaveCSVErr(fileName, opts))
+}
+
+// SaveCSVErr is like SaveCSV but returns an error instead of
+// panicking. If fileName ends with ".gz", the file is
+// gzip-compressed.
+func (m *Matrix) SaveCSVErr(fileName string, opts *CSVOptions) error {
+	w, err := createFile(fileName)
+	if err != nil {
+		return err
+	}
+	if err = m.writeCSV(w, opts.withDefaults(fileName)); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// WriteCSV writes the matrix to w as delimited text. It is the stream
+// version of SaveCSVErr.
+func (m *Matrix) WriteCSV(w io.Writer, opts *CSVOptions) error {
+	return m.writeCSV(w, opts.withDefaults(""))
+}
+
+func (m *Matrix) writeCSV(w io.Writer, o CSVOptions) error {
+	loc := m.timeLocation()
+	layouts := make([]string, m.cols)
+	for j := 0; j < m.cols; j++ {
+		if m.enum_to_str[j][ATTR_NAME][0] == 'd' {
+			var err error
+			layouts[j], err = JavaDateLayout(m.datePattern(j))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	missing := ""
+	if len(o.Missing) > 0 {
+		missing = o.Missing[0]
+	}
+	if missing == "" && m.cols == 1 {
+		// an unquoted empty record would be read as a blank line
+		missing = string([]byte{o.Quote, o.Quote})
+	}
+
+	file := bufio.NewWriter(w)
+	field := func(j int, s string) {
+		if j > 0 {
+			file.WriteByte(o.Delimiter)
+		}
+		file.WriteString(csvQuote(s, o.Delimiter, o.Quote))
+	}
+	if !o.NoHeader {
+		for j := 0; j < m.cols; j++ {
+			field(j, m.attrName[j])
+		}
+		file.WriteString("\n")
+	}
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			v := m.matrix[i][j]
+			if v == UNKNOWN_VALUE {
+				if j > 0 {
+					file.WriteByte(o.Delimiter)
+				}
+				file.WriteString(missing)
+				continue
+			}
+			switch m.enum_to_str[j][ATTR_NAME][0] {
+			case 'n', 's': // nominal, string
+				field(j, m.enum_to_str[j][int(v)])
+			case 'd': // date
+				field(j, unixToTime(v, loc).Format(layouts[j]))
+			default: // real
+				field(j, strconv.FormatFloat(v, 'g', -1, 64))
+			}
+		}
+		file.WriteString("\n")
+	}
+	return file.Flush()
+}
+
+// csvQuote quotes s if it contains the delimiter, the quote, a line
+// break, or leading or trailing spaces.
+func csvQuote(s string, delim, quote byte) string {
+	if !strings.ContainsAny(s, string([]byte{delim, quote, '\n', '\r'})) &&
+		strings.TrimSpace(s) == s {
+		return s
+	}
+	q := string(quote)
+	return q + strings.Replace(s, q, q+q, -1) + q
+}
+
+// csvReader reads records of delimited text. Quoted fields may
+// contain delimiters and line breaks; a doubled quote stands for one
+// quote.
+type csvReader struct {
+	r     *bufio.Reader
+	delim byte
+	quote byte
+	line  int  // number of lines read so far
+	blank bool // the last record was a blank line, not a quoted ""
+}
+
+// record returns the next record or io.EOF at the end of input.
+func (cr *csvReader) record() ([]string, error) {
+	rec := make([]string, 0, EXTRA_NUM_CELL)
+	var field strings.Builder
+	inQuote := false
+	quoted := false // the current field was quoted
+	started := false
+	cr.blank = false
+	for {
+		c, err := cr.r.ReadByte()
+		if err == io.EOF {
+			if inQuote {
+				return nil, errors.New("unterminated quoted field")
+			}
+			if !started {
+				return nil, io.EOF
+			}
+			cr.line++
+			s := field.String()
+			cr.blank = len(rec) == 0 && !quoted && s == ""
+			return append(rec, s), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		started = true
+		if inQuote {
+			if c == cr.quote {
+				next, err := cr.r.ReadByte()
+				if err == nil && next == cr.quote {
+					field.WriteByte(c)
+					continue
+				}
+				if err == nil {
+					cr.r.UnreadByte()
+				}
+				inQuote = false
+				continue
+			}
+			if c == '\n' {
+				cr.line++
+			}
+			field.WriteByte(c)
+			continue
+		}
+		switch {
+		case c == cr.quote && !quoted &&
+			strings.TrimSpace(field.String()) == "":
+			field.Reset()
+			inQuote = true
+			quoted = true
+		case c == cr.delim:
+			rec = append(rec, field.String())
+			field.Reset()
+			quoted = false
+		case c == '\n':
+			cr.line++
+			s := field.String()
+			if !quoted {
+				s = strings.TrimSuffix(s, "\r")
+			}
+			cr.blank = len(rec) == 0 && !quoted && s == ""
+			return append(rec, s), nil
+		case c == '\r' && quoted:
+			// line ending after a closing quote
+		default:
+			field.WriteByte(c)
+		}
+	}
+}
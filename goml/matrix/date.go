@@ -0,0 +1,172 @@
+package matrix
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DEFAULT_TIME_ZONE is the time zone used to parse and print dates
+// when no other location is given.
+const DEFAULT_TIME_ZONE = "-06:00"
+
+// ParseTimeZone returns the location described by tz. tz is either
+// a fixed offset such as "-06:00" or "+0530", or a name understood by
+// time.LoadLocation such as "UTC", "Local" or "America/Chicago".
+func ParseTimeZone(tz string) (*time.Location, error) {
+	s := strings.Replace(tz, ":", "", 1)
+	if len(s) == 5 && (s[0] == '+' || s[0] == '-') {
+		h, errH := strconv.Atoi(s[1:3])
+		mm, errM := strconv.Atoi(s[3:5])
+		if errH == nil && errM == nil && h < 24 && mm < 60 {
+			offset := h*3600 + mm*60
+			if s[0] == '-' {
+				offset = -offset
+			}
+			return time.FixedZone(tz, offset), nil
+		}
+	}
+	return time.LoadLocation(tz)
+}
+
+// defaultLocation is the location of DEFAULT_TIME_ZONE.
+var defaultLocation = time.FixedZone(DEFAULT_TIME_ZONE, -6*3600)
+
+// unixToTime converts a date stored in a matrix (seconds since the
+// Unix epoch, possibly with a fractional part) to a time.Time.
+func unixToTime(v float64, loc *time.Location) time.Time {
+	sec := math.Floor(v)
+	nsec := math.Round((v - sec) * 1e9)
+	return time.Unix(int64(sec), int64(nsec)).In(loc)
+}
+
+// timeToUnix converts t to the representation of dates in a matrix.
+func timeToUnix(t time.Time) float64 {
+	return float64(t.Unix()) + float64(t.Nanosecond())/1e9
+}
+
+// JavaDateLayout converts a Java SimpleDateFormat pattern, which is
+// what ARFF files use to describe dates, into a layout for the time
+// package. Only the pattern letters that have an equivalent in Go are
+// supported.
+func JavaDateLayout(pattern string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		if c == '\'' {
+			// quoted literal text, '' stands for a single quote
+			j := i + 1
+			for {
+				if j >= len(pattern) {
+					return "", fmt.Errorf(
+						"matrix: date pattern %q: unterminated quote", pattern)
+				}
+				if pattern[j] == '\'' {
+					if j+1 < len(pattern) && pattern[j+1] == '\'' {
+						b.WriteByte('\'')
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(pattern[j])
+				j++
+			}
+			if j == i+1 { // '' outside of a quoted text
+				b.WriteByte('\'')
+			}
+			i = j + 1
+			continue
+		}
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		n := 1
+		for i+n < len(pattern) && pattern[i+n] == c {
+			n++
+		}
+		var layout string
+		switch c {
+		case 'y':
+			layout = "2006"
+			if n == 2 {
+				layout = "06"
+			}
+		case 'M':
+			switch {
+			case n >= 4:
+				layout = "January"
+			case n == 3:
+				layout = "Jan"
+			case n == 2:
+				layout = "01"
+			default:
+				layout = "1"
+			}
+		case 'd':
+			layout = "2"
+			if n >= 2 {
+				layout = "02"
+			}
+		case 'D':
+			layout = "002"
+		case 'H':
+			layout = "15"
+		case 'h':
+			layout = "3"
+			if n >= 2 {
+				layout = "03"
+			}
+		case 'm':
+			layout = "4"
+			if n >= 2 {
+				layout = "04"
+			}
+		case 's':
+			layout = "5"
+			if n >= 2 {
+				layout = "05"
+			}
+		case 'S':
+			// Go requires fractional seconds to follow a '.' or ','.
+			s := b.String()
+			if len(s) == 0 || (s[len(s)-1] != '.' && s[len(s)-1] != ',') {
+				return "", fmt.Errorf(
+					"matrix: date pattern %q: %s", pattern,
+					"milliseconds must follow '.' or ','")
+			}
+			layout = strings.Repeat("0", n)
+		case 'a':
+			layout = "PM"
+		case 'E':
+			layout = "Mon"
+			if n >= 4 {
+				layout = "Monday"
+			}
+		case 'z':
+			layout = "MST"
+		case 'Z':
+			layout = "-0700"
+		case 'X':
+			switch n {
+			case 1:
+				layout = "Z07"
+			case 2:
+				layout = "Z0700"
+			default:
+				layout = "Z07:00"
+			}
+		default:
+			return "", fmt.Errorf(
+				"matrix: date pattern %q: letter %q is not supported",
+				pattern, c)
+		}
+		b.WriteString(layout)
+		i += n
+	}
+	return b.String(), nil
+}
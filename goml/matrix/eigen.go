@@ -0,0 +1,100 @@
+package matrix
+
+import (
+	"math"
+	"sort"
+)
+
+// EigenSym is the eigendecomposition A = VDV* of a symmetric matrix A,
+// where D is diagonal and V is orthogonal.
+type EigenSym struct {
+	values  Vector
+	vectors *Matrix
+}
+
+// NewEigenSym computes the eigenvalues and eigenvectors of the
+// symmetric matrix a using the cyclic Jacobi method. It returns
+// ErrNotSymmetric if a is not symmetric. The matrix a is not modified.
+func NewEigenSym(a *Matrix) (*EigenSym, error) {
+	if a.rows != a.cols {
+		return nil, ErrNotSquare
+	}
+	if !isSymmetric(a) {
+		return nil, ErrNotSymmetric
+	}
+	n := a.rows
+	d := a.clone()
+	v := identity(n) // rows are the eigenvectors
+
+	converged := false
+	for sweep := 0; sweep < maxSweeps && !converged; sweep++ {
+		off, diag := 0.0, 0.0
+		for i := 0; i < n; i++ {
+			diag += d.matrix[i][i] * d.matrix[i][i]
+			for j := i + 1; j < n; j++ {
+				off += d.matrix[i][j] * d.matrix[i][j]
+			}
+		}
+		if off <= ESP*ESP*diag || off == 0 {
+			converged = true
+			break
+		}
+		for p := 0; p < n-1; p++ {
+			for q := p + 1; q < n; q++ {
+				apq := d.matrix[p][q]
+				if apq == 0 {
+					continue
+				}
+				theta := (d.matrix[q][q] - d.matrix[p][p]) / (2 * apq)
+				t := 1 / (math.Abs(theta) + math.Sqrt(1+theta*theta))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(1+t*t)
+				s := c * t
+				// D = J*DJ where J rotates columns p and q
+				rotate(d.matrix[p], d.matrix[q], c, s)
+				for i := 0; i < n; i++ {
+					dip, diq := d.matrix[i][p], d.matrix[i][q]
+					d.matrix[i][p] = c*dip - s*diq
+					d.matrix[i][q] = s*dip + c*diq
+				}
+				d.matrix[p][q], d.matrix[q][p] = 0, 0
+				rotate(v.matrix[p], v.matrix[q], c, s)
+			}
+		}
+	}
+	if !converged {
+		return nil, ErrNoConvergence
+	}
+
+	// eigenvalues in increasing order
+	order := make(valuesort, n)
+	for i := 0; i < n; i++ {
+		order[i].val = d.matrix[i][i]
+		order[i].idx = i
+	}
+	sort.Stable(sort.Reverse(order))
+
+	f := &EigenSym{values: make(Vector, n), vectors: NewMatrix(n, n, nil)}
+	for k := 0; k < n; k++ {
+		f.values[k] = order[k].val
+		vk := v.matrix[order[k].idx]
+		for i := 0; i < n; i++ {
+			f.vectors.matrix[i][k] = vk[i]
+		}
+	}
+	return f, nil
+}
+
+// Values returns the eigenvalues in increasing order.
+func (f *EigenSym) Values() Vector {
+	v := make(Vector, len(f.values))
+	copy(v, f.values)
+	return v
+}
+
+// Vectors returns the eigenvectors as columns, in the order of Values.
+func (f *EigenSym) Vectors() *Matrix {
+	return f.vectors.clone()
+}
@@ -0,0 +1,117 @@
+package matrix
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the matrix decompositions.
+var (
+	ErrNotSquare           = errors.New("matrix: matrix is not square")
+	ErrSingular            = errors.New("matrix: matrix is singular")
+	ErrNotSymmetric        = errors.New("matrix: matrix is not symmetric")
+	ErrNotPositiveDefinite = errors.New("matrix: matrix is not positive definite")
+	ErrNoConvergence       = errors.New("matrix: iteration did not converge")
+)
+
+// DimensionError is returned when the operands of an operation do not
+// have compatible sizes.
+type DimensionError struct {
+	Op         string // name of the operation, e.g. "Mul"
+	Rows, Cols int    // size of the first operand
+	R, C       int    // size of the second operand
+}
+
+func (e *DimensionError) Error() string {
+	return fmt.Sprintf("matrix: %s: dimension mismatched: %d-by-%d and %d-by-%d",
+		e.Op, e.Rows, e.Cols, e.R, e.C)
+}
+
+// ParseError is returned when a data file cannot be parsed. Line and
+// Column are 1-based; Column is the index of the attribute (field) in
+// the row and is 0 when the error is not about a particular field.
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+	Err    error // underlying error, if any
+}
+
+func (e *ParseError) Error() string {
+	file := e.File
+	if file == "" {
+		file = "<input>"
+	}
+	s := fmt.Sprintf("matrix: %s:%d", file, e.Line)
+	if e.Column > 0 {
+		s += fmt.Sprintf(":%d", e.Column)
+	}
+	s += ": " + e.Msg
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownColumnError is returned when a column statistic is requested
+// but every value in the column is UNKNOWN_VALUE.
+type UnknownColumnError struct {
+	Op   string
+	Col  int
+	Name string
+}
+
+func (e *UnknownColumnError) Error() string {
+	return fmt.Sprintf("matrix: %s: all data in column %d (%s) are unknown",
+		e.Op, e.Col, e.Name)
+}
+
+// IndexError is returned when a row or column index is out of bound.
+type IndexError struct {
+	Op    string
+	Index int
+	Len   int
+}
+
+func (e *IndexError) Error() string {
+	return fmt.Sprintf("matrix: %s: index %d out of bound [0, %d)",
+		e.Op, e.Index, e.Len)
+}
+
+// must panics with the message of err if err is not nil. It is used
+// by the panicking forms of the functions that also have an
+// error-returning form.
+func must(err error) {
+	if err != nil {
+		panic(err.Error() + "\n")
+	}
+}
+
+// ColumnNameError is returned when no column has the requested name.
+type ColumnNameError struct {
+	Op   string
+	Name string
+}
+
+func (e *ColumnNameError) Error() string {
+	return fmt.Sprintf("matrix: %s: no column named %q", e.Op, e.Name)
+}
+
+// ColumnTypeError is returned when columns that are combined hold
+// different types of data, e.g. a nominal and a real column.
+type ColumnTypeError struct {
+	Op          string
+	Col         int
+	Name        string
+	Type, Other string
+}
+
+func (e *ColumnTypeError) Error() string {
+	return fmt.Sprintf("matrix: %s: column %d (%s) is %s but %s is expected",
+		e.Op, e.Col, e.Name, e.Other, e.Type)
+}
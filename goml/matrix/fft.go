@@ -0,0 +1,296 @@
+package matrix
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// maxRadix is the largest prime factor handled by the mixed-radix FFT.
+// Lengths with a larger prime factor use Bluestein's algorithm.
+const maxRadix = 31
+
+// FFT returns the discrete Fourier transform of x,
+//
+//	X[k] = sum_j x[j] exp(-2*pi*i*j*k/n),
+//
+// for any length n. Powers of two use an iterative radix-2 FFT, lengths
+// whose prime factors are small use a mixed-radix FFT, and other
+// lengths use Bluestein's algorithm, so the cost is O(n log n) in all
+// cases.
+func FFT(x []complex128) []complex128 {
+	y := make([]complex128, len(x))
+	copy(y, x)
+	fft(y, false)
+	return y
+}
+
+// IFFT returns the inverse discrete Fourier transform of x, so that
+// IFFT(FFT(x)) is x up to rounding.
+func IFFT(x []complex128) []complex128 {
+	y := make([]complex128, len(x))
+	copy(y, x)
+	fft(y, true)
+	scale := complex(1/float64(len(y)), 0)
+	for i := range y {
+		y[i] *= scale
+	}
+	return y
+}
+
+// FFT returns the discrete Fourier transform of the real vector v.
+func (v Vector) FFT() []complex128 {
+	return FFT(toComplex(v))
+}
+
+// IFFTReal returns the real part of IFFT(x), e.g. to get back a real
+// signal from its spectrum.
+func IFFTReal(x []complex128) Vector {
+	y := IFFT(x)
+	v := make(Vector, len(y))
+	for i, c := range y {
+		v[i] = real(c)
+	}
+	return v
+}
+
+func toComplex(v Vector) []complex128 {
+	c := make([]complex128, len(v))
+	for i, x := range v {
+		c[i] = complex(x, 0)
+	}
+	return c
+}
+
+// fft computes the transform of x in place, without the 1/n of the
+// inverse.
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	switch {
+	case n <= 1:
+	case n&(n-1) == 0:
+		radix2(x, inverse)
+	case largestFactor(n) <= maxRadix:
+		mixedRadix(x, inverse)
+	default:
+		bluestein(x, inverse)
+	}
+}
+
+// twiddle returns exp(sign*2*pi*i*k/n) with sign = -1 for the forward
+// transform.
+func twiddle(k, n int, inverse bool) complex128 {
+	a := -2 * math.Pi * float64(k%n) / float64(n)
+	if inverse {
+		a = -a
+	}
+	s, c := math.Sincos(a)
+	return complex(c, s)
+}
+
+// radix2 is the iterative Cooley-Tukey FFT for a power-of-two length.
+func radix2(x []complex128, inverse bool) {
+	n := len(x)
+	// bit reversal permutation
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	w := make([]complex128, n/2)
+	for k := range w {
+		w[k] = twiddle(k, n, inverse)
+	}
+	for size := 2; size <= n; size <<= 1 {
+		half := size / 2
+		step := n / size
+		for start := 0; start < n; start += size {
+			for k := 0; k < half; k++ {
+				t := w[k*step] * x[start+k+half]
+				x[start+k+half] = x[start+k] - t
+				x[start+k] += t
+			}
+		}
+	}
+}
+
+// mixedRadix is a recursive decimation-in-time FFT that splits off the
+// smallest prime factor p of the length: the p subsequences x[r::p]
+// are transformed and then combined with DFTs of length p.
+func mixedRadix(x []complex128, inverse bool) {
+	n := len(x)
+	p := smallestFactor(n)
+	m := n / p
+	sub := make([]complex128, n)
+	for r := 0; r < p; r++ {
+		s := sub[r*m : (r+1)*m]
+		for j := range s {
+			s[j] = x[j*p+r]
+		}
+		fft(s, inverse)
+	}
+	for k := 0; k < m; k++ {
+		for q := 0; q < p; q++ {
+			idx := k + m*q
+			v := sub[k]
+			for r := 1; r < p; r++ {
+				v += twiddle(r*idx, n, inverse) * sub[r*m+k]
+			}
+			x[idx] = v
+		}
+	}
+}
+
+// bluestein computes a DFT of any length as a circular convolution of
+// power-of-two length, using nk = (n^2 + k^2 - (k-n)^2)/2.
+func bluestein(x []complex128, inverse bool) {
+	n := len(x)
+	m := 1
+	for m < 2*n-1 {
+		m <<= 1
+	}
+	// chirp[k] = exp(-i*pi*k^2/n), with k^2 reduced mod 2n for accuracy
+	chirp := make([]complex128, n)
+	for k := range chirp {
+		chirp[k] = twiddle(k*k%(2*n), 2*n, inverse)
+	}
+	a := make([]complex128, m)
+	b := make([]complex128, m)
+	for k := 0; k < n; k++ {
+		a[k] = x[k] * chirp[k]
+	}
+	b[0] = cmplx.Conj(chirp[0])
+	for k := 1; k < n; k++ {
+		b[k] = cmplx.Conj(chirp[k])
+		b[m-k] = b[k]
+	}
+	radix2(a, false)
+	radix2(b, false)
+	for i := range a {
+		a[i] *= b[i]
+	}
+	radix2(a, true)
+	scale := complex(1/float64(m), 0)
+	for k := 0; k < n; k++ {
+		x[k] = a[k] * scale * chirp[k]
+	}
+}
+
+func smallestFactor(n int) int {
+	for p := 2; p*p <= n; p++ {
+		if n%p == 0 {
+			return p
+		}
+	}
+	return n
+}
+
+func largestFactor(n int) int {
+	f := 1
+	for n > 1 {
+		p := smallestFactor(n)
+		f = p
+		for n%p == 0 {
+			n /= p
+		}
+	}
+	return f
+}
+
+// FFTN returns the N-dimensional discrete Fourier transform of x, an
+// array with the given dims in column-major order as a Tensor.
+func FFTN(x []complex128, dims []int) []complex128 {
+	y := make([]complex128, len(x))
+	copy(y, x)
+	fftn(y, dims, false)
+	return y
+}
+
+// IFFTN returns the N-dimensional inverse discrete Fourier transform
+// of x.
+func IFFTN(x []complex128, dims []int) []complex128 {
+	y := make([]complex128, len(x))
+	copy(y, x)
+	fftn(y, dims, true)
+	scale := complex(1/float64(len(y)), 0)
+	for i := range y {
+		y[i] *= scale
+	}
+	return y
+}
+
+// FFT returns the N-dimensional discrete Fourier transform of the
+// tensor, in column-major order (see FFTN).
+func (t *Tensor) FFT() []complex128 {
+	return FFTN(toComplex(t.Data()), t.dims)
+}
+
+// fftn transforms x in place along every axis.
+func fftn(x []complex128, dims []int, inverse bool) {
+	size := 1
+	for _, d := range dims {
+		size *= d
+	}
+	Require(size == len(x),
+		"FFTN: size mismatched: dims (%d) != data (%d)\n", size, len(x))
+	step := 1
+	for _, d := range dims {
+		if d > 1 {
+			line := make([]complex128, d)
+			// every line along this axis starts at an offset whose
+			// index along the axis is 0
+			for start := 0; start < size; start++ {
+				if (start/step)%d != 0 {
+					continue
+				}
+				for i := range line {
+					line[i] = x[start+i*step]
+				}
+				fft(line, inverse)
+				for i := range line {
+					x[start+i*step] = line[i]
+				}
+			}
+		}
+		step *= d
+	}
+}
+
+// PowerSpectrum returns the one-sided power spectrum of the real
+// signal v: P[k] for the frequencies k = 0, ..., n/2 of FFTFreq. The
+// powers of the frequencies that appear twice in the full spectrum are
+// doubled, so that P sums to the energy sum_j v[j]^2. Peaks of P show
+// the periods of the signal, e.g. to choose the frequencies of a
+// LayerSinusoidal.
+func PowerSpectrum(v Vector) Vector {
+	n := len(v)
+	if n == 0 {
+		return Vector{}
+	}
+	X := v.FFT()
+	p := make(Vector, n/2+1)
+	for k := range p {
+		a := cmplx.Abs(X[k])
+		p[k] = a * a / float64(n)
+		if k != 0 && 2*k != n {
+			p[k] *= 2
+		}
+	}
+	return p
+}
+
+// FFTFreq returns the frequencies of the n/2+1 bins of PowerSpectrum
+// for a signal of length n sampled every d units, in cycles per unit:
+// bin k is at k/(n*d). A sinusoid of frequency f completes f cycles per
+// unit, i.e. it is sin(2*pi*f*t).
+func FFTFreq(n int, d float64) Vector {
+	f := make(Vector, n/2+1)
+	for k := range f {
+		f[k] = float64(k) / (float64(n) * d)
+	}
+	return f
+}
@@ -0,0 +1,57 @@
+package matrix
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// gzipFile closes both the gzip stream and the underlying file.
+type gzipFile struct {
+	io.Reader
+	io.Writer
+	z io.Closer
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	err := g.z.Close()
+	if e := g.f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// openFile opens fileName for reading. If fileName ends with ".gz",
+// the content is transparently gzip-decompressed.
+func openFile(fileName string) (io.ReadCloser, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(fileName, ".gz") {
+		return f, nil
+	}
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipFile{Reader: z, z: z, f: f}, nil
+}
+
+// createFile creates fileName for writing. If fileName ends with
+// ".gz", the content is transparently gzip-compressed. The returned
+// writer must be closed to flush its content.
+func createFile(fileName string) (io.WriteCloser, error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(fileName, ".gz") {
+		return f, nil
+	}
+	z := gzip.NewWriter(f)
+	return &gzipFile{Writer: z, z: z, f: f}, nil
+}
@@ -0,0 +1,280 @@
+package matrix
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// UNKNOWN_VALUE32 is UNKNOWN_VALUE in single precision. UNKNOWN_VALUE
+// itself is out of the range of a float32.
+const UNKNOWN_VALUE32 = float32(-math.MaxFloat32)
+
+// Vector32 is a Vector stored in single precision. It takes half the
+// memory and bandwidth of a Vector. Reductions such as Dot accumulate
+// in float64.
+type Vector32 []float32
+
+// NewVector32 wraps a vector around vals.
+func NewVector32(size int, vals []float32) Vector32 {
+	Require(len(vals) == 0 || len(vals) == size,
+		"NewVector32: require len(vals) == 0 || len(vals) == size\n")
+	if len(vals) > 0 {
+		return vals
+	}
+	return make(Vector32, size)
+}
+
+// ToFloat32 returns a single precision copy of v.
+func (v Vector) ToFloat32() Vector32 {
+	s := make(Vector32, len(v))
+	for i, x := range v {
+		s[i] = to32(x)
+	}
+	return s
+}
+
+// ToFloat64 returns a double precision copy of v.
+func (v Vector32) ToFloat64() Vector {
+	s := make(Vector, len(v))
+	for i, x := range v {
+		s[i] = to64(x)
+	}
+	return s
+}
+
+func to32(x float64) float32 {
+	if x == UNKNOWN_VALUE {
+		return UNKNOWN_VALUE32
+	}
+	return float32(x)
+}
+
+func to64(x float32) float64 {
+	if x == UNKNOWN_VALUE32 {
+		return UNKNOWN_VALUE
+	}
+	return float64(x)
+}
+
+// String converts a Vector32 into a string.
+func (v Vector32) String() string {
+	var buf bytes.Buffer
+	buf.Grow(len(v)*15 + 3 + 2)
+	fmt.Fprintf(&buf, "\n[\n")
+	for i := 0; i < len(v); i++ {
+		fmt.Fprintf(&buf, " %14.7e\n", v[i])
+	}
+	fmt.Fprintf(&buf, "]\n")
+	return buf.String()
+}
+
+// Dot returns the dot product of two vectors, accumulated in float64.
+func (a Vector32) Dot(b Vector32) float64 {
+	Require(len(a) == len(b),
+		"Dot: dimension mismatch: len(a) == len(b)\n")
+	return dot32(a, b)
+}
+
+func dot32(a, b Vector32) float64 {
+	b = b[:len(a)]
+	d := float64(0)
+	for i, x := range a {
+		d += float64(x) * float64(b[i])
+	}
+	return d
+}
+
+// Fill fills a vector with val.
+func (v Vector32) Fill(val float32) Vector32 {
+	for i := range v {
+		v[i] = val
+	}
+	return v
+}
+
+// Scale scales a vector.
+func (v Vector32) Scale(c float64) Vector32 {
+	for i := range v {
+		v[i] = float32(float64(v[i]) * c)
+	}
+	return v
+}
+
+// Matrix32 is a Matrix stored in single precision, e.g. to hold a large
+// training set in half the memory. It shares the metadata of the
+// Matrix it was converted from, so converting it back with ToFloat64
+// restores the column names and types. Dates lose precision: a float32
+// resolves a Unix time to about two minutes.
+type Matrix32 struct {
+	data        Vector32
+	matrix      []Vector32
+	rows, cols  int
+	relation    string
+	attrName    []string
+	str_to_enum []map[string]int
+	enum_to_str []map[int]string
+}
+
+// NewMatrix32 creates a matrix of size rows*cols or wraps a matrix
+// around a slice val. All columns are real.
+func NewMatrix32(rows, cols int, val []float32) *Matrix32 {
+	Require(cols > 0, "%s %d%s%d\n",
+		"NewMatrix32: cannot generate an empty matrix of size",
+		rows, "-by-", cols)
+	Require(len(val) == 0 || len(val) == rows*cols,
+		"NewMatrix32: require len(val) = 0 || len(val) = rows*cols\n")
+	meta := NewMatrix(0, cols, nil)
+	m := &Matrix32{rows: rows, cols: cols, relation: meta.relation,
+		attrName: meta.attrName, str_to_enum: meta.str_to_enum,
+		enum_to_str: meta.enum_to_str}
+	m.data = NewVector32(rows*cols, val)
+	m.matrix = make([]Vector32, rows)
+	for i := range m.matrix {
+		m.matrix[i] = m.data[i*cols : (i+1)*cols]
+	}
+	return m
+}
+
+// ToFloat32 returns a single precision copy of m. Unknown values are
+// stored as UNKNOWN_VALUE32.
+func (m *Matrix) ToFloat32() *Matrix32 {
+	s := NewMatrix32(m.rows, m.cols, nil)
+	s.relation = m.relation
+	s.attrName = m.attrName
+	s.str_to_enum = m.str_to_enum
+	s.enum_to_str = m.enum_to_str
+	for i := 0; i < m.rows; i++ {
+		r := s.matrix[i]
+		for j, x := range m.matrix[i] {
+			r[j] = to32(x)
+		}
+	}
+	return s
+}
+
+// ToFloat64 returns a double precision copy of m with the metadata
+// of m.
+func (m *Matrix32) ToFloat64() *Matrix {
+	d := NewMatrix(m.rows, m.cols, nil)
+	d.relation = m.relation
+	d.attrName = m.attrName
+	d.str_to_enum = m.str_to_enum
+	d.enum_to_str = m.enum_to_str
+	for i := 0; i < m.rows; i++ {
+		r := d.matrix[i]
+		for j, x := range m.matrix[i] {
+			r[j] = to64(x)
+		}
+	}
+	return d
+}
+
+// Rows returns the number of rows of a matrix.
+func (m *Matrix32) Rows() int {
+	return m.rows
+}
+
+// Cols returns the number of columns of a matrix.
+func (m *Matrix32) Cols() int {
+	return m.cols
+}
+
+// Size returns the numbers of rows and columns of a matrix.
+func (m *Matrix32) Size() (r int, c int) {
+	return m.rows, m.cols
+}
+
+// Row returns row i of m. It shares the storage of m.
+func (m *Matrix32) Row(i int) Vector32 {
+	Require(i >= 0 && i < m.rows,
+		"Row: index out of bound: r = %d\n", i)
+	return m.matrix[i]
+}
+
+// GetElem returns the element at row r and column c.
+func (m *Matrix32) GetElem(r, c int) float32 {
+	return m.matrix[r][c]
+}
+
+// SetElem sets the element at row i and column j.
+func (m *Matrix32) SetElem(i, j int, val float32) {
+	m.matrix[i][j] = val
+}
+
+// GetAttrName returns the name of column col.
+func (m *Matrix32) GetAttrName(col int) string {
+	return m.attrName[col]
+}
+
+// Mul stores the product of the two matrices in the receiver, as
+// Matrix.Mul. Every element is accumulated in float64 and rounded once.
+func (m *Matrix32) Mul(a, b *Matrix32, aTranspose, bTranspose bool) *Matrix32 {
+	must(m.MulErr(a, b, aTranspose, bTranspose))
+	return m
+}
+
+// MulErr is like Mul but returns a *DimensionError instead of
+// panicking when the inner dimensions of the product do not agree.
+func (m *Matrix32) MulErr(a, b *Matrix32, aTranspose, bTranspose bool) error {
+	cols, rows := a.cols, b.rows
+	mRows, mCols := a.rows, b.cols
+	if aTranspose {
+		cols, mRows = mRows, cols
+	}
+	if bTranspose {
+		rows, mCols = mCols, rows
+	}
+	if cols != rows {
+		return &DimensionError{Op: "Mul", Rows: mRows, Cols: cols,
+			R: rows, C: mCols}
+	}
+	t := m
+	if m == a || m == b || m.rows != mRows || m.cols != mCols {
+		t = NewMatrix32(mRows, mCols, nil)
+	}
+	inner := cols
+	parallelRows(mRows, mRows*mCols*inner, func(i0, i1 int) {
+		acc := make(Vector, mCols)
+		col := make(Vector32, inner)
+		for i := i0; i < i1; i++ {
+			acc.Fill(0)
+			switch {
+			case !aTranspose && !bTranspose:
+				for k, x := range a.matrix[i] {
+					xk := float64(x)
+					for j, y := range b.matrix[k] {
+						acc[j] += xk * float64(y)
+					}
+				}
+			case aTranspose && !bTranspose:
+				for k := 0; k < inner; k++ {
+					xk := float64(a.matrix[k][i])
+					for j, y := range b.matrix[k] {
+						acc[j] += xk * float64(y)
+					}
+				}
+			default:
+				ai := col
+				if aTranspose {
+					for k := range col {
+						col[k] = a.matrix[k][i]
+					}
+				} else {
+					ai = a.matrix[i]
+				}
+				for j := range acc {
+					acc[j] = dot32(ai, b.matrix[j])
+				}
+			}
+			r := t.matrix[i]
+			for j, x := range acc {
+				r[j] = float32(x)
+			}
+		}
+	})
+	if t != m {
+		*m = *t
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package matrix
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TableStyle selects the layout of WriteTable.
+type TableStyle int
+
+const (
+	// StylePlain is an aligned text table framed by lines of = and -,
+	// with the column types above the column names.
+	StylePlain TableStyle = iota
+
+	// StyleMarkdown is a GitHub-flavored Markdown table.
+	StyleMarkdown
+
+	// StyleHTML is an HTML table.
+	StyleHTML
+)
+
+// PrintOptions holds the options of WriteTable.
+type PrintOptions struct {
+	// MaxRows and MaxCols bound the numbers of rows and columns shown:
+	// the first and last ones are shown and the middle ones are elided
+	// with "...". Zero means no bound.
+	MaxRows, MaxCols int
+
+	// Precision is the number of significant digits of real values;
+	// zero means 6.
+	Precision int
+
+	// Width is the minimum width of a column of a plain table.
+	Width int
+
+	Style TableStyle
+}
+
+// DefaultPrintOptions are the options of String.
+var DefaultPrintOptions = PrintOptions{MaxRows: 20, MaxCols: 10}
+
+// String converts a Matrix into a plain table with DefaultPrintOptions
+// so that it can be printed using fmt.Printf("%v\n", matrix). Large
+// matrices are truncated; see Format and WriteTable to show more.
+func (m *Matrix) String() string {
+	var buf bytes.Buffer
+	m.WriteTable(&buf, DefaultPrintOptions)
+	return buf.String()
+}
+
+// Format implements fmt.Formatter for the verbs %v and %s, which print
+// the table of String. The + flag prints every row and column, the
+// precision sets the significant digits of real values and the width
+// the minimum width of a column: fmt.Printf("%+12.4v", m) prints all
+// of m with 4 digits in columns at least 12 wide.
+func (m *Matrix) Format(f fmt.State, verb rune) {
+	if verb != 'v' && verb != 's' {
+		fmt.Fprintf(f, "%%!%c(*matrix.Matrix=%d-by-%d)", verb, m.rows, m.cols)
+		return
+	}
+	opts := DefaultPrintOptions
+	if f.Flag('+') {
+		opts.MaxRows, opts.MaxCols = 0, 0
+	}
+	if p, ok := f.Precision(); ok {
+		opts.Precision = p
+	}
+	if w, ok := f.Width(); ok {
+		opts.Width = w
+	}
+	m.WriteTable(f, opts)
+}
+
+// table holds the text of the shown part of a matrix.
+type table struct {
+	names, types []string
+	right        []bool     // right-align the column (numbers and dates)
+	cells        [][]string // nil for an elided row
+	footer       string
+}
+
+// WriteTable writes m as a table with the attribute names and types.
+// Nominal and string values are printed as strings, dates with
+// TIME_FORMAT in the location of m and unknown values as "?".
+func (m *Matrix) WriteTable(w io.Writer, opts PrintOptions) error {
+	t := m.table(opts)
+	var buf bytes.Buffer
+	switch opts.Style {
+	case StylePlain:
+		t.plain(&buf, opts.Width)
+	case StyleMarkdown:
+		t.markdown(&buf)
+	case StyleHTML:
+		t.html(&buf, m.relation)
+	default:
+		return fmt.Errorf("WriteTable: unknown style %d", opts.Style)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// shown returns the indices of the items shown out of n, with -1 in
+// place of the elided ones.
+func shown(n, max int) []int {
+	idx := make([]int, 0, n)
+	if max <= 0 || n <= max {
+		for i := 0; i < n; i++ {
+			idx = append(idx, i)
+		}
+		return idx
+	}
+	head := (max + 1) / 2
+	for i := 0; i < head; i++ {
+		idx = append(idx, i)
+	}
+	idx = append(idx, -1)
+	for i := n - (max - head); i < n; i++ {
+		idx = append(idx, i)
+	}
+	return idx
+}
+
+func (m *Matrix) table(opts PrintOptions) *table {
+	prec := opts.Precision
+	if prec <= 0 {
+		prec = 6
+	}
+	rows, cols := shown(m.rows, opts.MaxRows), shown(m.cols, opts.MaxCols)
+	t := &table{}
+	for _, j := range cols {
+		if j < 0 {
+			t.names = append(t.names, "...")
+			t.types = append(t.types, "")
+			t.right = append(t.right, false)
+			continue
+		}
+		t.names = append(t.names, m.attrName[j])
+		t.types = append(t.types, m.enum_to_str[j][ATTR_NAME])
+		t.right = append(t.right, !m.isNominal(j))
+	}
+	for _, i := range rows {
+		if i < 0 {
+			t.cells = append(t.cells, nil)
+			continue
+		}
+		r := make([]string, len(cols))
+		for k, j := range cols {
+			if j < 0 {
+				r[k] = "..."
+			} else {
+				r[k] = m.cell(i, j, prec)
+			}
+		}
+		t.cells = append(t.cells, r)
+	}
+	if len(rows) != m.rows || len(cols) != m.cols {
+		t.footer = fmt.Sprintf("[%d rows x %d columns]", m.rows, m.cols)
+	}
+	return t
+}
+
+// cell returns the text of the element at row i and column j.
+func (m *Matrix) cell(i, j, prec int) string {
+	v := m.matrix[i][j]
+	switch {
+	case v == UNKNOWN_VALUE:
+		return "?"
+	case m.isNominal(j):
+		if s, ok := m.enum_to_str[j][int(v)]; ok {
+			return s
+		}
+		return strconv.Itoa(int(v))
+	case strings.HasPrefix(m.enum_to_str[j][ATTR_NAME], "d"):
+		return unixToTime(v, m.timeLocation()).Format(TIME_FORMAT)
+	}
+	return strconv.FormatFloat(v, 'g', prec, 64)
+}
+
+func (t *table) plain(buf *bytes.Buffer, minWidth int) {
+	width := make([]int, len(t.names))
+	total := 0
+	for k := range width {
+		width[k] = minWidth
+		for _, s := range []string{t.names[k], t.types[k]} {
+			if n := utf8.RuneCountInString(s); n > width[k] {
+				width[k] = n
+			}
+		}
+		for _, r := range t.cells {
+			if r != nil {
+				if n := utf8.RuneCountInString(r[k]); n > width[k] {
+					width[k] = n
+				}
+			}
+		}
+		total += width[k] + 1
+	}
+	line := func(s []string) {
+		for k, x := range s {
+			if t.right[k] {
+				fmt.Fprintf(buf, " %*s", width[k], x)
+			} else {
+				fmt.Fprintf(buf, " %-*s", width[k], x)
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	heline := strings.Repeat("=", total)
+	fmt.Fprintf(buf, "\n%s\n", heline)
+	line(t.types)
+	line(t.names)
+	fmt.Fprintf(buf, "%s\n", strings.Repeat("-", total))
+	elided := make([]string, len(t.names))
+	for k := range elided {
+		elided[k] = "..."
+	}
+	for _, r := range t.cells {
+		if r == nil {
+			r = elided
+		}
+		line(r)
+	}
+	fmt.Fprintf(buf, "%s\n", heline)
+	if t.footer != "" {
+		fmt.Fprintf(buf, "%s\n", t.footer)
+	}
+}
+
+func (t *table) markdown(buf *bytes.Buffer) {
+	esc := strings.NewReplacer("|", "\\|", "\n", " ")
+	buf.WriteByte('|')
+	for k, name := range t.names {
+		if t.types[k] != "" {
+			name += " (" + t.types[k] + ")"
+		}
+		fmt.Fprintf(buf, " %s |", esc.Replace(name))
+	}
+	buf.WriteString("\n|")
+	for _, right := range t.right {
+		if right {
+			buf.WriteString(" ---: |")
+		} else {
+			buf.WriteString(" --- |")
+		}
+	}
+	buf.WriteByte('\n')
+	for _, r := range t.cells {
+		buf.WriteByte('|')
+		for k := range t.names {
+			x := "..."
+			if r != nil {
+				x = esc.Replace(r[k])
+			}
+			fmt.Fprintf(buf, " %s |", x)
+		}
+		buf.WriteByte('\n')
+	}
+	if t.footer != "" {
+		fmt.Fprintf(buf, "\n%s\n", t.footer)
+	}
+}
+
+func (t *table) html(buf *bytes.Buffer, relation string) {
+	buf.WriteString("<table>\n")
+	if caption := strings.TrimSpace(relation + " " + t.footer); caption != "" {
+		fmt.Fprintf(buf, "<caption>%s</caption>\n", html.EscapeString(caption))
+	}
+	align := func(k int) string {
+		if t.right[k] {
+			return ` style="text-align:right"`
+		}
+		return ""
+	}
+	buf.WriteString("<thead>\n<tr>")
+	for k, name := range t.names {
+		fmt.Fprintf(buf, "<th%s>%s</th>", align(k), html.EscapeString(name))
+	}
+	buf.WriteString("</tr>\n<tr>")
+	for k, typ := range t.types {
+		fmt.Fprintf(buf, "<th%s><small>%s</small></th>", align(k), html.EscapeString(typ))
+	}
+	buf.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for _, r := range t.cells {
+		buf.WriteString("<tr>")
+		for k := range t.names {
+			x := "..."
+			if r != nil {
+				x = r[k]
+			}
+			fmt.Fprintf(buf, "<td%s>%s</td>", align(k), html.EscapeString(x))
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</tbody>\n</table>\n")
+}
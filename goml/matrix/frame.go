@@ -0,0 +1,408 @@
+package matrix
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ColIndex returns the index of the first column named name, or -1 if
+// there is none.
+func (m *Matrix) ColIndex(name string) int {
+	for j, s := range m.attrName {
+		if s == name {
+			return j
+		}
+	}
+	return -1
+}
+
+// colIndices returns the indices of the columns with the given names.
+func (m *Matrix) colIndices(op string, names []string) ([]int, error) {
+	cols := make([]int, len(names))
+	for k, name := range names {
+		if cols[k] = m.ColIndex(name); cols[k] < 0 {
+			return nil, &ColumnNameError{Op: op, Name: name}
+		}
+	}
+	return cols, nil
+}
+
+// copyColumn copies the name and the type of column j of a, including
+// its nominal values, to column k of m.
+func (m *Matrix) copyColumn(k int, a *Matrix, j int) {
+	m.attrName[k] = a.attrName[j]
+	m.str_to_enum[k] = make(map[string]int, len(a.str_to_enum[j]))
+	m.enum_to_str[k] = make(map[int]string, len(a.enum_to_str[j]))
+	for s, e := range a.str_to_enum[j] {
+		m.str_to_enum[k][s] = e
+	}
+	for e, s := range a.enum_to_str[j] {
+		m.enum_to_str[k][e] = s
+	}
+}
+
+// HConcat returns the matrix made of the columns of ms side by side,
+// with their names and types. All matrices must have the same number
+// of rows. The relation, time zone and weights are those of the first
+// matrix (the first one with weights for the weights).
+func HConcat(ms ...*Matrix) *Matrix {
+	m, err := HConcatErr(ms...)
+	must(err)
+	return m
+}
+
+// HConcatErr is like HConcat but returns a *DimensionError instead of
+// panicking when the numbers of rows differ.
+func HConcatErr(ms ...*Matrix) (*Matrix, error) {
+	Require(len(ms) > 0, "HConcat: no matrix to concatenate\n")
+	rows, cols := ms[0].rows, 0
+	for _, a := range ms {
+		if a.rows != rows {
+			return nil, &DimensionError{Op: "HConcat", Rows: ms[0].rows,
+				Cols: ms[0].cols, R: a.rows, C: a.cols}
+		}
+		cols += a.cols
+	}
+	c := NewMatrix(rows, cols, nil)
+	c.relation, c.location = ms[0].relation, ms[0].location
+	j0 := 0
+	for _, a := range ms {
+		for i := 0; i < rows; i++ {
+			copy(c.matrix[i][j0:], a.matrix[i])
+		}
+		for j := 0; j < a.cols; j++ {
+			c.copyColumn(j0+j, a, j)
+		}
+		if c.weight == nil && a.weight != nil {
+			c.weight = append(Vector{}, a.weight...)
+		}
+		j0 += a.cols
+	}
+	return c, nil
+}
+
+// VConcat returns the matrix made of the rows of ms one after the
+// other. All matrices must have the same number of columns and the
+// same column types; the names are those of the first matrix.
+//
+// Nominal dictionaries are reconciled: the values of the first matrix
+// keep their codes, values first seen in a later matrix get the next
+// free codes, and the data of every matrix are recoded so
+// that a value has the same code throughout the result. If some
+// matrices have weights, the rows of the others get weight 1.
+func VConcat(ms ...*Matrix) *Matrix {
+	m, err := VConcatErr(ms...)
+	must(err)
+	return m
+}
+
+// VConcatErr is like VConcat but returns a *DimensionError or a
+// *ColumnTypeError instead of panicking.
+func VConcatErr(ms ...*Matrix) (*Matrix, error) {
+	Require(len(ms) > 0, "VConcat: no matrix to concatenate\n")
+	first := ms[0]
+	rows, weighted := 0, false
+	for _, a := range ms {
+		if a.cols != first.cols {
+			return nil, &DimensionError{Op: "VConcat", Rows: first.rows,
+				Cols: first.cols, R: a.rows, C: a.cols}
+		}
+		for j := 0; j < a.cols; j++ {
+			if t := a.GetAttrType(j); t != first.GetAttrType(j) {
+				return nil, &ColumnTypeError{Op: "VConcat", Col: j,
+					Name: a.attrName[j], Type: first.GetAttrType(j), Other: t}
+			}
+		}
+		rows += a.rows
+		weighted = weighted || a.weight != nil
+	}
+	c := NewMatrix(rows, first.cols, nil)
+	c.relation, c.location = first.relation, first.location
+	for j := 0; j < c.cols; j++ {
+		c.copyColumn(j, first, j)
+	}
+	if weighted {
+		c.weight = make(Vector, rows)
+	}
+	r := 0
+	for _, a := range ms {
+		recode := make([]map[int]int, a.cols)
+		for j := 0; j < a.cols; j++ {
+			if a != first && c.isNominal(j) {
+				recode[j] = c.mergeValues(j, a, j)
+			}
+		}
+		for i := 0; i < a.rows; i++ {
+			row := c.matrix[r+i]
+			copy(row, a.matrix[i])
+			for j, codes := range recode {
+				if codes == nil || row[j] == UNKNOWN_VALUE {
+					continue
+				}
+				if e, ok := codes[int(row[j])]; ok {
+					row[j] = float64(e)
+				}
+			}
+			if weighted {
+				c.weight[r+i] = 1
+				if a.weight != nil {
+					c.weight[r+i] = a.weight[i]
+				}
+			}
+		}
+		r += a.rows
+	}
+	return c, nil
+}
+
+// mergeValues adds the nominal values of column j of a that column k
+// of m lacks and returns the map from the codes of a to those of m.
+func (m *Matrix) mergeValues(k int, a *Matrix, j int) map[int]int {
+	next := 0
+	for _, e := range m.str_to_enum[k] {
+		if e >= next {
+			next = e + 1
+		}
+	}
+	// add new values in the order of their codes in a
+	codes := make([]int, 0, len(a.str_to_enum[j]))
+	for _, e := range a.str_to_enum[j] {
+		codes = append(codes, e)
+	}
+	sort.Ints(codes)
+	recode := make(map[int]int, len(codes))
+	for _, e := range codes {
+		s := a.enum_to_str[j][e]
+		code, ok := m.str_to_enum[k][s]
+		if !ok {
+			code = next
+			next++
+			m.str_to_enum[k][s] = code
+			m.enum_to_str[k][code] = s
+		}
+		recode[e] = code
+	}
+	return recode
+}
+
+// Filter returns the rows of m for which keep returns true, in their
+// order. The result is a view as in RowView; use Clone for a copy.
+func (m *Matrix) Filter(keep func(row Vector) bool) *Matrix {
+	var rows []int
+	for i := 0; i < m.rows; i++ {
+		if keep(m.matrix[i]) {
+			rows = append(rows, i)
+		}
+	}
+	return m.RowView(rows)
+}
+
+// SelectCols returns a copy of the columns of m with the given names,
+// in that order, with their metadata and the weights of m.
+func (m *Matrix) SelectCols(names ...string) *Matrix {
+	s, err := m.SelectColsErr(names...)
+	must(err)
+	return s
+}
+
+// SelectColsErr is like SelectCols but returns a *ColumnNameError
+// instead of panicking when a column does not exist.
+func (m *Matrix) SelectColsErr(names ...string) (*Matrix, error) {
+	cols, err := m.colIndices("SelectCols", names)
+	if err != nil {
+		return nil, err
+	}
+	return m.View(nil, cols).Clone(), nil
+}
+
+// less orders two values of column j: nominal and string values by
+// name, other values by number, and unknown values last.
+func (m *Matrix) less(j int, x, y float64) bool {
+	switch {
+	case x == UNKNOWN_VALUE:
+		return false
+	case y == UNKNOWN_VALUE:
+		return true
+	case m.isNominal(j):
+		return m.enum_to_str[j][int(x)] < m.enum_to_str[j][int(y)]
+	}
+	return x < y
+}
+
+// SortBy sorts the rows of m (and their weights) by column col in
+// place and returns m. Nominal values are sorted by name and unknown
+// values come last in either order. The sort is stable, so sorting by
+// a secondary column first and then by the primary column sorts by
+// both.
+func (m *Matrix) SortBy(col int, descending bool) *Matrix {
+	Require(col >= 0 && col < m.cols,
+		"SortBy: index out of bound: c = %d\n", col)
+	order := Range(0, m.rows, 1)
+	sort.SliceStable(order, func(a, b int) bool {
+		x, y := m.matrix[order[a]][col], m.matrix[order[b]][col]
+		if descending && x != UNKNOWN_VALUE && y != UNKNOWN_VALUE {
+			x, y = y, x
+		}
+		return m.less(col, x, y)
+	})
+	rows := make([]Vector, m.rows)
+	for k, i := range order {
+		rows[k] = m.matrix[i]
+	}
+	copy(m.matrix, rows)
+	if m.weight != nil {
+		w := make(Vector, m.rows)
+		for k, i := range order {
+			w[k] = m.weight[i]
+		}
+		m.weight = w
+	}
+	return m
+}
+
+// Agg is an aggregation of GroupBy. Every aggregation skips unknown
+// values.
+type Agg int
+
+const (
+	AggCount  Agg = iota // number of known values
+	AggSum               // sum
+	AggMean              // mean
+	AggMin               // minimum
+	AggMax               // maximum
+	AggStd               // sample standard deviation
+	AggMedian            // median
+)
+
+var aggNames = [...]string{"count", "sum", "mean", "min", "max", "std", "median"}
+
+func (a Agg) String() string {
+	if a < 0 || int(a) >= len(aggNames) {
+		return fmt.Sprintf("Agg(%d)", int(a))
+	}
+	return aggNames[a]
+}
+
+// Aggregation asks GroupBy for the aggregate Agg of the column named
+// Col in every group.
+type Aggregation struct {
+	Col string
+	Agg Agg
+}
+
+// GroupBy groups the rows of m by the values of the key columns and
+// returns one row per group, in order of first appearance: the key
+// columns, with their metadata, followed by one real column per
+// aggregation named after the column and the aggregation, e.g.
+// "price_mean". Unknown keys form groups of their own. Aggregates of
+// groups without known values are UNKNOWN_VALUE, except for AggCount
+// and AggSum which are 0. Weights are ignored.
+func (m *Matrix) GroupBy(keys []string, aggs ...Aggregation) *Matrix {
+	g, err := m.GroupByErr(keys, aggs...)
+	must(err)
+	return g
+}
+
+// GroupByErr is like GroupBy but returns a *ColumnNameError or a
+// *ColumnTypeError instead of panicking. Nominal and string columns
+// can only be counted.
+func (m *Matrix) GroupByErr(keys []string, aggs ...Aggregation) (*Matrix, error) {
+	kcols, err := m.colIndices("GroupBy", keys)
+	if err != nil {
+		return nil, err
+	}
+	acols := make([]int, len(aggs))
+	for k, a := range aggs {
+		j := m.ColIndex(a.Col)
+		if j < 0 {
+			return nil, &ColumnNameError{Op: "GroupBy", Name: a.Col}
+		}
+		if a.Agg != AggCount && m.isNominal(j) {
+			return nil, &ColumnTypeError{Op: "GroupBy", Col: j,
+				Name: a.Col, Type: "real", Other: m.GetAttrType(j)}
+		}
+		Require(a.Agg >= AggCount && a.Agg <= AggMedian,
+			"GroupBy: unknown aggregation %d\n", int(a.Agg))
+		acols[k] = j
+	}
+
+	// group the rows
+	var groups [][]int
+	index := make(map[string]int)
+	var key strings.Builder
+	for i := 0; i < m.rows; i++ {
+		key.Reset()
+		for _, j := range kcols {
+			fmt.Fprintf(&key, "%x,", math.Float64bits(m.matrix[i][j]))
+		}
+		g, ok := index[key.String()]
+		if !ok {
+			g = len(groups)
+			index[key.String()] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	cols := len(kcols) + len(aggs)
+	Require(cols > 0, "GroupBy: no key and no aggregation\n")
+	r := NewMatrix(len(groups), cols, nil)
+	r.relation, r.location = m.relation, m.location
+	for k, j := range kcols {
+		r.copyColumn(k, m, j)
+	}
+	for k, a := range aggs {
+		r.attrName[len(kcols)+k] = a.Col + "_" + a.Agg.String()
+	}
+	values := make([]float64, 0, m.rows)
+	for g, rows := range groups {
+		out := r.matrix[g]
+		for k, j := range kcols {
+			out[k] = m.matrix[rows[0]][j]
+		}
+		for k, a := range aggs {
+			values = values[:0]
+			var mo moments
+			for _, i := range rows {
+				if x := m.matrix[i][acols[k]]; x != UNKNOWN_VALUE {
+					values = append(values, x)
+					mo.add(x)
+				}
+			}
+			out[len(kcols)+k] = aggregate(a.Agg, &mo, values)
+		}
+	}
+	return r, nil
+}
+
+// aggregate returns the aggregate of the known values of a group and
+// their moments; values may be sorted in place.
+func aggregate(a Agg, mo *moments, values []float64) float64 {
+	switch a {
+	case AggCount:
+		return float64(mo.n)
+	case AggSum:
+		sum := 0.0
+		for _, x := range values {
+			sum += x
+		}
+		return sum
+	}
+	if mo.n == 0 || (a == AggStd && mo.n < 2) {
+		return UNKNOWN_VALUE
+	}
+	switch a {
+	case AggMean:
+		return mo.mean
+	case AggMin:
+		return mo.min
+	case AggMax:
+		return mo.max
+	case AggStd:
+		return math.Sqrt(mo.m2 / float64(mo.n-1))
+	}
+	sort.Float64s(values)
+	return quantile(values, 0.5)
+}
@@ -0,0 +1,153 @@
+package matrix
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// LoadIDX loads a tensor from a file in the IDX format, which is the
+// format of the MNIST database. If fileName ends with ".gz", the file
+// is gzip-decompressed, so the files can be used as downloaded.
+//
+// IDX stores its data in row-major order so the dimensions of the
+// returned tensor are those of the file in reverse order. For
+// example, train-images-idx3-ubyte (60000x28x28) gives a tensor of
+// dimensions [28, 28, 60000] and ToMatrix gives a 60000-by-784
+// matrix with one image per row.
+func LoadIDX(fileName string) (*Tensor, error) {
+	r, err := openFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	t, err := ReadIDX(r)
+	if err != nil {
+		return nil, fmt.Errorf("matrix: LoadIDX: %s: %v", fileName, err)
+	}
+	return t, nil
+}
+
+// ReadIDX reads a tensor in the IDX format from r. See LoadIDX.
+func ReadIDX(r io.Reader) (*Tensor, error) {
+	br := bufio.NewReader(r)
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic[0] != 0 || magic[1] != 0 {
+		return nil, errors.New("not an IDX file")
+	}
+	ndims := int(magic[3])
+	shape := make([]int, ndims)
+	for i := 0; i < ndims; i++ {
+		var d uint32
+		if err := binary.Read(br, binary.BigEndian, &d); err != nil {
+			return nil, err
+		}
+		shape[i] = int(d)
+	}
+	size, err := elemCount(shape)
+	if err != nil {
+		return nil, err
+	}
+
+	var width int
+	var conv func(b []byte) float64
+	switch magic[2] {
+	case 0x08: // unsigned byte
+		width = 1
+		conv = func(b []byte) float64 { return float64(b[0]) }
+	case 0x09: // signed byte
+		width = 1
+		conv = func(b []byte) float64 { return float64(int8(b[0])) }
+	case 0x0B: // short
+		width = 2
+		conv = func(b []byte) float64 {
+			return float64(int16(binary.BigEndian.Uint16(b)))
+		}
+	case 0x0C: // int
+		width = 4
+		conv = func(b []byte) float64 {
+			return float64(int32(binary.BigEndian.Uint32(b)))
+		}
+	case 0x0D: // float
+		width = 4
+		conv = func(b []byte) float64 {
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+		}
+	case 0x0E: // double
+		width = 8
+		conv = func(b []byte) float64 {
+			return math.Float64frombits(binary.BigEndian.Uint64(b))
+		}
+	default:
+		return nil, fmt.Errorf("unknown IDX data type 0x%02x", magic[2])
+	}
+
+	data, err := readElems(br, size, width, conv)
+	if err != nil {
+		return nil, err
+	}
+
+	dims := make([]int, ndims)
+	for i := 0; i < ndims; i++ {
+		dims[i] = shape[ndims-1-i]
+	}
+	return NewTensor(data, dims), nil
+}
+
+// maxElems bounds the number of elements of an array read from a file,
+// so that its size in bytes fits an int.
+const maxElems = math.MaxInt / 8
+
+// elemCount returns the number of elements of an array of the given
+// shape, or an error if a dimension is negative or the count is too
+// large.
+func elemCount(shape []int) (int, error) {
+	size := 1
+	for _, d := range shape {
+		if d < 0 {
+			return 0, fmt.Errorf("negative dimension in shape %v", shape)
+		}
+		if d > 0 && size > maxElems/d {
+			return 0, fmt.Errorf("shape %v is too large", shape)
+		}
+		size *= d
+	}
+	return size, nil
+}
+
+// readElems reads size elements of width bytes from r, decoding them
+// with conv. The result grows as the elements arrive, so that a header
+// announcing more elements than the input holds fails at the end of
+// the input instead of allocating memory for all of them.
+func readElems(r io.Reader, size, width int, conv func(b []byte) float64) (Vector, error) {
+	const chunk = 4096
+	c := size
+	if c > 16*chunk {
+		c = 16 * chunk
+	}
+	data := make(Vector, 0, c)
+	buf := make([]byte, width*chunk)
+	for i := 0; i < size; {
+		n := size - i
+		if n > chunk {
+			n = chunk
+		}
+		if _, err := io.ReadFull(r, buf[:n*width]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("%d of %d elements: %v", i, size, err)
+		}
+		for k := 0; k < n; k++ {
+			data = append(data, conv(buf[k*width:(k+1)*width]))
+		}
+		i += n
+	}
+	return data, nil
+}
@@ -0,0 +1,377 @@
+package matrix
+
+import (
+	"math"
+)
+
+// LinearOperator is a matrix that is only known through its products
+// with vectors. *Matrix and *SparseMatrix implement it; an implicit
+// operator, e.g. a design matrix with standardized columns, can
+// implement it without ever storing the matrix.
+type LinearOperator interface {
+	Size() (rows, cols int)
+	MulVec(x Vector) Vector      // returns A*x
+	MulTransVec(x Vector) Vector // returns A^T*x
+}
+
+// MulVec returns m*x.
+func (m *Matrix) MulVec(x Vector) Vector {
+	Require(len(x) == m.cols,
+		"MulVec: dimension mismatch: %d != %d\n", len(x), m.cols)
+	y := make(Vector, m.rows)
+	parallelRows(m.rows, m.rows*m.cols, func(i0, i1 int) {
+		for i := i0; i < i1; i++ {
+			y[i] = dot(m.matrix[i][:m.cols], x)
+		}
+	})
+	return y
+}
+
+// MulTransVec returns m^T*x.
+func (m *Matrix) MulTransVec(x Vector) Vector {
+	Require(len(x) == m.rows,
+		"MulTransVec: dimension mismatch: %d != %d\n", len(x), m.rows)
+	y := make(Vector, m.cols)
+	for i, xi := range x {
+		if xi != 0 {
+			axpy(xi, m.matrix[i][:m.cols], y)
+		}
+	}
+	return y
+}
+
+// MulTransVec returns s^T*x.
+func (s *SparseMatrix) MulTransVec(x Vector) Vector {
+	Require(len(x) == s.rows,
+		"SparseMatrix.MulTransVec: dimension mismatch: %d != %d\n", len(x), s.rows)
+	y := make(Vector, s.cols)
+	s.DoNonZero(func(i, j int, v float64) {
+		y[j] += v * x[i]
+	})
+	return y
+}
+
+// Preconditioner returns an approximation of A^-1*r for PCG. A good
+// preconditioner is cheap to apply and makes A^-1 close to the identity.
+type Preconditioner func(r Vector) Vector
+
+// JacobiPreconditioner returns the preconditioner that divides by the
+// diagonal of A, which is often enough for badly scaled features.
+// Zero elements of diag are treated as 1.
+func JacobiPreconditioner(diag Vector) Preconditioner {
+	inv := make(Vector, len(diag))
+	for i, d := range diag {
+		inv[i] = 1
+		if d != 0 {
+			inv[i] = 1 / d
+		}
+	}
+	return func(r Vector) Vector {
+		z := make(Vector, len(r))
+		for i, x := range r {
+			z[i] = x * inv[i]
+		}
+		return z
+	}
+}
+
+// IterOptions holds the options of the iterative solvers. The zero
+// value uses the defaults.
+type IterOptions struct {
+	// MaxIter bounds the number of iterations; 0 means 2*n for CG and
+	// 4*n for LSQR and LSMR, where n is the number of unknowns.
+	MaxIter int
+
+	// Tol is the relative tolerance; 0 means 1e-10. CG and PCG stop
+	// when |b - A*x| <= Tol*|b|; LSQR and LSMR also stop when
+	// |A^T*r| <= Tol*|A|*|r|, which is the test for inconsistent
+	// least squares problems.
+	Tol float64
+
+	// X0 is the initial guess of CG and PCG; nil means 0.
+	X0 Vector
+
+	// Damp is the Tikhonov regularization of LSQR and LSMR: they
+	// minimize |A*x - b|^2 + Damp^2*|x|^2.
+	Damp float64
+}
+
+// IterResult is the result of an iterative solver.
+type IterResult struct {
+	X         Vector
+	Iter      int     // number of iterations
+	Residual  float64 // |b - A*x|, with the Damp term for LSQR and LSMR
+	Converged bool
+}
+
+func (o *IterOptions) defaults(n, factor int) (int, float64) {
+	maxIter, tol := factor*n, 1e-10
+	if o != nil && o.MaxIter > 0 {
+		maxIter = o.MaxIter
+	}
+	if o != nil && o.Tol > 0 {
+		tol = o.Tol
+	}
+	return maxIter, tol
+}
+
+// CG solves A*x = b by the conjugate gradient method, for a symmetric
+// positive definite A, e.g. the normal equations X^T*X of a fit. Each
+// iteration costs one product with A. If the method does not converge
+// within MaxIter iterations, the result so far is returned with
+// ErrNoConvergence. ErrNotPositiveDefinite is returned if A turns out
+// not to be positive definite. opts may be nil.
+func CG(a LinearOperator, b Vector, opts *IterOptions) (*IterResult, error) {
+	return PCG(a, b, nil, opts)
+}
+
+// PCG is CG preconditioned by m, which should approximate A^-1 and be
+// symmetric positive definite. A nil m is the identity.
+func PCG(a LinearOperator, b Vector, m Preconditioner, opts *IterOptions) (*IterResult, error) {
+	rows, cols := a.Size()
+	if rows != cols || rows != len(b) {
+		return nil, &DimensionError{Op: "PCG", Rows: rows, Cols: cols,
+			R: len(b), C: 1}
+	}
+	if m == nil {
+		m = func(r Vector) Vector { return append(Vector{}, r...) }
+	}
+	maxIter, tol := opts.defaults(cols, 2)
+	res := &IterResult{X: make(Vector, cols)}
+	r := append(Vector{}, b...)
+	if opts != nil && opts.X0 != nil {
+		Require(len(opts.X0) == cols,
+			"PCG: X0 has %d elements but A has %d columns\n", len(opts.X0), cols)
+		copy(res.X, opts.X0)
+		axpy(-1, a.MulVec(res.X), r)
+	}
+	bnorm := b.Norm(2)
+	if bnorm == 0 {
+		bnorm = 1
+	}
+	res.Residual = r.Norm(2)
+	if res.Residual <= tol*bnorm {
+		res.Converged = true
+		return res, nil
+	}
+	z := m(r)
+	p := append(Vector{}, z...)
+	rz := dot(r, z)
+	for res.Iter < maxIter {
+		res.Iter++
+		ap := a.MulVec(p)
+		pap := dot(p, ap)
+		if pap <= 0 {
+			return res, ErrNotPositiveDefinite
+		}
+		alpha := rz / pap
+		axpy(alpha, p, res.X)
+		axpy(-alpha, ap, r)
+		res.Residual = r.Norm(2)
+		if res.Residual <= tol*bnorm {
+			res.Converged = true
+			return res, nil
+		}
+		z = m(r)
+		rzNew := dot(r, z)
+		beta := rzNew / rz
+		rz = rzNew
+		for i := range p {
+			p[i] = z[i] + beta*p[i]
+		}
+	}
+	return res, ErrNoConvergence
+}
+
+// normalize scales v to unit norm and returns its norm.
+func normalize(v Vector) float64 {
+	n := v.Norm(2)
+	if n > 0 {
+		v.Scale(1 / n)
+	}
+	return n
+}
+
+// rotation returns the Givens rotation (c, s) and r = sqrt(a^2 + b^2)
+// such that [c s; -s c]*[a; b] = [r; 0].
+func rotation(a, b float64) (c, s, r float64) {
+	r = math.Hypot(a, b)
+	if r == 0 {
+		return 1, 0, 0
+	}
+	return a / r, b / r, r
+}
+
+// LSQR solves the least squares problem min |A*x - b|^2 +
+// Damp^2*|x|^2 for any A by the method of Paige and Saunders, which is
+// CG on the normal equations without forming A^T*A. Each iteration
+// costs one product with A and one with A^T, so it scales to large
+// sparse or implicit design matrices where LeastSquare is infeasible.
+// The columns of A should be scaled to similar norms. opts may be nil;
+// X0 is ignored.
+func LSQR(a LinearOperator, b Vector, opts *IterOptions) (*IterResult, error) {
+	rows, cols := a.Size()
+	if rows != len(b) {
+		return nil, &DimensionError{Op: "LSQR", Rows: rows, Cols: cols,
+			R: len(b), C: 1}
+	}
+	maxIter, tol := opts.defaults(cols, 4)
+	damp := 0.0
+	if opts != nil {
+		damp = opts.Damp
+	}
+	res := &IterResult{X: make(Vector, cols)}
+	u := append(Vector{}, b...)
+	beta := normalize(u)
+	bnorm := beta
+	v := a.MulTransVec(u)
+	alpha := normalize(v)
+	res.Residual = beta
+	if alpha*beta == 0 {
+		res.Converged = true
+		return res, nil
+	}
+	w := append(Vector{}, v...)
+	phibar, rhobar := beta, alpha
+	// anorm2 estimates |A|_F^2 and res2 accumulates the damping part
+	// of |r|^2
+	anorm2, res2 := 0.0, 0.0
+	for res.Iter < maxIter {
+		res.Iter++
+		// bidiagonalization
+		u.Scale(-alpha)
+		u.add(a.MulVec(v), 1)
+		beta = normalize(u)
+		anorm2 += alpha*alpha + beta*beta + damp*damp
+		v.Scale(-beta)
+		v.add(a.MulTransVec(u), 1)
+		alpha = normalize(v)
+
+		// eliminate the damping, then the subdiagonal
+		c1, s1, rhobar1 := rotation(rhobar, damp)
+		psi := s1 * phibar
+		res2 += psi * psi
+		phibar *= c1
+		c, s, rho := rotation(rhobar1, beta)
+		theta := s * alpha
+		rhobar = -c * alpha
+		phi := c * phibar
+		phibar *= s
+
+		axpy(phi/rho, w, res.X)
+		for i := range w {
+			w[i] = v[i] - theta/rho*w[i]
+		}
+
+		// |r| and |A^T*r| of the damped problem
+		rnorm := math.Sqrt(phibar*phibar + res2)
+		arnorm := math.Abs(phibar * alpha * c)
+		res.Residual = rnorm
+		if rnorm <= tol*bnorm || arnorm <= tol*math.Sqrt(anorm2)*rnorm {
+			res.Converged = true
+			return res, nil
+		}
+	}
+	return res, ErrNoConvergence
+}
+
+// LSMR solves the same problem as LSQR by the method of Fong and
+// Saunders, which is MINRES on the normal equations: |A^T*r| decreases
+// monotonically, so it can be stopped early more safely than LSQR
+// when A is ill-conditioned. opts may be nil; X0 is ignored.
+func LSMR(a LinearOperator, b Vector, opts *IterOptions) (*IterResult, error) {
+	rows, cols := a.Size()
+	if rows != len(b) {
+		return nil, &DimensionError{Op: "LSMR", Rows: rows, Cols: cols,
+			R: len(b), C: 1}
+	}
+	maxIter, tol := opts.defaults(cols, 4)
+	damp := 0.0
+	if opts != nil {
+		damp = opts.Damp
+	}
+	res := &IterResult{X: make(Vector, cols)}
+	u := append(Vector{}, b...)
+	beta := normalize(u)
+	bnorm := beta
+	v := a.MulTransVec(u)
+	alpha := normalize(v)
+	res.Residual = beta
+	if alpha*beta == 0 {
+		res.Converged = true
+		return res, nil
+	}
+
+	zetabar, alphabar := alpha*beta, alpha
+	rho, rhobar, cbar, sbar := 1.0, 1.0, 1.0, 0.0
+	h := append(Vector{}, v...)
+	hbar := make(Vector, cols)
+
+	// estimation of |r|
+	betadd, betad := beta, 0.0
+	rhodold, tautildeold, thetatilde, zeta, d := 1.0, 0.0, 0.0, 0.0, 0.0
+	anorm2 := alpha * alpha
+
+	for res.Iter < maxIter {
+		res.Iter++
+		// bidiagonalization
+		u.Scale(-alpha)
+		u.add(a.MulVec(v), 1)
+		beta = normalize(u)
+		if beta > 0 {
+			v.Scale(-beta)
+			v.add(a.MulTransVec(u), 1)
+			alpha = normalize(v)
+		}
+
+		// rotations
+		chat, shat, alphahat := rotation(alphabar, damp)
+		rhoold := rho
+		var c, s float64
+		c, s, rho = rotation(alphahat, beta)
+		thetanew := s * alpha
+		alphabar = c * alpha
+		rhobarold, zetaold := rhobar, zeta
+		thetabar := sbar * rho
+		cbar, sbar, rhobar = rotation(cbar*rho, thetanew)
+		zeta = cbar * zetabar
+		zetabar = -sbar * zetabar
+
+		// update h, hbar and x
+		f := thetabar * rho / (rhoold * rhobarold)
+		for i := range hbar {
+			hbar[i] = h[i] - f*hbar[i]
+		}
+		axpy(zeta/(rho*rhobar), hbar, res.X)
+		f = thetanew / rho
+		for i := range h {
+			h[i] = v[i] - f*h[i]
+		}
+
+		// estimate |r|
+		betaacute := chat * betadd
+		betacheck := -shat * betadd
+		betahat := c * betaacute
+		betadd = -s * betaacute
+		thetatildeold := thetatilde
+		ctildeold, stildeold, rhotildeold := rotation(rhodold, thetabar)
+		thetatilde = stildeold * rhobar
+		rhodold = ctildeold * rhobar
+		betad = -stildeold*betad + ctildeold*betahat
+		tautildeold = (zetaold - thetatildeold*tautildeold) / rhotildeold
+		taud := (zeta - thetatilde*tautildeold) / rhodold
+		d += betacheck * betacheck
+		rnorm := math.Sqrt(d + (betad-taud)*(betad-taud) + betadd*betadd)
+
+		anorm2 += beta * beta
+		anorm := math.Sqrt(anorm2)
+		anorm2 += alpha * alpha
+		arnorm := math.Abs(zetabar)
+		res.Residual = rnorm
+		if rnorm <= tol*bnorm || arnorm <= tol*anorm*rnorm {
+			res.Converged = true
+			return res, nil
+		}
+	}
+	return res, ErrNoConvergence
+}
@@ -0,0 +1,300 @@
+package matrix
+
+import (
+	"fmt"
+	"math"
+)
+
+// LSQOptions selects the variant of least squares solved by
+// LeastSquareWith. The zero value gives plain least squares.
+type LSQOptions struct {
+	// Ridge is the Tikhonov penalty: Ridge*|x|^2 is added to the
+	// residual sum of squares. The intercept is not penalized.
+	Ridge float64
+
+	// Weights are the per-row sample weights. If nil, the instance
+	// weights of the matrix (see Weights) are used.
+	Weights Vector
+
+	// NonNegative constrains the coefficients to be non-negative
+	// (NNLS). The intercept is not constrained.
+	NonNegative bool
+
+	// Intercept adds a column of ones after the last column of the
+	// matrix, so that the intercept is the last coefficient, as in
+	// OLS and as the bias of a linear layer.
+	Intercept bool
+}
+
+// LSQResult is the result of LeastSquareWith for a matrix X with n
+// columns and labels Y with k columns.
+type LSQResult struct {
+	// Coef is the n-by-k matrix of coefficients, or (n+1)-by-k with
+	// the intercept in the last row if Intercept is set. Row j holds
+	// the coefficients of column j of X.
+	Coef *Matrix
+
+	// Residuals is Y - X*Coef (unweighted).
+	Residuals *Matrix
+
+	// RSS is the weighted residual sum of squares of each column of
+	// Y, without the ridge penalty.
+	RSS Vector
+
+	// R2 is the coefficient of determination 1 - RSS/TSS of each
+	// column of Y, where TSS is the weighted total sum of squares
+	// about the weighted mean.
+	R2 Vector
+}
+
+// LeastSquareWith solves, for each column y of y, the least squares
+// problem min_b sum_i w_i(m_i*b - y_i)^2 + Ridge*|b|^2 where m_i is row
+// i of m, optionally with b >= 0. Unlike LeastSquare, neither m nor y
+// is modified. opts may be nil.
+func (m *Matrix) LeastSquareWith(y *Matrix, opts *LSQOptions) (*LSQResult, error) {
+	var o LSQOptions
+	if opts != nil {
+		o = *opts
+	}
+	if m.rows != y.rows {
+		return nil, &DimensionError{Op: "LeastSquareWith", Rows: m.rows,
+			Cols: m.cols, R: y.rows, C: y.cols}
+	}
+	w := o.Weights
+	if w == nil {
+		w = m.weight
+	}
+	if w != nil && len(w) != m.rows {
+		return nil, fmt.Errorf("matrix: LeastSquareWith: %d weights for %d rows",
+			len(w), m.rows)
+	}
+	if o.Ridge < 0 {
+		return nil, fmt.Errorf("matrix: LeastSquareWith: negative ridge %g",
+			o.Ridge)
+	}
+
+	// Build the augmented system [sqrt(W)X; sqrt(Ridge)I] b = [sqrt(W)y; 0].
+	n := m.cols
+	if o.Intercept {
+		n++
+	}
+	rows := m.rows
+	if o.Ridge > 0 {
+		rows += m.cols
+	}
+	a := NewMatrix(rows, n, nil)
+	b := NewMatrix(rows, y.cols, nil)
+	for i := 0; i < m.rows; i++ {
+		s := 1.0
+		if w != nil {
+			if w[i] < 0 {
+				return nil, fmt.Errorf(
+					"matrix: LeastSquareWith: negative weight at row %d", i)
+			}
+			s = math.Sqrt(w[i])
+		}
+		for j := 0; j < m.cols; j++ {
+			a.matrix[i][j] = s * m.matrix[i][j]
+		}
+		if o.Intercept {
+			a.matrix[i][m.cols] = s
+		}
+		for t := 0; t < y.cols; t++ {
+			b.matrix[i][t] = s * y.matrix[i][t]
+		}
+	}
+	if o.Ridge > 0 {
+		r := math.Sqrt(o.Ridge)
+		for j := 0; j < m.cols; j++ {
+			a.matrix[m.rows+j][j] = r
+		}
+	}
+
+	var coef *Matrix
+	var err error
+	if o.NonNegative {
+		coef = NewMatrix(n, y.cols, nil)
+		free := make([]bool, n)
+		if o.Intercept {
+			free[m.cols] = true
+		}
+		for t := 0; t < y.cols; t++ {
+			x, err := nnls(a, b.Col(t), free)
+			if err != nil {
+				return nil, err
+			}
+			for j := 0; j < n; j++ {
+				coef.matrix[j][t] = x[j]
+			}
+		}
+	} else {
+		if a.rows < a.cols {
+			// pad with zero rows so that LeastSquare returns the
+			// minimum-norm solution of the underdetermined system
+			a.AddRows(a.cols - a.rows)
+			b.AddRows(a.rows - b.rows)
+		}
+		coef, err = a.LeastSquareErr(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := &LSQResult{
+		Coef:      coef,
+		Residuals: NewMatrix(m.rows, y.cols, nil),
+		RSS:       make(Vector, y.cols),
+		R2:        make(Vector, y.cols),
+	}
+	for t := 0; t < y.cols; t++ {
+		var sw, mean float64
+		for i := 0; i < m.rows; i++ {
+			wi := 1.0
+			if w != nil {
+				wi = w[i]
+			}
+			sw += wi
+			mean += wi * y.matrix[i][t]
+		}
+		if sw > 0 {
+			mean /= sw
+		}
+		var rss, tss float64
+		for i := 0; i < m.rows; i++ {
+			wi := 1.0
+			if w != nil {
+				wi = w[i]
+			}
+			r := y.matrix[i][t]
+			for j := 0; j < m.cols; j++ {
+				r -= m.matrix[i][j] * coef.matrix[j][t]
+			}
+			if o.Intercept {
+				r -= coef.matrix[m.cols][t]
+			}
+			res.Residuals.matrix[i][t] = r
+			rss += wi * r * r
+			d := y.matrix[i][t] - mean
+			tss += wi * d * d
+		}
+		res.RSS[t] = rss
+		if tss > 0 {
+			res.R2[t] = 1 - rss/tss
+		} else {
+			res.R2[t] = math.NaN()
+		}
+	}
+	return res, nil
+}
+
+// nnls solves min |ax - b| subject to x[j] >= 0 for every j that is not
+// free using the active set method of Lawson and Hanson.
+func nnls(a *Matrix, b Vector, free []bool) (Vector, error) {
+	n := a.cols
+	x := make(Vector, n)
+	passive := make([]bool, n)
+	for j := range free {
+		passive[j] = free[j]
+	}
+
+	// gradient returns a*(b - ax)
+	gradient := func() Vector {
+		r := make(Vector, a.rows)
+		for i := 0; i < a.rows; i++ {
+			r[i] = b[i] - a.matrix[i].Dot(x)
+		}
+		g := make(Vector, n)
+		for i := 0; i < a.rows; i++ {
+			for j := 0; j < n; j++ {
+				g[j] += a.matrix[i][j] * r[i]
+			}
+		}
+		return g
+	}
+
+	// solve returns the unconstrained least squares solution on the
+	// passive columns, with zeros elsewhere.
+	solve := func() (Vector, error) {
+		var cols []int
+		for j := 0; j < n; j++ {
+			if passive[j] {
+				cols = append(cols, j)
+			}
+		}
+		s := make(Vector, n)
+		if len(cols) == 0 {
+			return s, nil
+		}
+		rows := a.rows
+		if rows < len(cols) {
+			rows = len(cols)
+		}
+		sub := NewMatrix(rows, len(cols), nil)
+		rhs := NewMatrix(rows, 1, nil)
+		for i := 0; i < a.rows; i++ {
+			for k, j := range cols {
+				sub.matrix[i][k] = a.matrix[i][j]
+			}
+			rhs.matrix[i][0] = b[i]
+		}
+		z, err := sub.LeastSquareErr(rhs)
+		if err != nil {
+			return nil, err
+		}
+		for k, j := range cols {
+			s[j] = z.matrix[k][0]
+		}
+		return s, nil
+	}
+
+	// start from the unconstrained solution on the free columns
+	s, err := solve()
+	if err != nil {
+		return nil, err
+	}
+	copy(x, s)
+
+	tol := 1e-10 * float64(n) * math.Max(1, b.Norm(0))
+	for iter := 0; iter < 10*n+10; iter++ {
+		g := gradient()
+		p := -1
+		for j := 0; j < n; j++ {
+			if !passive[j] && g[j] > tol && (p < 0 || g[j] > g[p]) {
+				p = j
+			}
+		}
+		if p < 0 {
+			return x, nil
+		}
+		passive[p] = true
+
+		for inner := 0; inner <= n; inner++ {
+			s, err := solve()
+			if err != nil {
+				return nil, err
+			}
+			alpha := math.Inf(1)
+			for j := 0; j < n; j++ {
+				if passive[j] && !free[j] && s[j] <= 0 {
+					if d := x[j] - s[j]; d > 0 {
+						alpha = math.Min(alpha, x[j]/d)
+					} else {
+						alpha = 0
+					}
+				}
+			}
+			if math.IsInf(alpha, 1) {
+				copy(x, s)
+				break
+			}
+			for j := 0; j < n; j++ {
+				x[j] += alpha * (s[j] - x[j])
+				if passive[j] && !free[j] && x[j] <= tol {
+					passive[j] = false
+					x[j] = 0
+				}
+			}
+		}
+	}
+	return nil, ErrNoConvergence
+}
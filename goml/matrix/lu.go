@@ -0,0 +1,193 @@
+package matrix
+
+import (
+	"errors"
+	"math"
+)
+
+// LU is the LU factorization with partial pivoting of a square matrix
+// A, i.e., PA = LU where P is a permutation matrix, L is unit lower
+// triangular and U is upper triangular.
+type LU struct {
+	// lu stores L below the diagonal and U on and above it.
+	lu *Matrix
+
+	// piv[i] is the row of A that is row i of PA.
+	piv permutation
+
+	// sign is the determinant of P.
+	sign float64
+}
+
+// NewLU computes the LU factorization of a. The matrix a is not
+// modified. A singular matrix can be factorized but Solve and Inverse
+// then return ErrSingular.
+func NewLU(a *Matrix) (*LU, error) {
+	if a.rows != a.cols {
+		return nil, ErrNotSquare
+	}
+	n := a.rows
+	f := &LU{lu: a.clone(), piv: make(permutation, n), sign: 1}
+	for i := range f.piv {
+		f.piv[i] = i
+	}
+	lu := f.lu.matrix
+	for k := 0; k < n; k++ {
+		// find the pivot
+		p := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(lu[i][k]) > math.Abs(lu[p][k]) {
+				p = i
+			}
+		}
+		if math.IsNaN(lu[p][k]) || math.IsInf(lu[p][k], 0) {
+			return nil, errors.New("matrix: LU: matrix contains NaN or Inf")
+		}
+		if p != k {
+			lu[p], lu[k] = lu[k], lu[p]
+			f.piv[p], f.piv[k] = f.piv[k], f.piv[p]
+			f.sign = -f.sign
+		}
+		if lu[k][k] == 0 {
+			continue
+		}
+		for i := k + 1; i < n; i++ {
+			lu[i][k] /= lu[k][k]
+			l := lu[i][k]
+			if l == 0 {
+				continue
+			}
+			for j := k + 1; j < n; j++ {
+				lu[i][j] -= l * lu[k][j]
+			}
+		}
+	}
+	return f, nil
+}
+
+// L returns the unit lower triangular factor.
+func (f *LU) L() *Matrix {
+	n := f.lu.rows
+	l := NewMatrix(n, n, nil)
+	for i := 0; i < n; i++ {
+		copy(l.matrix[i][:i], f.lu.matrix[i][:i])
+		l.matrix[i][i] = 1
+	}
+	return l
+}
+
+// U returns the upper triangular factor.
+func (f *LU) U() *Matrix {
+	n := f.lu.rows
+	u := NewMatrix(n, n, nil)
+	for i := 0; i < n; i++ {
+		copy(u.matrix[i][i:], f.lu.matrix[i][i:])
+	}
+	return u
+}
+
+// Pivot returns the row permutation: row i of PA is row Pivot()[i] of
+// A.
+func (f *LU) Pivot() []int {
+	p := make([]int, len(f.piv))
+	copy(p, f.piv)
+	return p
+}
+
+// Det returns the determinant of A.
+func (f *LU) Det() float64 {
+	d := f.sign
+	for i := 0; i < f.lu.rows; i++ {
+		d *= f.lu.matrix[i][i]
+	}
+	return d
+}
+
+// IsSingular reports whether a diagonal element of U is zero or
+// negligible compared to the largest one.
+func (f *LU) IsSingular() bool {
+	max := 0.0
+	for i := 0; i < f.lu.rows; i++ {
+		max = math.Max(max, math.Abs(f.lu.matrix[i][i]))
+	}
+	tol := float64(f.lu.rows) * max * ESP
+	for i := 0; i < f.lu.rows; i++ {
+		if math.Abs(f.lu.matrix[i][i]) <= tol {
+			return true
+		}
+	}
+	return false
+}
+
+// Solve returns the solution X of AX = B. It returns a
+// *DimensionError if B does not have as many rows as A and
+// ErrSingular if A is singular.
+func (f *LU) Solve(b *Matrix) (*Matrix, error) {
+	n := f.lu.rows
+	if b.rows != n {
+		return nil, &DimensionError{Op: "LU.Solve", Rows: n, Cols: n,
+			R: b.rows, C: b.cols}
+	}
+	if f.IsSingular() {
+		return nil, ErrSingular
+	}
+	x := NewMatrix(n, b.cols, nil)
+	for i := 0; i < n; i++ {
+		copy(x.matrix[i], b.matrix[f.piv[i]])
+	}
+	lu := f.lu.matrix
+	// forward substitution with L
+	for k := 0; k < n; k++ {
+		for i := k + 1; i < n; i++ {
+			if l := lu[i][k]; l != 0 {
+				for t := 0; t < x.cols; t++ {
+					x.matrix[i][t] -= l * x.matrix[k][t]
+				}
+			}
+		}
+	}
+	// backward substitution with U
+	for k := n - 1; k >= 0; k-- {
+		x.matrix[k].Scale(1 / lu[k][k])
+		for i := 0; i < k; i++ {
+			if u := lu[i][k]; u != 0 {
+				for t := 0; t < x.cols; t++ {
+					x.matrix[i][t] -= u * x.matrix[k][t]
+				}
+			}
+		}
+	}
+	return x, nil
+}
+
+// Inverse returns the inverse of A or ErrSingular.
+func (f *LU) Inverse() (*Matrix, error) {
+	return f.Solve(identity(f.lu.rows))
+}
+
+// Det returns the determinant of the square matrix m. It panics if m
+// is not square.
+func (m *Matrix) Det() float64 {
+	f, err := NewLU(m)
+	must(err)
+	return f.Det()
+}
+
+// Inverse returns the inverse of the square matrix m.
+func (m *Matrix) Inverse() (*Matrix, error) {
+	f, err := NewLU(m)
+	if err != nil {
+		return nil, err
+	}
+	return f.Inverse()
+}
+
+// Solve returns the solution X of mX = b for a square matrix m. Use
+// LeastSquare for rectangular systems.
+func (m *Matrix) Solve(b *Matrix) (*Matrix, error) {
+	f, err := NewLU(m)
+	if err != nil {
+		return nil, err
+	}
+	return f.Solve(b)
+}
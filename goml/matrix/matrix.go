@@ -6,15 +6,10 @@ package matrix
 
 import (
 	"../rand"
-	"bufio"
-	"bytes"
 	"fmt"
 	//"gonum.org/v1/gonum/floats"
 	"math"
-	"os"
 	"sort"
-	"strconv"
-	"strings"
 	"time"
 )
 
@@ -22,6 +17,7 @@ const (
 	UNKNOWN_VALUE  = -1e308
 	TIME_FORMAT    = "2006-01-02 15:04:05"
 	ATTR_NAME      = 1 << 20
+	ATTR_FORMAT    = ATTR_NAME + 1
 	EXTRA_NUM_CELL = 10
 	ESP            = 1e-15
 )
@@ -51,10 +47,23 @@ type Matrix struct {
 	str_to_enum []map[string]int
 
 	// enum_to_str[i] maps ATTR_NAME to data type of column[i].
-	// Currently, the code only support REAL, NOMINAL, and DATE data.
-	// If column[i] is a nominal variable then enum_to_str[i] maps its
-	// enumerated values to its string values.
+	// Currently, the code only support REAL, NOMINAL, STRING and DATE
+	// data. If column[i] is a nominal or string variable then
+	// enum_to_str[i] maps its enumerated values to its string values.
+	// If column[i] is a date then enum_to_str[i] maps ATTR_FORMAT to
+	// its date pattern (in Java SimpleDateFormat syntax as in ARFF).
 	enum_to_str []map[int]string
+
+	// weight stores the weight of each row (instance). A nil weight
+	// means every row has weight 1.
+	weight Vector
+
+	// location is the time zone used to parse and print dates. A nil
+	// location means DEFAULT_TIME_ZONE.
+	location *time.Location
+
+	// sparse tells SaveARFF to write the data in sparse format.
+	sparse bool
 }
 
 // NewMatrix creates a matrix of size rows*cols or wrap a matrix
@@ -76,6 +85,7 @@ func NewMatrix(rows, cols int, val []float64) *Matrix {
 	m.enum_to_str = make([]map[int]string, m.cols)
 	for i := 0; i < m.cols; i++ {
 		m.attrName[i] = fmt.Sprintf("col_%d", i)
+		m.str_to_enum[i] = make(map[string]int)
 		m.enum_to_str[i] = make(map[int]string)
 		m.enum_to_str[i][ATTR_NAME] = "real"
 	}
@@ -149,6 +159,16 @@ func (m *Matrix) FillCol(c int, val float64) *Matrix {
 
 // Fill tiles the vector m.data by the vector vals.
 func (m *Matrix) Fill(vals []float64) *Matrix {
+	if m.isView() {
+		k := 0
+		for i := 0; i < m.rows; i++ {
+			for j := 0; j < m.cols; j++ {
+				m.matrix[i][j] = vals[k%len(vals)]
+				k++
+			}
+		}
+		return m
+	}
 	N := len(m.data) / len(vals)
 	if N*len(vals) < len(m.data) {
 		N++
@@ -182,75 +202,6 @@ func (m *Matrix) Row(i int) Vector {
 	return m.matrix[i]
 }
 
-// String converts a Matrix into a string so that it can be printed
-// using fmt.Printf("%v\n", matrix).
-func (m *Matrix) String() string {
-	var buf bytes.Buffer
-
-	// find the width of a printed row
-	numstr := 0
-	for j := 0; j < m.cols; j++ {
-		switch m.enum_to_str[j][ATTR_NAME][0] {
-		case 'n': // nominal
-			numstr += 11
-		default: // real
-			numstr += 21
-		}
-	}
-	heline := make([]byte, numstr)
-	hdline := make([]byte, numstr)
-	for i := 0; i < len(heline); i++ {
-		heline[i] = '='
-		hdline[i] = '-'
-	}
-
-	buf.Grow((m.rows+2)*m.cols*21 + m.rows*2 + 2 + 2)
-	fmt.Fprintf(&buf, "\n%s\n", heline)
-	// print attribute type
-	for j := 0; j < m.cols; j++ {
-		switch m.enum_to_str[j][ATTR_NAME][0] {
-		case 'n': // nominal
-			fmt.Fprintf(&buf, " %10q", "nominal")
-		default: // real
-			fmt.Fprintf(&buf, " %20q", m.enum_to_str[j][ATTR_NAME])
-		}
-	}
-	fmt.Fprintf(&buf, "\n")
-	// print attribute name
-	for j := 0; j < m.cols; j++ {
-		switch m.enum_to_str[j][ATTR_NAME][0] {
-		case 'n': // nominal
-			if len(m.attrName[j]) > 10 {
-				fmt.Fprintf(&buf, " %10s", m.attrName[j][:10])
-			} else {
-				fmt.Fprintf(&buf, " %10s", m.attrName[j])
-			}
-		default: // real
-			fmt.Fprintf(&buf, " %20s", m.attrName[j])
-		}
-	}
-
-	// print data
-	fmt.Fprintf(&buf, "\n%s\n", hdline)
-	for i := 0; i < m.rows; i++ {
-		for j := 0; j < m.cols; j++ {
-			switch m.enum_to_str[j][ATTR_NAME][0] {
-			case 'n': // nominal
-				fmt.Fprintf(&buf, " %10d", int(m.matrix[i][j]))
-			case 'd': // date
-				t := int64(m.matrix[i][j])
-				fmt.Fprintf(&buf, " %20s",
-					time.Unix(t, 0).Format(TIME_FORMAT))
-			default: // real
-				fmt.Fprintf(&buf, " %20.12e", m.matrix[i][j])
-			}
-		}
-		fmt.Fprintf(&buf, "\n")
-	}
-	fmt.Fprintf(&buf, "%s\n", heline)
-	return buf.String()
-}
-
 // Rows return the number of rows of a matrix
 func (m *Matrix) Rows() int {
 	return m.rows
@@ -279,17 +230,57 @@ func (m *Matrix) Transpose() *Matrix {
 	return t
 }
 
+// clone returns a copy of the data of m without its metadata.
+func (m *Matrix) clone() *Matrix {
+	c := NewMatrix(m.rows, m.cols, nil)
+	for i := 0; i < m.rows; i++ {
+		copy(c.matrix[i], m.matrix[i])
+	}
+	return c
+}
+
+// identity returns the n-by-n identity matrix.
+func identity(n int) *Matrix {
+	m := NewMatrix(n, n, nil)
+	for i := 0; i < n; i++ {
+		m.matrix[i][i] = 1
+	}
+	return m
+}
+
 // Mul stores the product of the two matracies in the receiver.
 func (m *Matrix) Mul(a, b *Matrix, aTranspose, bTranspose bool) *Matrix {
+	must(m.MulErr(a, b, aTranspose, bTranspose))
+	return m
+}
+
+// MulErr is like Mul but returns a *DimensionError instead of
+// panicking when the inner dimensions of the product do not agree.
+// The receiver is left untouched on error. The receiver may be one of
+// the operands.
+//
+// Large products are tiled for cache locality and computed by one
+// goroutine per core. The transposed operands are read in place.
+func (m *Matrix) MulErr(a, b *Matrix, aTranspose, bTranspose bool) error {
 	cols, rows := a.cols, b.rows
-	m.rows, m.cols = a.rows, b.cols
+	mRows, mCols := a.rows, b.cols
 	if aTranspose {
-		cols, m.rows = m.rows, cols
+		cols, mRows = mRows, cols
 	}
 	if bTranspose {
-		rows, m.cols = m.cols, rows
+		rows, mCols = mCols, rows
+	}
+	if cols != rows {
+		return &DimensionError{Op: "Mul", Rows: mRows, Cols: cols,
+			R: rows, C: mCols}
+	}
+	if m.sharesData(a) || m.sharesData(b) {
+		t := NewMatrix(mRows, mCols, nil)
+		t.mul(a, b, aTranspose, bTranspose)
+		*m = *t
+		return nil
 	}
-	Require(cols == rows, "matrix: Mul: dimension mismatched\n")
+	m.rows, m.cols = mRows, mCols
 
 	// If the underlying data is of correct size, do not allocate new
 	// memory.
@@ -301,6 +292,7 @@ func (m *Matrix) Mul(a, b *Matrix, aTranspose, bTranspose bool) *Matrix {
 		m.enum_to_str = make([]map[int]string, m.cols)
 		for i := 0; i < m.cols; i++ {
 			m.attrName[i] = fmt.Sprintf("col_%d", i)
+			m.str_to_enum[i] = make(map[string]int)
 			m.enum_to_str[i] = make(map[int]string)
 			m.enum_to_str[i][ATTR_NAME] = "real"
 		}
@@ -314,49 +306,8 @@ func (m *Matrix) Mul(a, b *Matrix, aTranspose, bTranspose bool) *Matrix {
 		*m = *NewMatrix(m.rows, m.cols, nil)
 	}
 
-	// compute the product
-	if aTranspose { // a is transposed
-		if bTranspose { // a and b are transposed
-			for i := 0; i < m.rows; i++ {
-				for j := 0; j < m.cols; j++ {
-					m.matrix[i][j] = 0.0
-					for k := 0; k < a.rows; k++ {
-						m.matrix[i][j] += a.matrix[k][i] * b.matrix[j][k]
-					}
-				}
-			}
-		} else { // a is tranposed but b is not
-			for i := 0; i < m.rows; i++ {
-				for j := 0; j < m.cols; j++ {
-					m.matrix[i][j] = 0.0
-					for k := 0; k < a.rows; k++ {
-						m.matrix[i][j] += a.matrix[k][i] * b.matrix[k][j]
-					}
-				}
-			}
-		}
-	} else { // a is not transposed
-		if bTranspose { // a is not transposed but b is
-			for i := 0; i < m.rows; i++ {
-				for j := 0; j < m.cols; j++ {
-					m.matrix[i][j] = 0.0
-					for k := 0; k < a.cols; k++ {
-						m.matrix[i][j] += a.matrix[i][k] * b.matrix[j][k]
-					}
-				}
-			}
-		} else { // a and b are not transposed
-			for i := 0; i < m.rows; i++ {
-				for j := 0; j < m.cols; j++ {
-					m.matrix[i][j] = 0.0
-					for k := 0; k < a.cols; k++ {
-						m.matrix[i][j] += a.matrix[i][k] * b.matrix[k][j]
-					}
-				}
-			}
-		}
-	}
-	return m
+	m.mul(a, b, aTranspose, bTranspose)
+	return nil
 }
 
 func Mul(a, b *Matrix, aTranspose, bTranspose bool) *Matrix {
@@ -368,10 +319,23 @@ func Mul(a, b *Matrix, aTranspose, bTranspose bool) *Matrix {
 // AddRows adds more rows to the matrix. Old data are kept intact.
 func (m *Matrix) AddRows(n int) *Matrix {
 	Require(n > 0, "AddRows: n must be positive")
+	m.compact()
+	if m.weight != nil {
+		w := make(Vector, m.rows+n)
+		copy(w, m.weight)
+		for i := m.rows; i < len(w); i++ {
+			w[i] = 1
+		}
+		m.weight = w
+	}
+	// copy the rows in their current order, which differs from the
+	// order of m.data after SwapRows
+	temp := make(Vector, (m.rows+n)*m.cols)
+	for i := 0; i < m.rows; i++ {
+		copy(temp[i*m.cols:(i+1)*m.cols], m.matrix[i])
+	}
 	m.rows += n
 	m.matrix = make([]Vector, m.rows)
-	temp := make(Vector, m.rows*m.cols)
-	copy(temp, m.data)
 	m.data = temp
 	for i := 0; i < m.rows; i++ {
 		m.matrix[i] = m.data[i*m.cols : (i+1)*m.cols]
@@ -383,11 +347,12 @@ func (m *Matrix) AddRows(n int) *Matrix {
 // If you don't need to keep old data, use NewMatrix instead
 func (m *Matrix) AddCols(n int) *Matrix {
 	Require(n > 0, "AddCols: n must be positive")
+	m.compact()
 	oldCol := m.cols
 	m.cols += n
 	temp := make(Vector, m.rows*m.cols)
 	for i := 0; i < m.rows; i++ {
-		copy(temp[i*m.cols:], m.data[i*oldCol:(i+1)*oldCol])
+		copy(temp[i*m.cols:], m.matrix[i][:oldCol])
 		m.matrix[i] = temp[i*m.cols : (i+1)*m.cols]
 	}
 	m.data = temp
@@ -404,6 +369,7 @@ func (m *Matrix) AddCols(n int) *Matrix {
 	m.enum_to_str = tempe
 	for i := oldCol; i < m.cols; i++ {
 		m.attrName[i] = fmt.Sprintf("col_%d", i)
+		m.str_to_enum[i] = make(map[string]int)
 		m.enum_to_str[i] = make(map[int]string)
 		m.enum_to_str[i][ATTR_NAME] = "real"
 	}
@@ -413,6 +379,12 @@ func (m *Matrix) AddCols(n int) *Matrix {
 // Scale scales all element by the factor.
 func (m *Matrix) Scale(c float64) *Matrix {
 	//floats.Scale(c, m.data)
+	if m.isView() {
+		for i := 0; i < m.rows; i++ {
+			m.matrix[i].Scale(c)
+		}
+		return m
+	}
 	for i := 0; i < len(m.data); i++ {
 		m.data[i] *= c
 	}
@@ -432,6 +404,7 @@ func (m *Matrix) CopyMetadata(from *Matrix) {
 	m.str_to_enum = make([]map[string]int, from.cols)
 	m.enum_to_str = make([]map[int]string, from.cols)
 	copy(m.attrName, from.attrName)
+	m.location = from.location
 	for i := 0; i < from.cols; i++ {
 		m.str_to_enum[i] = make(map[string]int)
 		m.enum_to_str[i] = make(map[int]string)
@@ -473,214 +446,62 @@ func (m *Matrix) GetValueName(col, val int) string {
 	return m.enum_to_str[col][val]
 }
 
-// SaveARFF save data from a matrix to an ARFF file.
-func (m *Matrix) SaveARFF(fileName string, headerOn ...bool) {
-	fileio, err := os.Create(fileName)
-	Require(err == nil, "SaveARFF: %v\n", err)
-	defer fileio.Close()
+// Weights returns the weight of each row or nil if every row has
+// weight 1. Weights are read from and written to ARFF files.
+func (m *Matrix) Weights() Vector {
+	return m.weight
+}
 
-	header := true
-	if len(headerOn) > 0 {
-		header = headerOn[0]
-	}
-	file := bufio.NewWriter(fileio)
-	if header {
-		file.WriteString("@relation " + m.relation + "\n\n")
-		file.Flush()
-		for i := 0; i < m.cols; i++ {
-			quote := ""
-			for j := 0; j < len(m.attrName[i]); j++ {
-				if m.attrName[i][j] == ' ' {
-					quote = "\""
-					break
-				}
-			}
-			file.WriteString("@attribute " + quote + m.attrName[i] +
-				quote + "\t")
-			s := m.enum_to_str[i][ATTR_NAME]
-			switch s[0] {
-			case 'n': // nominal
-				file.WriteString("{" + m.enum_to_str[i][0])
-				for j := 1; j < len(m.str_to_enum[i]); j++ {
-					file.WriteString("," + m.enum_to_str[i][j])
-				}
-				file.WriteString("}\n")
-			case 'r': // real
-				file.WriteString("real\n")
-			default: // date
-				file.WriteString("\"yyyy-MM-dd HH:mm:ss\"\n")
-			}
-			file.Flush()
-		}
-		file.WriteString("\n@data\n")
+// rowWeight returns the weight of row i.
+func (m *Matrix) rowWeight(i int) float64 {
+	if m.weight == nil {
+		return 1
 	}
-	// write data
-	for i := 0; i < m.rows; i++ {
-		for j := 0; j < m.cols; j++ {
-			if j > 0 {
-				file.WriteString(",")
-			}
-			var s string
-			if m.matrix[i][j] == UNKNOWN_VALUE {
-				s = "?"
-			} else {
-				switch m.enum_to_str[j][ATTR_NAME][0] {
-				case 'n': // nominal
-					s = fmt.Sprintf("%s", m.enum_to_str[j][int(m.matrix[i][j])])
-				case 'r': // real
-					s = fmt.Sprintf("%.5e", m.matrix[i][j])
-				default: // date
-					t := int64(m.matrix[i][j])
-					s = fmt.Sprintf("%s", time.Unix(t, 0).Format(TIME_FORMAT))
-				}
-			}
-			file.WriteString(s)
-		}
-		file.WriteString("\n")
-		file.Flush()
+	return m.weight[i]
+}
+
+// checkWeights returns an error if m has weights but not one per row,
+// so that op does not write them to the wrong rows.
+func (m *Matrix) checkWeights(op string) error {
+	if m.weight != nil && len(m.weight) != m.rows {
+		return &DimensionError{Op: op, Rows: m.rows, Cols: m.cols,
+			R: len(m.weight), C: 1}
 	}
+	return nil
 }
 
-// ValueCount returns the number of categorical values in the column
-// i and 0 if the variable in the column i is continuous.
-func (m *Matrix) ValueCount(i int) int {
-	return len(m.str_to_enum[i])
+// SetWeights sets the weight of each row. A nil w resets every weight
+// to 1.
+func (m *Matrix) SetWeights(w Vector) {
+	Require(w == nil || len(w) == m.rows,
+		"SetWeights: require len(w) = %d but get %d\n", m.rows, len(w))
+	m.weight = w
 }
 
-// LoadARFF loads data from ARFF file to a matrix
-func (m *Matrix) LoadARFF(fileName string, tz ...string) *Matrix {
-	timeZone := "-06:00"
-	if len(tz) > 0 {
-		timeZone = tz[0]
-	}
-	fileio, err := os.Open(fileName)
-	Require(err == nil, "LoadARFF: %v\n", err)
-	defer fileio.Close()
+// SetSparseARFF selects whether SaveARFF writes rows in the sparse
+// ARFF format. It is turned on by LoadARFF when the file contains
+// sparse rows.
+func (m *Matrix) SetSparseARFF(sparse bool) {
+	m.sparse = sparse
+}
 
-	// metadata
-	m.attrName = make([]string, 0, EXTRA_NUM_CELL)
-	m.str_to_enum = make([]map[string]int, 0, EXTRA_NUM_CELL)
-	m.enum_to_str = make([]map[int]string, 0, EXTRA_NUM_CELL)
-
-	attrFile := bufio.NewReader(fileio)
-	var line string
-
-	// read attributes' names and data types.
-	lineNum := 0 // current line
-	m.cols = 0
-	for err == nil {
-		line, err = attrFile.ReadString('\n')
-		if err != nil {
-			break
-		}
-		lineNum++
-		line = line[:len(line)-1]
-		s := Split(line, "\t ", 0, 3)
-		if len(s) == 0 {
-			continue
-		}
-		switch strings.ToLower(s[0]) {
-		case "@relation":
-			m.relation = s[1]
-		case "@attribute":
-			m.attrName = append(m.attrName, s[1])
-			m.enum_to_str = append(m.enum_to_str, make(map[int]string))
-			m.str_to_enum = append(m.str_to_enum, make(map[string]int))
-			switch strings.ToLower(string(s[2][0])) {
-			case "{": // nominal
-				m.enum_to_str[m.cols][ATTR_NAME] = "nominal"
-				sn := s[2][1 : len(s[2])-1]
-				n := Split(sn, ", ", 0)
-				for i := 0; i < len(n); i++ {
-					m.str_to_enum[m.cols][n[i]] = i
-					m.enum_to_str[m.cols][i] = n[i]
-				}
-			case "i": // integer
-				fallthrough
-			case "r": // real
-				fallthrough
-			case "n": // numeric
-				m.enum_to_str[m.cols][ATTR_NAME] = "real"
-			case "s": // string
-				panic("LoadARFF: string data is not supported")
-			case "d": // date
-				s := Split(line, "\t ", 0, 4)
-				Require(s[3] == "yyyy-MM-dd HH:mm:ss" ||
-					s[3] == "yyyy-MM-ddTHH:mm:ss",
-					"LoadARFF: only date formats '%s' and '%s' are supported\n",
-					"yyyy-MM-dd HH:mm:ss", "yyyy-MM-ddTHH:mm:ss")
-				m.enum_to_str[m.cols][ATTR_NAME] = "date"
-			default:
-				panic(fmt.Sprintf(
-					"Attribute %s: data type %q is not supported\n",
-					s[1], s[2]))
-			}
-			m.cols++
-		case "@data":
-			err = fmt.Errorf("done with reading attribute")
-		default:
-		}
+// timeLocation returns the location used to parse and print dates.
+func (m *Matrix) timeLocation() *time.Location {
+	if m.location == nil {
+		return defaultLocation
 	}
+	return m.location
+}
 
-	// read data
-	m.matrix = make([]Vector, 0, EXTRA_NUM_CELL)
-	err = nil
-	row := 0
-	for err == nil {
-		line, err = attrFile.ReadString('\n')
-		if err != nil {
-			break
-		}
-		lineNum++
-		line = line[:len(line)-1]
-		if len(line) == 0 || line[0] == '%' {
-			continue
-		}
-
-		s := Split(line, "\t ,", 0)
-		Require(len(s) == m.cols,
-			"LoadARFF: %s: wrong number of attributes on line %d\n",
-			fileName, lineNum)
-		m.matrix = append(m.matrix, make([]float64, m.cols))
-		for j := 0; j < len(s); j++ {
-			if s[j] == "?" {
-				m.matrix[row][j] = UNKNOWN_VALUE
-			} else {
-				switch m.enum_to_str[j][ATTR_NAME][0] {
-				case 'n': // nominal
-					m.matrix[row][j] =
-						float64(m.str_to_enum[j][s[j]])
-				case 'r': // real
-					number, parseErr := strconv.ParseFloat(s[j], 64)
-					Require(parseErr == nil || parseErr != strconv.ErrSyntax,
-						"LoadARFF: %s: data at column %d on line %d is not a valid real number\n",
-						fileName, j, lineNum)
-					m.matrix[row][j] = number
-				case 'd': // date
-					t, parseErr := time.Parse(time.RFC3339,
-						strings.Replace(s[j], " ", "T", 1)+timeZone)
-					Require(parseErr == nil,
-						"LoadARFF: %s: data at column %d on line %d is not a valid date\n",
-						fileName, j, lineNum)
-					m.matrix[row][j] = float64(t.Unix())
-				default: // string is not implemented
-				}
-			}
-		}
-		row++
-	}
-	m.rows = row
-	m.data = make(Vector, m.rows*m.cols)
-	for i := 0; i < m.rows; i++ {
-		copy(m.data[i*m.cols:], m.matrix[i])
-		m.matrix[i] = m.data[i*m.cols : (i+1)*m.cols]
-	}
-	return m
+// ValueCount returns the number of categorical values in the column
+// i and 0 if the variable in the column i is continuous.
+func (m *Matrix) ValueCount(i int) int {
+	return len(m.str_to_enum[i])
 }
 
 // CopyRows copies rows between start[i] and end[i] from a matrix s
 // to the matrix m. It will truncate any data that is out of bound on
-// the matrix m.
+// the matrix m. The weights of the rows are copied with them.
 func (m *Matrix) CopyRows(s *Matrix, start, end []int) {
 	N := len(start)
 	if N > len(end) {
@@ -691,18 +512,27 @@ func (m *Matrix) CopyRows(s *Matrix, start, end []int) {
 	copy(m.str_to_enum, s.str_to_enum)
 	copy(m.enum_to_str, s.enum_to_str)
 
+	if m.weight == nil && s.weight != nil {
+		m.weight = NewVector(m.rows, nil).Fill(1)
+	}
 	row := 0
-	for j := start[0]; j < end[0] && j < m.rows && j < s.rows; j++ {
+	copyRow := func(j int) {
 		copy(m.matrix[row], s.matrix[j])
+		if m.weight != nil {
+			m.weight[row] = s.rowWeight(j)
+		}
 		row++
 	}
+	for j := start[0]; j < end[0] && j < m.rows && j < s.rows; j++ {
+		copyRow(j)
+	}
 	for i := 1; i < N; i++ {
-		if start[i] < end[i-1] {
-			start[i] = end[i-1]
+		begin := start[i]
+		if begin < end[i-1] {
+			begin = end[i-1]
 		}
-		for j := start[i]; j < end[i] && j < m.rows && j < s.rows; j++ {
-			copy(m.matrix[row], s.matrix[j])
-			row++
+		for j := begin; j < end[i] && j < m.rows && j < s.rows; j++ {
+			copyRow(j)
 		}
 	}
 }
@@ -710,7 +540,8 @@ func (m *Matrix) CopyRows(s *Matrix, start, end []int) {
 // WrapRowsCols wraps a matrix around rows between start[i] and end[i]
 // of matrix m for columns from colBegin to colEnd. colBegin must be less
 // than s.cols and colEnd must be larger than 0.
-// colBegin = colIdx[0] and colEnd = colIdx[1].
+// colBegin = colIdx[0] and colEnd = colIdx[1]. The result is a view
+// of s (see Slice); start and end are not modified.
 func (m *Matrix) WrapRows(s *Matrix, start, end []int, colIdx ...int) {
 	colBegin := 0
 	colEnd := s.cols
@@ -725,6 +556,8 @@ func (m *Matrix) WrapRows(s *Matrix, start, end []int, colIdx ...int) {
 		}
 	}
 	m.cols = colEnd - colBegin
+	m.data = nil
+	m.weight = nil
 	if cap(m.matrix) < s.rows {
 		m.matrix = make([]Vector, 0, s.rows)
 	} else {
@@ -747,10 +580,11 @@ func (m *Matrix) WrapRows(s *Matrix, start, end []int, colIdx ...int) {
 		row++
 	}
 	for i := 1; i < N; i++ {
-		if start[i] < end[i-1] {
-			start[i] = end[i-1]
+		begin := start[i]
+		if begin < end[i-1] {
+			begin = end[i-1]
 		}
-		for j := start[i]; j < end[i] && j < s.rows; j++ {
+		for j := begin; j < end[i] && j < s.rows; j++ {
 			m.matrix = append(m.matrix, s.matrix[j][colBegin:colEnd])
 			row++
 		}
@@ -760,7 +594,7 @@ func (m *Matrix) WrapRows(s *Matrix, start, end []int, colIdx ...int) {
 
 // SubMatrix copy the content of a submatrix of a matrix determined
 // by the list of column indices and row indices. This operation
-// allows duplicating rows and columns.
+// allows duplicating rows and columns. The rows keep their weights.
 func (m *Matrix) SubMatrix(s *Matrix, rows, cols []int) {
 	if len(rows) != m.rows || len(cols) != m.cols {
 		*m = *NewMatrix(len(rows), len(cols), nil)
@@ -770,9 +604,16 @@ func (m *Matrix) SubMatrix(s *Matrix, rows, cols []int) {
 			m.matrix[i][j] = s.matrix[rows[i]][cols[j]]
 		}
 	}
+	m.weight = nil
+	if s.weight != nil {
+		m.weight = make(Vector, len(rows))
+		for i, r := range rows {
+			m.weight[i] = s.weight[r]
+		}
+	}
 }
 
-// SwapRows swaps two rows in the matrix.
+// SwapRows swaps two rows in the matrix, with their weights.
 func (m *Matrix) SwapRows(r1, r2 int) *Matrix {
 	if r1 < 0 {
 		r1 += m.rows
@@ -781,6 +622,9 @@ func (m *Matrix) SwapRows(r1, r2 int) *Matrix {
 		r2 += m.rows
 	}
 	m.matrix[r1], m.matrix[r2] = m.matrix[r2], m.matrix[r1]
+	if m.weight != nil {
+		m.weight[r1], m.weight[r2] = m.weight[r2], m.weight[r1]
+	}
 	return m
 }
 
@@ -806,21 +650,12 @@ func (a *Matrix) axb(x, b Vector, sign int) Vector {
 	Require(a.cols == len(x) && a.rows == len(b),
 		"Axb: dimension mismatched: a.cols == len(x) && a.rows == len(b)\n")
 	v := NewVector(a.rows, nil)
-	if sign < 0 {
-		for i := 0; i < len(v); i++ {
-			for j := 0; j < a.cols; j++ {
-				v[i] += a.matrix[i][j] * x[j]
-			}
-			v[i] -= b[i]
-		}
-	} else {
-		for i := 0; i < len(v); i++ {
-			for j := 0; j < a.cols; j++ {
-				v[i] += a.matrix[i][j] * x[j]
-			}
-			v[i] += b[i]
+	s := float64(sign)
+	parallelRows(a.rows, a.rows*a.cols, func(i0, i1 int) {
+		for i := i0; i < i1; i++ {
+			v[i] = dot(a.matrix[i][:a.cols], x) + s*b[i]
 		}
-	}
+	})
 	return v
 }
 
@@ -863,7 +698,8 @@ func (m *Matrix) PermuteCols(P permutation) {
 	}
 }
 
-// PermuteRows change the rows of the matrix due to a permutation.
+// PermuteRows change the rows of the matrix due to a permutation. The
+// weights are permuted with the rows.
 func (m *Matrix) PermuteRows(P permutation) {
 	if m.rows != len(P) {
 		panic("PermuteRows: permutation is of the wrong size\n")
@@ -902,17 +738,37 @@ func (m *Matrix) PermuteRows(P permutation) {
 // matrix y is the labels. The output is a matrix whose first rows is
 // the vector b and the remaining rows form the matrix M. Each
 // columns of the output matrix is a solution.
+//
+// NOTE: both m and y are overwritten by the factorization. Use
+// LeastSquareWith to keep them.
 func (m *Matrix) LeastSquare(y *Matrix) *Matrix {
-	Require(m.rows >= m.cols,
-		"LeastSquare: expected at least as many rows as columns\n")
-	Require(m.rows == y.rows, "LeastSquare: dimension mismatched\n")
+	x, err := m.LeastSquareErr(y)
+	must(err)
+	return x
+}
+
+// LeastSquareErr is like LeastSquare but returns an error instead of
+// panicking when the dimensions of m and y do not agree or when m
+// contains NaN or Inf.
+func (m *Matrix) LeastSquareErr(y *Matrix) (*Matrix, error) {
+	if m.rows < m.cols {
+		return nil, fmt.Errorf("matrix: LeastSquare: %s, get %d-by-%d",
+			"expected at least as many rows as columns", m.rows, m.cols)
+	}
+	if m.rows != y.rows {
+		return nil, &DimensionError{Op: "LeastSquare", Rows: m.rows,
+			Cols: m.cols, R: y.rows, C: y.cols}
+	}
 
 	// The solution x (the weights) is a matrix.
 	x := NewMatrix(m.cols, y.cols, nil)
 	var vk Vector = NewVector(m.rows, nil)
 
 	var rP, cP permutation
-	rank, vk1 := m.QR(&rP, &cP)
+	rank, vk1, err := m.qr(&rP, &cP)
+	if err != nil {
+		return nil, err
+	}
 
 	// compute (Q*)b
 	y.PermuteRows(rP)
@@ -947,7 +803,10 @@ func (m *Matrix) LeastSquare(y *Matrix) *Matrix {
 
 		y.matrix = y.matrix[:rank]
 		vk = vk[:n.rows]
-		_, vk1 := n.QR(&rP, &cP)
+		_, vk1, err := n.qr(&rP, &cP)
+		if err != nil {
+			return nil, err
+		}
 
 		// forward substitution
 		for t := 0; t < y.cols; t++ {
@@ -1005,7 +864,7 @@ func (m *Matrix) LeastSquare(y *Matrix) *Matrix {
 		}
 	}
 
-	return x
+	return x, nil
 }
 
 // QR perform QR factorization on the matrix m. It stores the row and
@@ -1019,6 +878,13 @@ func (m *Matrix) LeastSquare(y *Matrix) *Matrix {
 //
 // NOTE: the rows of m are sorted but the columns stay still.
 func (m *Matrix) QR(rP, cP *permutation) (int, Vector) {
+	rank, vk1, err := m.qr(rP, cP)
+	must(err)
+	return rank, vk1
+}
+
+// qr is QR but returns an error if a column norm is not a number.
+func (m *Matrix) qr(rP, cP *permutation) (int, Vector, error) {
 	*rP = make([]int, m.rows)
 	var P permutation = *rP
 
@@ -1064,8 +930,10 @@ func (m *Matrix) QR(rP, cP *permutation) (int, Vector) {
 			for i := k; i < m.rows; i++ {
 				colNorm += m.matrix[i][l] * m.matrix[i][l]
 			}
-			Require(!math.IsNaN(colNorm) && !math.IsInf(colNorm, 0),
-				"LeastSquare: the norm of column %d is not a number.\n", P[j])
+			if math.IsNaN(colNorm) || math.IsInf(colNorm, 0) {
+				return k, vk1[:k], fmt.Errorf(
+					"matrix: QR: the norm of column %d is not a number", P[j])
+			}
 			if colNorm > maxColNorm {
 				maxColNorm = colNorm
 				maxColIndex = j
@@ -1075,7 +943,7 @@ func (m *Matrix) QR(rP, cP *permutation) (int, Vector) {
 		a00 := m.matrix[0][P[0]]
 		if maxColNorm < a00*ESP*ESP*a00 {
 			vk1 = vk1[:k]
-			return k, vk1
+			return k, vk1, nil
 		}
 		// swap columns i and maxColIndex
 		P[k], P[maxColIndex] = P[maxColIndex], P[k]
@@ -1093,8 +961,9 @@ func (m *Matrix) QR(rP, cP *permutation) (int, Vector) {
 			vk[k] += colNorm
 		}
 
-		// A[k:m, k] -= 2vk(vk*A[k:m, k])
-		if m.matrix[k][P[k]] > 0 {
+		// A[k:m, k] -= 2vk(vk*A[k:m, k]), which is -sign(x_k)|x| with
+		// sign(0) = 1 as for vk above
+		if m.matrix[k][P[k]] >= 0 {
 			m.matrix[k][P[k]] = -colNorm
 		} else {
 			m.matrix[k][P[k]] = colNorm
@@ -1125,7 +994,7 @@ func (m *Matrix) QR(rP, cP *permutation) (int, Vector) {
 			}
 		}
 	}
-	return m.cols, vk1
+	return m.cols, vk1, nil
 }
 
 // Random set a random normal value (0, 1) for each element of the
@@ -1136,19 +1005,46 @@ func (m *Matrix) Random(seed ...uint64) *Matrix {
 		s = seed[0]
 	}
 	r := rand.NewRand(s)
+	if m.isView() {
+		for i := 0; i < m.rows; i++ {
+			for j := 0; j < m.cols; j++ {
+				m.matrix[i][j] = r.Normal()
+			}
+		}
+		return m
+	}
 	for i := 0; i < len(m.data); i++ {
 		m.data[i] = r.Normal()
 	}
 	return m
 }
 
-// ToVector wraps a vector around m.data.
+// ToVector wraps a vector around m.data. For a view (see Slice), it
+// returns a copy of the rows instead.
 func (m *Matrix) ToVector() Vector {
+	if m.isView() {
+		v := make(Vector, 0, m.rows*m.cols)
+		for i := 0; i < m.rows; i++ {
+			v = append(v, m.matrix[i]...)
+		}
+		return v
+	}
 	return m.data
 }
 
 // ColumnMax compute the mean of each column of a Matrix.
 func (m *Matrix) ColumnMax(c int) float64 {
+	s, err := m.ColumnMaxErr(c)
+	must(err)
+	return s
+}
+
+// ColumnMaxErr is like ColumnMax but returns an *UnknownColumnError
+// instead of panicking when all data in the column are unknown.
+func (m *Matrix) ColumnMaxErr(c int) (float64, error) {
+	if c < 0 || c >= m.cols {
+		return 0, &IndexError{Op: "ColumnMax", Index: c, Len: m.cols}
+	}
 	s := float64(-1e308)
 	count := 0
 	for i := 0; i < m.rows; i++ {
@@ -1160,14 +1056,26 @@ func (m *Matrix) ColumnMax(c int) float64 {
 			s = m.matrix[i][c]
 		}
 	}
-	Require(count != 0, "\n%s %d (%s) %s\n",
-		"Matrix: ColumnMax: all data in column",
-		c, m.attrName[c], "are unknown!")
-	return s
+	if count == 0 {
+		return 0, &UnknownColumnError{Op: "ColumnMax", Col: c,
+			Name: m.attrName[c]}
+	}
+	return s, nil
 }
 
 // ColumnMin compute the mean of each column of a Matrix.
 func (m *Matrix) ColumnMin(c int) float64 {
+	s, err := m.ColumnMinErr(c)
+	must(err)
+	return s
+}
+
+// ColumnMinErr is like ColumnMin but returns an *UnknownColumnError
+// instead of panicking when all data in the column are unknown.
+func (m *Matrix) ColumnMinErr(c int) (float64, error) {
+	if c < 0 || c >= m.cols {
+		return 0, &IndexError{Op: "ColumnMin", Index: c, Len: m.cols}
+	}
 	s := float64(1e308)
 	count := 0
 	for i := 0; i < m.rows; i++ {
@@ -1179,14 +1087,27 @@ func (m *Matrix) ColumnMin(c int) float64 {
 			s = m.matrix[i][c]
 		}
 	}
-	Require(count != 0, "\n%s %d (%s) %s\n",
-		"Matrix: ColumnMin: all data in column",
-		c, m.attrName[c], "are unknown!")
-	return s
+	if count == 0 {
+		return 0, &UnknownColumnError{Op: "ColumnMin", Col: c,
+			Name: m.attrName[c]}
+	}
+	return s, nil
 }
 
 // ColumnMean compute the mean of each column of a Matrix.
 func (m *Matrix) ColumnMean(c int) float64 {
+	s, err := m.ColumnMeanErr(c)
+	must(err)
+	return s
+}
+
+// ColumnMeanErr is like ColumnMean but returns an
+// *UnknownColumnError instead of panicking when all data in the
+// column are unknown.
+func (m *Matrix) ColumnMeanErr(c int) (float64, error) {
+	if c < 0 || c >= m.cols {
+		return 0, &IndexError{Op: "ColumnMean", Index: c, Len: m.cols}
+	}
 	s := float64(0)
 	var count int = 0
 	for i := 0; i < m.rows; i++ {
@@ -1195,13 +1116,26 @@ func (m *Matrix) ColumnMean(c int) float64 {
 			count++
 		}
 	}
-	Require(count != 0, "\n%s %d (%s) %s\n",
-		"Matrix: ColumnMean: all data in column",
-		c, m.attrName[c], "are unknown!")
-	return s / float64(count)
+	if count == 0 {
+		return 0, &UnknownColumnError{Op: "ColumnMean", Col: c,
+			Name: m.attrName[c]}
+	}
+	return s / float64(count), nil
 }
 
 func (m *Matrix) MostCommonValue(c int) float64 {
+	v, err := m.MostCommonValueErr(c)
+	must(err)
+	return v
+}
+
+// MostCommonValueErr is like MostCommonValue but returns an
+// *UnknownColumnError instead of panicking when all data in the
+// column are unknown.
+func (m *Matrix) MostCommonValueErr(c int) (float64, error) {
+	if c < 0 || c >= m.cols {
+		return 0, &IndexError{Op: "MostCommonValue", Index: c, Len: m.cols}
+	}
 	list := make(map[float64]int)
 	count := 0
 	for i := 0; i < m.rows; i++ {
@@ -1210,9 +1144,10 @@ func (m *Matrix) MostCommonValue(c int) float64 {
 			count++
 		}
 	}
-	Require(count != 0, "\n%s %d (%s) %s\n",
-		"Matrix: MostCommonValue: all data in column",
-		c, m.attrName[c], "are unknown!")
+	if count == 0 {
+		return 0, &UnknownColumnError{Op: "MostCommonValue", Col: c,
+			Name: m.attrName[c]}
+	}
 	max := 0
 	retval := 0.0
 	for key, value := range list {
@@ -1221,10 +1156,12 @@ func (m *Matrix) MostCommonValue(c int) float64 {
 			retval = key
 		}
 	}
-	return retval
+	return retval, nil
 }
 
 // OLS return the weights in approximating labels = M*features + b.
+// The weights of the features come first and b is the last weight, as
+// with the Intercept option of LeastSquareWith.
 func OLS(features, labels *Matrix) Vector {
 	x := NewMatrix(features.Rows(), features.Cols()+1, nil)
 	y := NewMatrix(labels.Rows(), labels.Cols(), nil)
@@ -1236,12 +1173,15 @@ func OLS(features, labels *Matrix) Vector {
 	return x.LeastSquare(y).ToVector()
 }
 
-// Shuffle shuffles the rows of the matrix.
+// Shuffle shuffles the rows of the matrix and their weights.
 func (m *Matrix) Shuffle() {
-	r := rand.NewRand(uint64(time.Now().UnixNano()))
-	for rr := m.rows; rr > 1; rr-- {
-		l := int(r.Next(uint64(rr)))
-		m.SwapRows(rr-1, l)
-		m.SwapRows(rr-1, l)
-	}
+	m.ShuffleWith(rand.NewRand(uint64(time.Now().UnixNano())))
+}
+
+// ShuffleWith shuffles the rows of the matrix, and their weights, with
+// r, so that the order can be reproduced from the seed of r.
+func (m *Matrix) ShuffleWith(r *rand.Rand) {
+	r.Shuffle(m.rows, func(i, j int) {
+		m.SwapRows(i, j)
+	})
 }
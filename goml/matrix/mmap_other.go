@@ -0,0 +1,15 @@
+//go:build !unix
+
+package matrix
+
+import "io"
+
+// mmapFile reads fileName into memory on platforms without mmap.
+func mmapFile(fileName string) ([]byte, io.Closer, error) {
+	return readWholeFile(fileName)
+}
+
+// bytesToVector decodes b as little-endian float64.
+func bytesToVector(b []byte) Vector {
+	return decodeVector(b)
+}
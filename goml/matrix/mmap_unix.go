@@ -0,0 +1,52 @@
+//go:build unix
+
+package matrix
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// mmapCloser unmaps a file mapped by mmapFile.
+type mmapCloser []byte
+
+func (b mmapCloser) Close() error {
+	return syscall.Munmap(b)
+}
+
+// mmapFile maps fileName read-only into memory.
+func mmapFile(fileName string) ([]byte, io.Closer, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if fi.Size() == 0 || !littleEndian() {
+		return readWholeFile(fileName)
+	}
+	b, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()),
+		syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, mmapCloser(b), nil
+}
+
+// bytesToVector returns a Vector that shares its memory with b. b must
+// be aligned on 8 bytes, which is the case for the data block of a
+// mapped file, and hold little-endian float64.
+func bytesToVector(b []byte) Vector {
+	if len(b) == 0 {
+		return Vector{}
+	}
+	if !littleEndian() || uintptr(unsafe.Pointer(&b[0]))%8 != 0 {
+		return decodeVector(b)
+	}
+	return Vector(unsafe.Slice((*float64)(unsafe.Pointer(&b[0])), len(b)/8))
+}
@@ -0,0 +1,167 @@
+package matrix
+
+import (
+	"runtime"
+	"sync"
+)
+
+const (
+	// blockK and blockJ are the sizes of the tiles of the inner and
+	// column dimensions so that a tile of b stays in cache while it is
+	// used by many rows of a.
+	blockK = 256
+	blockJ = 512
+	blockI = 64
+
+	// parallelWork is the number of multiply-adds above which the
+	// work is split among goroutines.
+	parallelWork = 1 << 18
+)
+
+// parallelRows calls f on disjoint ranges [i0, i1) covering [0, n),
+// one range per worker goroutine if work is large enough and in the
+// calling goroutine otherwise.
+func parallelRows(n, work int, f func(i0, i1 int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
+	if work < parallelWork || workers < 2 {
+		f(0, n)
+		return
+	}
+	var wg sync.WaitGroup
+	chunk := (n + workers - 1) / workers
+	for i0 := 0; i0 < n; i0 += chunk {
+		i1 := i0 + chunk
+		if i1 > n {
+			i1 = n
+		}
+		wg.Add(1)
+		go func(i0, i1 int) {
+			defer wg.Done()
+			f(i0, i1)
+		}(i0, i1)
+	}
+	wg.Wait()
+}
+
+// mul stores op(a)*op(b) in m, which must already have the right
+// size and must not share memory with a or b. For every element the
+// products are summed in increasing order of the inner index, so the
+// result does not depend on the tiling or on the number of workers.
+func (m *Matrix) mul(a, b *Matrix, aTranspose, bTranspose bool) {
+	inner := a.cols
+	if aTranspose {
+		inner = a.rows
+	}
+	for i := 0; i < m.rows; i++ {
+		m.matrix[i].Fill(0)
+	}
+	work := m.rows * m.cols * inner
+	switch {
+	case !aTranspose && !bTranspose:
+		parallelRows(m.rows, work, func(i0, i1 int) {
+			mulNN(m, a, b, i0, i1, inner)
+		})
+	case aTranspose && !bTranspose:
+		parallelRows(m.rows, work, func(i0, i1 int) {
+			mulTN(m, a, b, i0, i1, inner)
+		})
+	case !aTranspose && bTranspose:
+		parallelRows(m.rows, work, func(i0, i1 int) {
+			mulNT(m, a, b, i0, i1, inner)
+		})
+	default:
+		parallelRows(m.rows, work, func(i0, i1 int) {
+			mulTT(m, a, b, i0, i1, inner)
+		})
+	}
+}
+
+// mulNN computes rows [i0, i1) of a*b.
+func mulNN(m, a, b *Matrix, i0, i1, inner int) {
+	for kk := 0; kk < inner; kk += blockK {
+		kEnd := min(kk+blockK, inner)
+		for jj := 0; jj < m.cols; jj += blockJ {
+			jEnd := min(jj+blockJ, m.cols)
+			for i := i0; i < i1; i++ {
+				mi := m.matrix[i][jj:jEnd]
+				ai := a.matrix[i]
+				for k := kk; k < kEnd; k++ {
+					axpy(ai[k], b.matrix[k][jj:jEnd], mi)
+				}
+			}
+		}
+	}
+}
+
+// mulTN computes rows [i0, i1) of a*b where a is transposed.
+func mulTN(m, a, b *Matrix, i0, i1, inner int) {
+	for ii := i0; ii < i1; ii += blockI {
+		iEnd := min(ii+blockI, i1)
+		for jj := 0; jj < m.cols; jj += blockJ {
+			jEnd := min(jj+blockJ, m.cols)
+			for k := 0; k < inner; k++ {
+				ak := a.matrix[k]
+				bk := b.matrix[k][jj:jEnd]
+				for i := ii; i < iEnd; i++ {
+					axpy(ak[i], bk, m.matrix[i][jj:jEnd])
+				}
+			}
+		}
+	}
+}
+
+// mulNT computes rows [i0, i1) of a*b where b is transposed.
+func mulNT(m, a, b *Matrix, i0, i1, inner int) {
+	for jj := 0; jj < m.cols; jj += blockI {
+		jEnd := min(jj+blockI, m.cols)
+		for i := i0; i < i1; i++ {
+			ai := a.matrix[i][:inner]
+			mi := m.matrix[i]
+			for j := jj; j < jEnd; j++ {
+				mi[j] = dot(ai, b.matrix[j][:inner])
+			}
+		}
+	}
+}
+
+// mulTT computes rows [i0, i1) of a*b where both are transposed. The
+// column of a is copied to a contiguous buffer first.
+func mulTT(m, a, b *Matrix, i0, i1, inner int) {
+	col := make(Vector, inner)
+	for i := i0; i < i1; i++ {
+		for k := 0; k < inner; k++ {
+			col[k] = a.matrix[k][i]
+		}
+		mi := m.matrix[i]
+		for j := 0; j < m.cols; j++ {
+			mi[j] = dot(col, b.matrix[j][:inner])
+		}
+	}
+}
+
+// axpy adds alpha*x to y.
+func axpy(alpha float64, x, y Vector) {
+	y = y[:len(x)]
+	for i, v := range x {
+		y[i] += alpha * v
+	}
+}
+
+// dot is Vector.Dot without the size check.
+func dot(x, y Vector) float64 {
+	y = y[:len(x)]
+	d := float64(0)
+	for i, v := range x {
+		d += v * y[i]
+	}
+	return d
+}
+
+// sharesData reports whether the data of m is read by a, e.g. when a
+// is m itself or a view of m.
+func (m *Matrix) sharesData(a *Matrix) bool {
+	return m == a || overlaps(m.data, a.matrix)
+}
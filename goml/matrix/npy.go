@@ -0,0 +1,359 @@
+package matrix
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// npyMagic starts every NumPy .npy file.
+const npyMagic = "\x93NUMPY"
+
+// LoadNPY loads a tensor from a NumPy .npy file. As in LoadIDX, the
+// dimensions of the tensor are the shape of the array in reverse
+// order, so that a 2-D array of shape (rows, cols) gives a tensor
+// whose ToMatrix is a rows-by-cols matrix. Arrays in Fortran order are
+// rearranged accordingly. Boolean, integer and floating point arrays
+// are supported.
+func LoadNPY(fileName string) (*Tensor, error) {
+	r, err := openFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	t, err := ReadNPY(r)
+	if err != nil {
+		return nil, fmt.Errorf("matrix: LoadNPY: %s: %v", fileName, err)
+	}
+	return t, nil
+}
+
+// LoadNPZ loads all arrays from a NumPy .npz archive. The arrays are
+// keyed by their names without the ".npy" extension.
+func LoadNPZ(fileName string) (map[string]*Tensor, error) {
+	z, err := zip.OpenReader(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	arrays := make(map[string]*Tensor, len(z.File))
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		t, err := ReadNPY(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("matrix: LoadNPZ: %s: %s: %v",
+				fileName, f.Name, err)
+		}
+		arrays[strings.TrimSuffix(f.Name, ".npy")] = t
+	}
+	return arrays, nil
+}
+
+// ReadNPY reads a tensor in the NumPy .npy format from r. See
+// LoadNPY.
+func ReadNPY(r io.Reader) (*Tensor, error) {
+	br := bufio.NewReader(r)
+	var pre [8]byte
+	if _, err := io.ReadFull(br, pre[:]); err != nil {
+		return nil, err
+	}
+	if string(pre[:6]) != npyMagic {
+		return nil, errors.New("not a .npy file")
+	}
+	var headerLen int
+	switch pre[6] {
+	case 1:
+		var n uint16
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		headerLen = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		headerLen = int(n)
+	default:
+		return nil, fmt.Errorf("unsupported .npy version %d.%d",
+			pre[6], pre[7])
+	}
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, err
+	}
+	descr, fortran, shape, err := parseNPYHeader(string(header))
+	if err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if descr[0] == '>' {
+		order = binary.BigEndian
+	}
+	width, err := strconv.Atoi(descr[2:])
+	if err != nil {
+		return nil, fmt.Errorf("unsupported dtype %q", descr)
+	}
+	var conv func(b []byte) float64
+	switch descr[1:] {
+	case "f8":
+		conv = func(b []byte) float64 {
+			return math.Float64frombits(order.Uint64(b))
+		}
+	case "f4":
+		conv = func(b []byte) float64 {
+			return float64(math.Float32frombits(order.Uint32(b)))
+		}
+	case "i1":
+		conv = func(b []byte) float64 { return float64(int8(b[0])) }
+	case "u1", "b1":
+		conv = func(b []byte) float64 { return float64(b[0]) }
+	case "i2":
+		conv = func(b []byte) float64 { return float64(int16(order.Uint16(b))) }
+	case "u2":
+		conv = func(b []byte) float64 { return float64(order.Uint16(b)) }
+	case "i4":
+		conv = func(b []byte) float64 { return float64(int32(order.Uint32(b))) }
+	case "u4":
+		conv = func(b []byte) float64 { return float64(order.Uint32(b)) }
+	case "i8":
+		conv = func(b []byte) float64 { return float64(int64(order.Uint64(b))) }
+	case "u8":
+		conv = func(b []byte) float64 { return float64(order.Uint64(b)) }
+	default:
+		return nil, fmt.Errorf("unsupported dtype %q", descr)
+	}
+
+	size, err := elemCount(shape)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readElems(br, size, width, conv)
+	if err != nil {
+		return nil, err
+	}
+
+	dims := make([]int, len(shape))
+	for i := range shape {
+		dims[i] = shape[len(shape)-1-i]
+	}
+	if len(dims) == 0 { // scalar
+		dims = []int{1}
+	}
+	if fortran && len(shape) > 1 {
+		data = fortranToC(data, shape)
+	}
+	return NewTensor(data, dims), nil
+}
+
+// fortranToC rearranges the elements of an array of the given shape
+// from Fortran (column-major) order to C (row-major) order.
+func fortranToC(data Vector, shape []int) Vector {
+	n := len(shape)
+	out := make(Vector, len(data))
+	idx := make([]int, n)
+	for c := range out {
+		// offset of idx in Fortran order
+		f := 0
+		for k := n - 1; k >= 0; k-- {
+			f = f*shape[k] + idx[k]
+		}
+		out[c] = data[f]
+		// increment idx in C order
+		for k := n - 1; k >= 0; k-- {
+			idx[k]++
+			if idx[k] < shape[k] {
+				break
+			}
+			idx[k] = 0
+		}
+	}
+	return out
+}
+
+// parseNPYHeader parses the Python dictionary literal in the header of
+// a .npy file, e.g. {'descr': '<f8', 'fortran_order': False,
+// 'shape': (3, 4), }.
+func parseNPYHeader(h string) (string, bool, []int, error) {
+	value := func(key string) (string, error) {
+		k := strings.Index(h, "'"+key+"'")
+		if k < 0 {
+			return "", fmt.Errorf("missing %q in header", key)
+		}
+		s := strings.TrimLeft(h[k+len(key)+2:], " :")
+		switch {
+		case strings.HasPrefix(s, "'"):
+			end := strings.IndexByte(s[1:], '\'')
+			if end < 0 {
+				return "", fmt.Errorf("invalid %q in header", key)
+			}
+			return s[1 : end+1], nil
+		case strings.HasPrefix(s, "("):
+			end := strings.IndexByte(s, ')')
+			if end < 0 {
+				return "", fmt.Errorf("invalid %q in header", key)
+			}
+			return s[1:end], nil
+		default:
+			end := strings.IndexAny(s, ",}")
+			if end < 0 {
+				return "", fmt.Errorf("invalid %q in header", key)
+			}
+			return strings.TrimSpace(s[:end]), nil
+		}
+	}
+	descr, err := value("descr")
+	if err != nil {
+		return "", false, nil, err
+	}
+	if len(descr) < 3 || strings.IndexByte("<>|=", descr[0]) < 0 {
+		return "", false, nil, fmt.Errorf("unsupported dtype %q", descr)
+	}
+	f, err := value("fortran_order")
+	if err != nil {
+		return "", false, nil, err
+	}
+	s, err := value("shape")
+	if err != nil {
+		return "", false, nil, err
+	}
+	var shape []int
+	for _, d := range strings.Split(s, ",") {
+		d = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(d), "L"))
+		if d == "" {
+			continue
+		}
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			return "", false, nil, fmt.Errorf("invalid shape (%s)", s)
+		}
+		shape = append(shape, n)
+	}
+	return descr, f == "True", shape, nil
+}
+
+// writeNPYHeader writes the header of a .npy file (version 1.0) that
+// describes a float64 array in C order.
+func writeNPYHeader(w io.Writer, shape []int) error {
+	var b strings.Builder
+	b.WriteString("{'descr': '<f8', 'fortran_order': False, 'shape': (")
+	for i, d := range shape {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.Itoa(d))
+	}
+	if len(shape) == 1 {
+		b.WriteString(",")
+	}
+	b.WriteString("), }")
+	// pad with spaces so that the data is aligned on 64 bytes
+	n := len(npyMagic) + 4 + b.Len() + 1
+	b.WriteString(strings.Repeat(" ", (64-n%64)%64))
+	b.WriteString("\n")
+
+	var pre [10]byte
+	copy(pre[:], npyMagic)
+	pre[6] = 1
+	binary.LittleEndian.PutUint16(pre[8:], uint16(b.Len()))
+	if _, err := w.Write(pre[:]); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeNPYData writes v as little-endian float64.
+func writeNPYData(w io.Writer, v Vector) error {
+	buf := make([]byte, 8*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint64(buf[8*i:], math.Float64bits(x))
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// WriteNPY writes the tensor to w in the NumPy .npy format. The shape
+// of the array is the dimensions of the tensor in reverse order, as
+// in LoadNPY, so that no element has to be moved.
+func (t *Tensor) WriteNPY(w io.Writer) error {
+	shape := make([]int, len(t.dims))
+	for i := range t.dims {
+		shape[i] = t.dims[len(t.dims)-1-i]
+	}
+	bw := bufio.NewWriter(w)
+	if err := writeNPYHeader(bw, shape); err != nil {
+		return err
+	}
+	if err := writeNPYData(bw, t.Data()); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// SaveNPY saves the tensor to a NumPy .npy file. See WriteNPY.
+func (t *Tensor) SaveNPY(fileName string) error {
+	return saveNPY(fileName, t.WriteNPY)
+}
+
+// WriteNPY writes the matrix to w as a 2-D float64 array in the NumPy
+// .npy format. Metadata such as attribute names are not written.
+func (m *Matrix) WriteNPY(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := writeNPYHeader(bw, []int{m.rows, m.cols}); err != nil {
+		return err
+	}
+	for i := 0; i < m.rows; i++ {
+		if err := writeNPYData(bw, m.matrix[i]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// SaveNPY saves the matrix to a NumPy .npy file. See WriteNPY.
+func (m *Matrix) SaveNPY(fileName string) error {
+	return saveNPY(fileName, m.WriteNPY)
+}
+
+func saveNPY(fileName string, write func(io.Writer) error) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadNPYMatrix loads a 1-D or 2-D array from a NumPy .npy file as a
+// matrix. A 1-D array gives a column vector.
+func LoadNPYMatrix(fileName string) (*Matrix, error) {
+	t, err := LoadNPY(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if len(t.dims) > 2 {
+		return nil, fmt.Errorf("matrix: LoadNPYMatrix: %s: %d-D array",
+			fileName, len(t.dims))
+	}
+	if len(t.dims) == 2 && t.dims[0] == 0 {
+		return nil, fmt.Errorf("matrix: LoadNPYMatrix: %s: array has no columns",
+			fileName)
+	}
+	return t.ToMatrix(), nil
+}
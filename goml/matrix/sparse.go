@@ -0,0 +1,374 @@
+package matrix
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// SparseVector is a vector of length Len whose only nonzero elements
+// are Value[k] at Index[k]. Index is increasing.
+type SparseVector struct {
+	Len   int
+	Index []int
+	Value Vector
+}
+
+// ToSparse returns the nonzero elements of v as a sparse vector.
+func (v Vector) ToSparse() *SparseVector {
+	s := &SparseVector{Len: len(v)}
+	for i, x := range v {
+		if x != 0 {
+			s.Index = append(s.Index, i)
+			s.Value = append(s.Value, x)
+		}
+	}
+	return s
+}
+
+// NNZ returns the number of stored elements.
+func (s *SparseVector) NNZ() int {
+	return len(s.Index)
+}
+
+// Dense returns s as a dense vector.
+func (s *SparseVector) Dense() Vector {
+	v := make(Vector, s.Len)
+	for k, i := range s.Index {
+		v[i] = s.Value[k]
+	}
+	return v
+}
+
+// Dot returns the dot product of s and the dense vector v.
+func (s *SparseVector) Dot(v Vector) float64 {
+	Require(s.Len == len(v),
+		"SparseVector.Dot: dimension mismatch: %d != %d\n", s.Len, len(v))
+	d := float64(0)
+	for k, i := range s.Index {
+		d += s.Value[k] * v[i]
+	}
+	return d
+}
+
+// SparseFormat is the storage format of a SparseMatrix.
+type SparseFormat int
+
+const (
+	// CSR (compressed sparse row) stores the matrix row by row. Rows
+	// are cheap to read, which suits feeding examples to a learner.
+	CSR SparseFormat = iota
+
+	// CSC (compressed sparse column) stores the matrix column by
+	// column.
+	CSC
+)
+
+func (f SparseFormat) String() string {
+	if f == CSC {
+		return "CSC"
+	}
+	return "CSR"
+}
+
+// SparseMatrix is a matrix that stores only its nonzero elements, in
+// CSR or CSC format. In CSR format the column indices and values of
+// row i are idx[ptr[i]:ptr[i+1]] and val[ptr[i]:ptr[i+1]]; in CSC
+// format the roles of rows and columns are swapped. Indices are
+// increasing within a row (column).
+//
+// A SparseMatrix has no metadata. UNKNOWN_VALUE is stored as any other
+// nonzero value.
+type SparseMatrix struct {
+	rows, cols int
+	format     SparseFormat
+	ptr        []int
+	idx        []int
+	val        Vector
+}
+
+// NewSparseMatrix creates a rows-by-cols sparse matrix in the given
+// format from triplets: the element at (i[k], j[k]) is v[k].
+// Duplicated entries are summed and zeros are dropped.
+func NewSparseMatrix(rows, cols int, i, j []int, v []float64,
+	format SparseFormat) *SparseMatrix {
+	Require(len(i) == len(j) && len(j) == len(v),
+		"NewSparseMatrix: require len(i) == len(j) == len(v)\n")
+	major, minor := i, j
+	nMajor := rows
+	if format == CSC {
+		major, minor = j, i
+		nMajor = cols
+	}
+	s := &SparseMatrix{rows: rows, cols: cols, format: format,
+		ptr: make([]int, nMajor+1)}
+
+	// sort the triplets by (major, minor)
+	order := make([]int, len(v))
+	for k := range order {
+		Require(0 <= i[k] && i[k] < rows && 0 <= j[k] && j[k] < cols,
+			"NewSparseMatrix: index (%d, %d) out of bound\n", i[k], j[k])
+		order[k] = k
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ka, kb := order[a], order[b]
+		if major[ka] != major[kb] {
+			return major[ka] < major[kb]
+		}
+		return minor[ka] < minor[kb]
+	})
+
+	s.idx = make([]int, 0, len(v))
+	s.val = make(Vector, 0, len(v))
+	last := -1
+	for n, k := range order {
+		if n > 0 && major[k] == major[last] && minor[k] == minor[last] {
+			s.val[len(s.val)-1] += v[k]
+		} else {
+			s.idx = append(s.idx, minor[k])
+			s.val = append(s.val, v[k])
+			s.ptr[major[k]+1]++
+		}
+		last = k
+	}
+	for p := 0; p < nMajor; p++ {
+		s.ptr[p+1] += s.ptr[p]
+	}
+	s.dropZeros()
+	return s
+}
+
+// dropZeros removes the stored zeros, e.g. after duplicates cancel.
+func (s *SparseMatrix) dropZeros() {
+	n := 0
+	start := 0
+	for p := 0; p+1 < len(s.ptr); p++ {
+		end := s.ptr[p+1]
+		for k := start; k < end; k++ {
+			if s.val[k] != 0 {
+				s.idx[n] = s.idx[k]
+				s.val[n] = s.val[k]
+				n++
+			}
+		}
+		start = end
+		s.ptr[p+1] = n
+	}
+	s.idx = s.idx[:n]
+	s.val = s.val[:n]
+}
+
+// ToSparse returns the nonzero elements of m as a sparse matrix in the
+// given format.
+func (m *Matrix) ToSparse(format SparseFormat) *SparseMatrix {
+	s := &SparseMatrix{rows: m.rows, cols: m.cols, format: CSR,
+		ptr: make([]int, m.rows+1)}
+	for i := 0; i < m.rows; i++ {
+		for j, x := range m.matrix[i] {
+			if x != 0 {
+				s.idx = append(s.idx, j)
+				s.val = append(s.val, x)
+			}
+		}
+		s.ptr[i+1] = len(s.idx)
+	}
+	if format == CSC {
+		return s.ToCSC()
+	}
+	return s
+}
+
+// ToMatrix returns s as a dense matrix.
+func (s *SparseMatrix) ToMatrix() *Matrix {
+	m := NewMatrix(s.rows, s.cols, nil)
+	s.DoNonZero(func(i, j int, v float64) {
+		m.matrix[i][j] = v
+	})
+	return m
+}
+
+// Rows returns the number of rows.
+func (s *SparseMatrix) Rows() int {
+	return s.rows
+}
+
+// Cols returns the number of columns.
+func (s *SparseMatrix) Cols() int {
+	return s.cols
+}
+
+// Size returns the number of rows and columns.
+func (s *SparseMatrix) Size() (int, int) {
+	return s.rows, s.cols
+}
+
+// NNZ returns the number of stored elements.
+func (s *SparseMatrix) NNZ() int {
+	return len(s.val)
+}
+
+// Format returns the storage format of s.
+func (s *SparseMatrix) Format() SparseFormat {
+	return s.format
+}
+
+// GetElem returns the element at row i and column j.
+func (s *SparseMatrix) GetElem(i, j int) float64 {
+	Require(0 <= i && i < s.rows && 0 <= j && j < s.cols,
+		"SparseMatrix.GetElem: index (%d, %d) out of bound\n", i, j)
+	major, minor := i, j
+	if s.format == CSC {
+		major, minor = j, i
+	}
+	idx := s.idx[s.ptr[major]:s.ptr[major+1]]
+	k := sort.SearchInts(idx, minor)
+	if k < len(idx) && idx[k] == minor {
+		return s.val[s.ptr[major]+k]
+	}
+	return 0
+}
+
+// Transpose returns the transpose of s. It shares the storage of s: a
+// CSR matrix becomes a CSC matrix and vice versa.
+func (s *SparseMatrix) Transpose() *SparseMatrix {
+	t := *s
+	t.rows, t.cols = s.cols, s.rows
+	t.format = CSR + CSC - s.format
+	return &t
+}
+
+// convert returns a copy of s in the other format.
+func (s *SparseMatrix) convert() *SparseMatrix {
+	nMajor, nMinor := s.rows, s.cols
+	if s.format == CSC {
+		nMajor, nMinor = nMinor, nMajor
+	}
+	t := &SparseMatrix{rows: s.rows, cols: s.cols,
+		format: CSR + CSC - s.format,
+		ptr:    make([]int, nMinor+1),
+		idx:    make([]int, len(s.idx)),
+		val:    make(Vector, len(s.val))}
+	for _, q := range s.idx {
+		t.ptr[q+1]++
+	}
+	for q := 0; q < nMinor; q++ {
+		t.ptr[q+1] += t.ptr[q]
+	}
+	next := make([]int, nMinor)
+	copy(next, t.ptr)
+	for p := 0; p < nMajor; p++ {
+		for k := s.ptr[p]; k < s.ptr[p+1]; k++ {
+			q := s.idx[k]
+			t.idx[next[q]] = p
+			t.val[next[q]] = s.val[k]
+			next[q]++
+		}
+	}
+	return t
+}
+
+// ToCSR returns s in CSR format. It returns s itself if it already is.
+func (s *SparseMatrix) ToCSR() *SparseMatrix {
+	if s.format == CSR {
+		return s
+	}
+	return s.convert()
+}
+
+// ToCSC returns s in CSC format. It returns s itself if it already is.
+func (s *SparseMatrix) ToCSC() *SparseMatrix {
+	if s.format == CSC {
+		return s
+	}
+	return s.convert()
+}
+
+// Row returns row i of s. For a CSR matrix the returned vector shares
+// its storage with s; for a CSC matrix it is gathered from the
+// columns.
+func (s *SparseMatrix) Row(i int) *SparseVector {
+	Require(i >= 0 && i < s.rows,
+		"SparseMatrix.Row: index out of bound: r = %d\n", i)
+	if s.format == CSR {
+		a, b := s.ptr[i], s.ptr[i+1]
+		return &SparseVector{Len: s.cols, Index: s.idx[a:b:b],
+			Value: s.val[a:b:b]}
+	}
+	r := &SparseVector{Len: s.cols}
+	for j := 0; j < s.cols; j++ {
+		if x := s.GetElem(i, j); x != 0 {
+			r.Index = append(r.Index, j)
+			r.Value = append(r.Value, x)
+		}
+	}
+	return r
+}
+
+// DoRow calls f for every stored element of row i, in increasing
+// order of columns.
+func (s *SparseMatrix) DoRow(i int, f func(j int, v float64)) {
+	r := s.Row(i)
+	for k, j := range r.Index {
+		f(j, r.Value[k])
+	}
+}
+
+// DoNonZero calls f for every stored element, row by row for a CSR
+// matrix and column by column for a CSC matrix.
+func (s *SparseMatrix) DoNonZero(f func(i, j int, v float64)) {
+	for p := 0; p+1 < len(s.ptr); p++ {
+		for k := s.ptr[p]; k < s.ptr[p+1]; k++ {
+			if s.format == CSR {
+				f(p, s.idx[k], s.val[k])
+			} else {
+				f(s.idx[k], p, s.val[k])
+			}
+		}
+	}
+}
+
+// MulVec returns s*x.
+func (s *SparseMatrix) MulVec(x Vector) Vector {
+	Require(len(x) == s.cols,
+		"SparseMatrix.MulVec: dimension mismatch: %d != %d\n", len(x), s.cols)
+	y := make(Vector, s.rows)
+	s.DoNonZero(func(i, j int, v float64) {
+		y[i] += v * x[j]
+	})
+	return y
+}
+
+// MulDense returns the dense product s*b. Only the nonzero elements
+// of s are visited.
+func (s *SparseMatrix) MulDense(b *Matrix) (*Matrix, error) {
+	if s.cols != b.rows {
+		return nil, &DimensionError{Op: "SparseMatrix.MulDense",
+			Rows: s.rows, Cols: s.cols, R: b.rows, C: b.cols}
+	}
+	m := NewMatrix(s.rows, b.cols, nil)
+	if s.format == CSR {
+		parallelRows(s.rows, len(s.val)*b.cols, func(i0, i1 int) {
+			for i := i0; i < i1; i++ {
+				for k := s.ptr[i]; k < s.ptr[i+1]; k++ {
+					axpy(s.val[k], b.matrix[s.idx[k]], m.matrix[i])
+				}
+			}
+		})
+	} else {
+		s.DoNonZero(func(i, j int, v float64) {
+			axpy(v, b.matrix[j], m.matrix[i])
+		})
+	}
+	return m, nil
+}
+
+// String prints the stored elements of s.
+func (s *SparseMatrix) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d-by-%d %v sparse matrix with %d nonzeros\n",
+		s.rows, s.cols, s.format, len(s.val))
+	s.DoNonZero(func(i, j int, v float64) {
+		fmt.Fprintf(&buf, "  (%d, %d) %g\n", i, j, v)
+	})
+	return buf.String()
+}
@@ -0,0 +1,303 @@
+package matrix
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DEFAULT_BINS is the number of histogram bins used by Describe.
+const DEFAULT_BINS = 10
+
+// ColumnSummary holds the descriptive statistics of a column (or a row)
+// of a Matrix. Unknown values are counted in Missing and ignored
+// otherwise. Statistics that cannot be computed, e.g. the variance of
+// a single value, are NaN.
+type ColumnSummary struct {
+	Name string
+	Type string // "real", "nominal", "string" or "date"
+
+	Count   int // number of known values
+	Missing int // number of UNKNOWN_VALUE
+
+	Min, Max float64
+	Mean     float64
+	Var      float64 // sample variance (divided by Count-1)
+	Std      float64
+	Skewness float64 // g1 = m3/m2^(3/2) with population moments
+
+	Q1, Median, Q3 float64 // quartiles with linear interpolation
+
+	// Histogram[k] counts the values in [Edges[k], Edges[k+1]); the
+	// last bin also includes Max. Both are nil for nominal columns.
+	Histogram []int
+	Edges     []float64
+
+	// Freq counts the values of a nominal or string column by name,
+	// and Mode is the most frequent one. Both are empty for numeric
+	// columns.
+	Freq map[string]int
+	Mode string
+}
+
+// Summary is the result of Describe. It prints as a table.
+type Summary []ColumnSummary
+
+// moments accumulates the count and the first three central moments
+// of a sequence in one pass.
+type moments struct {
+	n            int
+	mean, m2, m3 float64
+	min, max     float64
+}
+
+func (s *moments) add(x float64) {
+	if s.n == 0 || x < s.min {
+		s.min = x
+	}
+	if s.n == 0 || x > s.max {
+		s.max = x
+	}
+	n1 := float64(s.n)
+	s.n++
+	n := float64(s.n)
+	delta := x - s.mean
+	deltaN := delta / n
+	term := delta * deltaN * n1
+	s.mean += deltaN
+	s.m3 += term*deltaN*(n-2) - 3*deltaN*s.m2
+	s.m2 += term
+}
+
+// quantile returns the p-quantile of the sorted values v using linear
+// interpolation between closest ranks.
+func quantile(v []float64, p float64) float64 {
+	if len(v) == 0 {
+		return math.NaN()
+	}
+	h := p * float64(len(v)-1)
+	lo := int(math.Floor(h))
+	if lo >= len(v)-1 {
+		return v[len(v)-1]
+	}
+	return v[lo] + (h-float64(lo))*(v[lo+1]-v[lo])
+}
+
+// summarize fills the numeric fields of s from the moments and the
+// known values, which are sorted in place.
+func (s *ColumnSummary) summarize(mo *moments, values []float64, bins int) {
+	nan := math.NaN()
+	s.Count = mo.n
+	s.Min, s.Max, s.Mean = nan, nan, nan
+	s.Var, s.Std, s.Skewness = nan, nan, nan
+	s.Q1, s.Median, s.Q3 = nan, nan, nan
+	if mo.n == 0 {
+		return
+	}
+	s.Min, s.Max, s.Mean = mo.min, mo.max, mo.mean
+	if mo.n > 1 {
+		s.Var = mo.m2 / float64(mo.n-1)
+		s.Std = math.Sqrt(s.Var)
+	}
+	if mo.m2 > 0 {
+		n := float64(mo.n)
+		s.Skewness = math.Sqrt(n) * mo.m3 / math.Pow(mo.m2, 1.5)
+	}
+	sort.Float64s(values)
+	s.Q1 = quantile(values, 0.25)
+	s.Median = quantile(values, 0.5)
+	s.Q3 = quantile(values, 0.75)
+
+	if bins <= 0 {
+		return
+	}
+	s.Histogram = make([]int, bins)
+	s.Edges = make([]float64, bins+1)
+	width := (s.Max - s.Min) / float64(bins)
+	for k := range s.Edges {
+		s.Edges[k] = s.Min + float64(k)*width
+	}
+	s.Edges[bins] = s.Max
+	for _, x := range values {
+		k := bins - 1
+		if width > 0 {
+			k = int((x - s.Min) / width)
+			if k >= bins {
+				k = bins - 1
+			}
+		}
+		s.Histogram[k]++
+	}
+}
+
+// Describe computes the descriptive statistics of every column in one
+// pass over the data. Nominal and string columns get a frequency table
+// keyed by value names instead of numeric statistics. The optional
+// argument is the number of histogram bins (DEFAULT_BINS by default).
+func (m *Matrix) Describe(bins ...int) Summary {
+	nbins := DEFAULT_BINS
+	if len(bins) > 0 {
+		nbins = bins[0]
+	}
+	mo := make([]moments, m.cols)
+	values := make([][]float64, m.cols)
+	counts := make([]map[int]int, m.cols)
+	sum := make(Summary, m.cols)
+	for j := 0; j < m.cols; j++ {
+		sum[j].Name = m.attrName[j]
+		sum[j].Type = m.enum_to_str[j][ATTR_NAME]
+		if m.isNominal(j) {
+			counts[j] = make(map[int]int)
+		} else {
+			values[j] = make([]float64, 0, m.rows)
+		}
+	}
+
+	for i := 0; i < m.rows; i++ {
+		for j, x := range m.matrix[i] {
+			switch {
+			case x == UNKNOWN_VALUE:
+				sum[j].Missing++
+			case counts[j] != nil:
+				counts[j][int(x)]++
+			default:
+				mo[j].add(x)
+				values[j] = append(values[j], x)
+			}
+		}
+	}
+
+	for j := 0; j < m.cols; j++ {
+		if counts[j] == nil {
+			sum[j].summarize(&mo[j], values[j], nbins)
+			continue
+		}
+		s := &sum[j]
+		nan := math.NaN()
+		s.Min, s.Max, s.Mean = nan, nan, nan
+		s.Var, s.Std, s.Skewness = nan, nan, nan
+		s.Q1, s.Median, s.Q3 = nan, nan, nan
+		s.Freq = make(map[string]int, len(counts[j]))
+		best := -1
+		for v, c := range counts[j] {
+			name, ok := m.enum_to_str[j][v]
+			if !ok {
+				name = fmt.Sprint(v)
+			}
+			s.Freq[name] += c
+			s.Count += c
+			if c > best || (c == best && name < s.Mode) {
+				best = c
+				s.Mode = name
+			}
+		}
+	}
+	return sum
+}
+
+// DescribeRows computes the numeric statistics of every row, treating
+// all columns as real. Name is the row index.
+func (m *Matrix) DescribeRows(bins ...int) Summary {
+	nbins := DEFAULT_BINS
+	if len(bins) > 0 {
+		nbins = bins[0]
+	}
+	sum := make(Summary, m.rows)
+	values := make([]float64, 0, m.cols)
+	for i := 0; i < m.rows; i++ {
+		var mo moments
+		values = values[:0]
+		sum[i].Name = fmt.Sprint(i)
+		sum[i].Type = "real"
+		for _, x := range m.matrix[i] {
+			if x == UNKNOWN_VALUE {
+				sum[i].Missing++
+				continue
+			}
+			mo.add(x)
+			values = append(values, x)
+		}
+		sum[i].summarize(&mo, values, nbins)
+	}
+	return sum
+}
+
+// isNominal reports whether column j holds nominal or string values.
+func (m *Matrix) isNominal(j int) bool {
+	t := m.enum_to_str[j][ATTR_NAME]
+	return len(t) > 0 && (t[0] == 'n' || t[0] == 's')
+}
+
+// String prints the summary with one column per line.
+func (s Summary) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%-12s %-8s %7s %7s %12s %12s %12s %12s %12s %12s %9s\n",
+		"name", "type", "count", "missing", "mean", "std", "min",
+		"median", "max", "iqr", "skewness")
+	for _, c := range s {
+		name := c.Name
+		if len(name) > 12 {
+			name = name[:12]
+		}
+		fmt.Fprintf(&buf, "%-12s %-8s %7d %7d ", name, c.Type, c.Count, c.Missing)
+		if c.Freq != nil {
+			fmt.Fprintf(&buf, "mode %q (%d of %d values)\n", c.Mode,
+				c.Freq[c.Mode], len(c.Freq))
+			continue
+		}
+		fmt.Fprintf(&buf, "%12.5g %12.5g %12.5g %12.5g %12.5g %12.5g %9.4g\n",
+			c.Mean, c.Std, c.Min, c.Median, c.Max, c.Q3-c.Q1, c.Skewness)
+	}
+	return buf.String()
+}
+
+// Covariance returns the sample covariance matrix of the columns of
+// m. Each entry is computed from the rows where both columns are
+// known (pairwise deletion); it is NaN if there are fewer than two
+// such rows. Nominal columns enter through their numeric codes. The
+// columns of the result are named after the columns of m.
+func (m *Matrix) Covariance() *Matrix {
+	return m.covariance(false)
+}
+
+// Correlation returns the Pearson correlation matrix of the columns
+// of m, with unknown values handled as in Covariance.
+func (m *Matrix) Correlation() *Matrix {
+	return m.covariance(true)
+}
+
+func (m *Matrix) covariance(normalize bool) *Matrix {
+	c := NewMatrix(m.cols, m.cols, nil)
+	copy(c.attrName, m.attrName)
+	for j := 0; j < m.cols; j++ {
+		for k := j; k < m.cols; k++ {
+			// one pass with Welford's update of the co-moment
+			var n, mx, my, cxy, cxx, cyy float64
+			for i := 0; i < m.rows; i++ {
+				x, y := m.matrix[i][j], m.matrix[i][k]
+				if x == UNKNOWN_VALUE || y == UNKNOWN_VALUE {
+					continue
+				}
+				n++
+				dx := x - mx
+				mx += dx / n
+				dy := y - my
+				my += dy / n
+				cxy += dx * (y - my)
+				cxx += dx * (x - mx)
+				cyy += dy * (y - my)
+			}
+			v := math.NaN()
+			if n > 1 {
+				if normalize {
+					v = cxy / math.Sqrt(cxx*cyy)
+				} else {
+					v = cxy / (n - 1)
+				}
+			}
+			c.matrix[j][k], c.matrix[k][j] = v, v
+		}
+	}
+	return c
+}
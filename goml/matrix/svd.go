@@ -0,0 +1,228 @@
+package matrix
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// SVD is the singular value decomposition A = USV* of an m-by-n matrix
+// A, where S is diagonal with non-negative entries in decreasing order
+// and U and V have orthonormal columns. In the thin decomposition U is
+// m-by-k and V is n-by-k with k = min(m, n); in the full
+// decomposition U is m-by-m and V is n-by-n.
+type SVD struct {
+	u, v *Matrix
+	s    Vector
+}
+
+// maxSweeps bounds the number of sweeps of the Jacobi methods.
+const maxSweeps = 60
+
+// NewSVD computes the singular value decomposition of a using the
+// one-sided Jacobi method, which is slower than Golub-Kahan but
+// computes small singular values to high relative accuracy. If full
+// is true, U and V are completed to square orthogonal matrices. The
+// matrix a is not modified.
+func NewSVD(a *Matrix, full bool) (*SVD, error) {
+	transposed := a.rows < a.cols
+	if transposed {
+		a = a.Transpose()
+	}
+	m, n := a.rows, a.cols
+
+	// w[j] is column j of AV and v[j] is column j of V.
+	w := a.Transpose()
+	v := identity(n)
+	for i := 0; i < m; i++ {
+		for j := 0; j < n; j++ {
+			if x := a.matrix[i][j]; math.IsNaN(x) || math.IsInf(x, 0) {
+				return nil, errors.New("matrix: SVD: matrix contains NaN or Inf")
+			}
+		}
+	}
+
+	converged := false
+	for sweep := 0; sweep < maxSweeps && !converged; sweep++ {
+		converged = true
+		for p := 0; p < n-1; p++ {
+			for q := p + 1; q < n; q++ {
+				wp, wq := w.matrix[p], w.matrix[q]
+				alpha := wp.Dot(wp)
+				beta := wq.Dot(wq)
+				gamma := wp.Dot(wq)
+				if gamma == 0 || math.Abs(gamma) <= ESP*math.Sqrt(alpha*beta) {
+					continue
+				}
+				converged = false
+				zeta := (beta - alpha) / (2 * gamma)
+				t := 1 / (math.Abs(zeta) + math.Sqrt(1+zeta*zeta))
+				if zeta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(1+t*t)
+				s := c * t
+				rotate(wp, wq, c, s)
+				rotate(v.matrix[p], v.matrix[q], c, s)
+			}
+		}
+	}
+	if !converged {
+		return nil, ErrNoConvergence
+	}
+
+	// singular values in decreasing order
+	sigma := make(valuesort, n)
+	for j := 0; j < n; j++ {
+		sigma[j].val = w.matrix[j].Norm(2)
+		sigma[j].idx = j
+	}
+	sort.Stable(sigma)
+
+	k := n
+	ucols, vcols := k, k
+	if full {
+		ucols, vcols = m, n
+	}
+	f := &SVD{s: make(Vector, k)}
+	ut := NewMatrix(ucols, m, nil) // rows are the columns of U
+	vt := NewMatrix(vcols, n, nil) // rows are the columns of V
+	rank := 0
+	for j := 0; j < k; j++ {
+		s := sigma[j].val
+		f.s[j] = s
+		copy(vt.matrix[j], v.matrix[sigma[j].idx])
+		if s > 0 {
+			copy(ut.matrix[j], w.matrix[sigma[j].idx])
+			ut.matrix[j].Scale(1 / s)
+			rank = j + 1
+		}
+	}
+	// columns of U for zero singular values and the full decomposition
+	completeBasis(ut, rank)
+
+	f.u, f.v = ut.Transpose(), vt.Transpose()
+	if transposed {
+		f.u, f.v = f.v, f.u
+	}
+	return f, nil
+}
+
+// rotate applies the plane rotation [c s; -s c] to the pair (x, y).
+func rotate(x, y Vector, c, s float64) {
+	for i := range x {
+		xi, yi := x[i], y[i]
+		x[i] = c*xi - s*yi
+		y[i] = s*xi + c*yi
+	}
+}
+
+// completeBasis replaces rows k, k+1, ... of q by vectors that make
+// the rows of q orthonormal, given that the first k rows already are.
+func completeBasis(q *Matrix, k int) {
+	e := 0 // next canonical vector to try
+	for j := k; j < q.rows; j++ {
+		for ; e < q.cols; e++ {
+			qj := q.matrix[j]
+			qj.Fill(0)
+			qj[e] = 1
+			// Gram-Schmidt twice for numerical orthogonality
+			for pass := 0; pass < 2; pass++ {
+				for i := 0; i < j; i++ {
+					d := qj.Dot(q.matrix[i])
+					for l := range qj {
+						qj[l] -= d * q.matrix[i][l]
+					}
+				}
+			}
+			if norm := qj.Norm(2); norm > 0.5 {
+				qj.Scale(1 / norm)
+				e++
+				break
+			}
+		}
+	}
+}
+
+// Values returns the singular values in decreasing order.
+func (f *SVD) Values() Vector {
+	s := make(Vector, len(f.s))
+	copy(s, f.s)
+	return s
+}
+
+// U returns the left singular vectors as columns.
+func (f *SVD) U() *Matrix {
+	return f.u.clone()
+}
+
+// V returns the right singular vectors as columns.
+func (f *SVD) V() *Matrix {
+	return f.v.clone()
+}
+
+// tolerance returns the default threshold below which a singular value
+// is considered to be zero.
+func (f *SVD) tolerance(tol []float64) float64 {
+	if len(tol) > 0 {
+		return tol[0]
+	}
+	if len(f.s) == 0 {
+		return 0
+	}
+	max := f.u.rows
+	if f.v.rows > max {
+		max = f.v.rows
+	}
+	return float64(max) * f.s[0] * 2.2e-16
+}
+
+// Rank returns the number of singular values greater than tol. The
+// default tolerance is max(m, n)*s[0]*eps where eps is the machine
+// epsilon.
+func (f *SVD) Rank(tol ...float64) int {
+	t := f.tolerance(tol)
+	r := 0
+	for _, s := range f.s {
+		if s > t {
+			r++
+		}
+	}
+	return r
+}
+
+// Cond returns the 2-norm condition number of A, which is +Inf if A
+// is rank deficient, that is if its smallest singular value is not
+// greater than the default tolerance of Rank.
+func (f *SVD) Cond() float64 {
+	if len(f.s) == 0 {
+		return math.Inf(1)
+	}
+	last := f.s[len(f.s)-1]
+	if last <= f.tolerance(nil) {
+		return math.Inf(1)
+	}
+	return f.s[0] / last
+}
+
+// PseudoInverse returns the Moore-Penrose pseudo-inverse of A, an
+// n-by-m matrix. Singular values not greater than tol are treated as
+// zero, with the same default as Rank.
+func (f *SVD) PseudoInverse(tol ...float64) *Matrix {
+	r := f.Rank(tol...)
+	m, n := f.u.rows, f.v.rows
+	p := NewMatrix(n, m, nil)
+	for k := 0; k < r; k++ {
+		s := 1 / f.s[k]
+		for i := 0; i < n; i++ {
+			vik := f.v.matrix[i][k] * s
+			if vik == 0 {
+				continue
+			}
+			for j := 0; j < m; j++ {
+				p.matrix[i][j] += vik * f.u.matrix[j][k]
+			}
+		}
+	}
+	return p
+}
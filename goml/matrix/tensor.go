@@ -1,128 +1,415 @@
 package matrix
 
-//import (
-//"fmt"
-//)
+import (
+	"bytes"
+	"fmt"
+)
 
+// Tensor is an N-dimensional array stored in a Vector. The first
+// dimension changes fastest (column-major order), so a w-by-h image is
+// a Tensor with dims {w, h} whose element (x, y) is at x + w*y.
+//
+// Permute, Slice and Index return views that share the storage of the
+// tensor and may be non-contiguous, i.e. their elements are not
+// consecutive in the underlying vector. Functions that need
+// consecutive elements, such as Convolve and ToMatrix, call
+// Contiguous, which copies a non-contiguous tensor.
 type Tensor struct {
-	data Vector
-	dims []int
+	data    Vector
+	dims    []int
+	strides []int // strides[i] is the step in data along axis i
+	offset  int   // position of the first element in data
 }
 
+// NewTensor wraps a tensor with the given dims around v, or allocates
+// a zero tensor if v is nil.
 func NewTensor(v Vector, dims []int) *Tensor {
 	tot := 1
 	for i := 0; i < len(dims); i++ {
 		tot *= dims[i]
 	}
+	if v == nil {
+		v = make(Vector, tot)
+	}
 	Require(tot == len(v),
 		"NewTensor: size mismatched: Tensor (%d) != Vector (%d)\n",
 		tot, len(v))
 	var t Tensor
 	t.dims = make([]int, len(dims))
 	copy(t.dims, dims)
+	t.strides = columnMajor(dims)
 	t.data = v
 	return &t
 }
 
-func Convolve(in, filter, out *Tensor, flipFilter bool, stride int) {
-	// Precompute some values
-	dc := len(in.dims)
-	Require(dc == len(filter.dims) && dc == len(out.dims),
-		"tensor: Convolve: Expected tensors with the same number of dimensions")
-	kinner := make([]int, 5*dc)
-	kouter := kinner[dc:]
-	stepInner := kouter[dc:]
-	stepFilter := stepInner[dc:]
-	stepOuter := stepFilter[dc:]
-
-	// Compute step sizes
-	stepInner[0] = 1
-	stepFilter[0] = 1
-	stepOuter[0] = 1
-	for i := 1; i < dc; i++ {
-		stepInner[i] = stepInner[i-1] * in.dims[i-1]
-		stepFilter[i] = stepFilter[i-1] * filter.dims[i-1]
-		stepOuter[i] = stepOuter[i-1] * out.dims[i-1]
-	}
-	filterTail := stepFilter[dc-1]*filter.dims[dc-1] - 1
-
-	// Do convolution
-	var op, ip, fp int
-	var padding, min, adj int
-	for i := 0; i < dc; i++ {
-		kouter[i] = 0
-		kinner[i] = 0
-		padding = (stride*(out.dims[i]-1) + filter.dims[i] - in.dims[i]) / 2
-		min = padding
-		if min > kouter[i] {
-			min = kouter[i]
+// columnMajor returns the strides of a contiguous tensor with dims.
+func columnMajor(dims []int) []int {
+	strides := make([]int, len(dims))
+	step := 1
+	for i, d := range dims {
+		strides[i] = step
+		step *= d
+	}
+	return strides
+}
+
+// Dims returns the dimensions of the tensor. The first dimension
+// changes fastest in the underlying vector.
+func (t *Tensor) Dims() []int {
+	return t.dims
+}
+
+// Size returns the number of elements of the tensor.
+func (t *Tensor) Size() int {
+	n := 1
+	for _, d := range t.dims {
+		n *= d
+	}
+	return n
+}
+
+// Data returns the elements of the tensor in column-major order. The
+// result shares the storage of the tensor if it is contiguous and is a
+// copy otherwise.
+func (t *Tensor) Data() Vector {
+	if t.IsContiguous() {
+		return t.data[t.offset : t.offset+t.Size()]
+	}
+	return t.Clone().data
+}
+
+// IsContiguous reports whether the elements of the tensor are
+// consecutive in column-major order in the underlying vector.
+func (t *Tensor) IsContiguous() bool {
+	step := 1
+	for i, d := range t.dims {
+		if d > 1 && t.strides[i] != step {
+			return false
 		}
-		adj = (padding - min) - kinner[i]
-		kinner[i] += adj
-		fp += adj * stepFilter[i]
+		step *= d
 	}
-	for {
-		val := 0.0
-
-		// Fix up the initial kinner positions
-		for i := 0; i < dc; i++ {
-			padding = (stride*(out.dims[i]-1) + filter.dims[i] - in.dims[i]) / 2
-			min = padding
-			if min > kouter[i] {
-				min = kouter[i]
-			}
-			adj = (padding - min) - kinner[i]
-			kinner[i] += adj
-			fp += adj * stepFilter[i]
-			ip += adj * stepInner[i]
+	return true
+}
+
+// Contiguous returns t if it is contiguous and a copy of t otherwise.
+func (t *Tensor) Contiguous() *Tensor {
+	if t.IsContiguous() {
+		return t
+	}
+	return t.Clone()
+}
+
+// Clone returns a contiguous copy of the tensor.
+func (t *Tensor) Clone() *Tensor {
+	c := NewTensor(nil, t.dims)
+	k := 0
+	walk(t.dims, []*Tensor{t}, func(off []int) {
+		c.data[k] = t.data[off[0]]
+		k++
+	})
+	return c
+}
+
+// Offset returns the position of the element at the multi-index idx in
+// the underlying vector of the tensor.
+func (t *Tensor) Offset(idx ...int) int {
+	Require(len(idx) == len(t.dims),
+		"Tensor: expected %d indices but got %d\n", len(t.dims), len(idx))
+	off := t.offset
+	for i, k := range idx {
+		Require(0 <= k && k < t.dims[i],
+			"Tensor: index %d out of bound for axis %d of size %d\n",
+			k, i, t.dims[i])
+		off += k * t.strides[i]
+	}
+	return off
+}
+
+// At returns the element at the multi-index idx.
+func (t *Tensor) At(idx ...int) float64 {
+	return t.data[t.Offset(idx...)]
+}
+
+// Set sets the element at the multi-index idx to val.
+func (t *Tensor) Set(val float64, idx ...int) {
+	t.data[t.Offset(idx...)] = val
+}
+
+// Reshape returns a tensor with the same elements in column-major order
+// and the given dims. One of the dims may be -1, in which case it is
+// inferred from the size. The result shares the storage of t if t is
+// contiguous.
+func (t *Tensor) Reshape(dims ...int) *Tensor {
+	size := t.Size()
+	infer := -1
+	n := 1
+	for i, d := range dims {
+		if d == -1 {
+			Require(infer < 0, "Reshape: only one dimension can be -1\n")
+			infer = i
+			continue
 		}
-		for {
-			if flipFilter {
-				val += in.data[ip] * filter.data[filterTail-fp]
-			} else {
-				val += in.data[ip] * filter.data[fp]
-			}
+		n *= d
+	}
+	shape := make([]int, len(dims))
+	copy(shape, dims)
+	if infer >= 0 {
+		Require(n > 0 && size%n == 0,
+			"Reshape: cannot reshape %d elements into %v\n", size, dims)
+		shape[infer] = size / n
+		n = size
+	}
+	Require(n == size, "Reshape: cannot reshape %d elements into %v\n",
+		size, dims)
+	return NewTensor(t.Data(), shape)
+}
 
-			// increment the kinner position
-			var i int
-			for i = 0; i < dc; i++ {
-				kinner[i]++
-				ip += stepInner[i]
-				fp += stepFilter[i]
-				padding = (stride*(out.dims[i]-1) + filter.dims[i] - in.dims[i]) / 2
-				if kinner[i] < filter.dims[i] && kouter[i]+kinner[i]-padding < in.dims[i] {
-					break
-				}
-				min = padding
-				if min > kouter[i] {
-					min = kouter[i]
-				}
-				adj = (padding - min) - kinner[i]
-				kinner[i] += adj
-				fp += adj * stepFilter[i]
-				ip += adj * stepInner[i]
+// Permute returns a view of the tensor with its axes reordered: axis i
+// of the result is axis axes[i] of t. For a matrix-like tensor,
+// Permute(1, 0) is the transpose.
+func (t *Tensor) Permute(axes ...int) *Tensor {
+	Require(len(axes) == len(t.dims),
+		"Permute: expected %d axes but got %d\n", len(t.dims), len(axes))
+	p := &Tensor{data: t.data, offset: t.offset,
+		dims: make([]int, len(axes)), strides: make([]int, len(axes))}
+	seen := make([]bool, len(axes))
+	for i, a := range axes {
+		Require(0 <= a && a < len(t.dims) && !seen[a],
+			"Permute: %v is not a permutation of the axes\n", axes)
+		seen[a] = true
+		p.dims[i] = t.dims[a]
+		p.strides[i] = t.strides[a]
+	}
+	return p
+}
+
+// Slice returns a view of the elements of the tensor whose index
+// along axis is in [start, end).
+func (t *Tensor) Slice(axis, start, end int) *Tensor {
+	Require(0 <= axis && axis < len(t.dims),
+		"Slice: axis %d out of bound\n", axis)
+	Require(0 <= start && start <= end && end <= t.dims[axis],
+		"Slice: invalid range [%d, %d) for axis of size %d\n",
+		start, end, t.dims[axis])
+	s := &Tensor{data: t.data, offset: t.offset + start*t.strides[axis],
+		dims: make([]int, len(t.dims)), strides: make([]int, len(t.dims))}
+	copy(s.dims, t.dims)
+	copy(s.strides, t.strides)
+	s.dims[axis] = end - start
+	return s
+}
+
+// Index returns a view of the elements of the tensor whose index along
+// axis is i, with that axis removed. For instance, Index(2, c) of a
+// w-by-h-by-channels image is its channel c.
+func (t *Tensor) Index(axis, i int) *Tensor {
+	s := t.Slice(axis, i, i+1)
+	s.dims = append(s.dims[:axis], s.dims[axis+1:]...)
+	s.strides = append(s.strides[:axis], s.strides[axis+1:]...)
+	return s
+}
+
+// Concat joins tensors along an existing axis. All other dims must
+// agree. The result is a new contiguous tensor.
+func Concat(axis int, ts ...*Tensor) *Tensor {
+	Require(len(ts) > 0, "Concat: no tensors\n")
+	dims := make([]int, len(ts[0].dims))
+	copy(dims, ts[0].dims)
+	Require(0 <= axis && axis < len(dims), "Concat: axis %d out of bound\n", axis)
+	dims[axis] = 0
+	for _, t := range ts {
+		Require(len(t.dims) == len(dims),
+			"Concat: tensors must have the same number of dimensions\n")
+		for i, d := range t.dims {
+			Require(i == axis || d == dims[i],
+				"Concat: dims %v and %v do not agree\n", ts[0].dims, t.dims)
+		}
+		dims[axis] += t.dims[axis]
+	}
+	c := NewTensor(nil, dims)
+	start := 0
+	for _, t := range ts {
+		end := start + t.dims[axis]
+		c.Slice(axis, start, end).Copy(t)
+		start = end
+	}
+	return c
+}
+
+// Stack joins tensors of the same dims along a new axis, which is
+// inserted before axis (len(dims) appends it). For instance, stacking
+// w-by-h images along axis 2 gives a w-by-h-by-n tensor.
+func Stack(axis int, ts ...*Tensor) *Tensor {
+	Require(len(ts) > 0, "Stack: no tensors\n")
+	n := len(ts[0].dims)
+	Require(0 <= axis && axis <= n, "Stack: axis %d out of bound\n", axis)
+	expanded := make([]*Tensor, len(ts))
+	for k, t := range ts {
+		Require(len(t.dims) == n, "Stack: tensors must have the same dims\n")
+		e := &Tensor{data: t.data, offset: t.offset}
+		e.dims = append(append(append([]int{}, t.dims[:axis]...), 1), t.dims[axis:]...)
+		e.strides = append(append(append([]int{}, t.strides[:axis]...), 0), t.strides[axis:]...)
+		expanded[k] = e
+	}
+	return Concat(axis, expanded...)
+}
+
+// Copy copies the elements of src into t. Both must have the same
+// dims.
+func (t *Tensor) Copy(src *Tensor) *Tensor {
+	t.sameDims("Copy", src)
+	walk(t.dims, []*Tensor{t, src}, func(off []int) {
+		t.data[off[0]] = src.data[off[1]]
+	})
+	return t
+}
+
+// Fill sets every element of t to val.
+func (t *Tensor) Fill(val float64) *Tensor {
+	walk(t.dims, []*Tensor{t}, func(off []int) {
+		t.data[off[0]] = val
+	})
+	return t
+}
+
+func (t *Tensor) sameDims(op string, a *Tensor) {
+	ok := len(t.dims) == len(a.dims)
+	for i := 0; ok && i < len(t.dims); i++ {
+		ok = t.dims[i] == a.dims[i]
+	}
+	Require(ok, "Tensor.%s: dims %v and %v do not agree\n", op, t.dims, a.dims)
+}
+
+// elementwise stores op(a, b) in t elementwise. A zero Tensor receiver
+// is allocated with the dims of a.
+func (t *Tensor) elementwise(op string, a, b *Tensor, f func(x, y float64) float64) *Tensor {
+	a.sameDims(op, b)
+	if t.dims == nil {
+		*t = *NewTensor(nil, a.dims)
+	}
+	t.sameDims(op, a)
+	walk(t.dims, []*Tensor{t, a, b}, func(off []int) {
+		t.data[off[0]] = f(a.data[off[1]], b.data[off[2]])
+	})
+	return t
+}
+
+// Add stores a + b in the receiver and returns it. The receiver may be
+// one of the operands; a zero Tensor is allocated, so new(Tensor).Add(a,
+// b) returns a new tensor.
+func (t *Tensor) Add(a, b *Tensor) *Tensor {
+	return t.elementwise("Add", a, b, func(x, y float64) float64 { return x + y })
+}
+
+// Sub stores a - b in the receiver and returns it.
+func (t *Tensor) Sub(a, b *Tensor) *Tensor {
+	return t.elementwise("Sub", a, b, func(x, y float64) float64 { return x - y })
+}
+
+// MulElem stores the elementwise product of a and b in the receiver
+// and returns it.
+func (t *Tensor) MulElem(a, b *Tensor) *Tensor {
+	return t.elementwise("MulElem", a, b, func(x, y float64) float64 { return x * y })
+}
+
+// DivElem stores the elementwise quotient a / b in the receiver and
+// returns it.
+func (t *Tensor) DivElem(a, b *Tensor) *Tensor {
+	return t.elementwise("DivElem", a, b, func(x, y float64) float64 { return x / y })
+}
+
+// Apply stores f applied to every element of a in the receiver and
+// returns it.
+func (t *Tensor) Apply(a *Tensor, f func(float64) float64) *Tensor {
+	return t.elementwise("Apply", a, a, func(x, _ float64) float64 { return f(x) })
+}
+
+// Scale multiplies every element of t by c.
+func (t *Tensor) Scale(c float64) *Tensor {
+	walk(t.dims, []*Tensor{t}, func(off []int) {
+		t.data[off[0]] *= c
+	})
+	return t
+}
+
+// Sum returns the sum of the elements of t.
+func (t *Tensor) Sum() float64 {
+	s := float64(0)
+	walk(t.dims, []*Tensor{t}, func(off []int) {
+		s += t.data[off[0]]
+	})
+	return s
+}
+
+// walk calls f with the offsets in ts of every element, visiting the
+// multi-indices of dims in column-major order. All ts must have dims.
+func walk(dims []int, ts []*Tensor, f func(off []int)) {
+	for _, d := range dims {
+		if d == 0 {
+			return
+		}
+	}
+	off := make([]int, len(ts))
+	for k, t := range ts {
+		off[k] = t.offset
+	}
+	idx := make([]int, len(dims))
+	for {
+		f(off)
+		i := 0
+		for ; i < len(dims); i++ {
+			idx[i]++
+			for k, t := range ts {
+				off[k] += t.strides[i]
 			}
-			if i >= dc {
+			if idx[i] < dims[i] {
 				break
 			}
-		}
-		out.data[op] += val
-
-		// increment the kouter position
-		var i int
-		for i = 0; i < dc; i++ {
-			kouter[i]++
-			op += stepOuter[i]
-			ip += stride * stepInner[i]
-			if kouter[i] < out.dims[i] {
-				break
+			for k, t := range ts {
+				off[k] -= idx[i] * t.strides[i]
 			}
-			op -= kouter[i] * stepOuter[i]
-			ip -= kouter[i] * stride * stepInner[i]
-			kouter[i] = 0
+			idx[i] = 0
 		}
-		if i >= dc {
-			break
+		if i == len(dims) {
+			return
 		}
 	}
 }
+
+// String prints the dims and the elements of the tensor in
+// column-major order.
+func (t *Tensor) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Tensor %v", t.dims)
+	buf.WriteString(t.Data().String())
+	return buf.String()
+}
+
+// ToMatrix wraps a matrix around the tensor. Each row of the matrix
+// is a slice of the tensor along its last dimension, i.e., the
+// matrix has dims[len(dims)-1] rows. A non-contiguous tensor is copied
+// first.
+func (t *Tensor) ToMatrix() *Matrix {
+	n := len(t.dims)
+	data := t.Data()
+	if n == 0 {
+		return NewMatrix(1, 1, data)
+	}
+	// the column count comes from the dims, since data is empty when
+	// there are no rows
+	cols := 1
+	for _, d := range t.dims[:n-1] {
+		cols *= d
+	}
+	return NewMatrix(t.dims[n-1], cols, data)
+}
+
+// Convolve adds the convolution of in and filter to out, with the
+// filter centered so that the output has the dims of out. The filter
+// is flipped if flipFilter is set. It is ConvolveWith with PaddingAuto
+// and the given stride on every axis, computed directly; use
+// ConvolveWith with ConvFFT for long signals or big filters.
+func Convolve(in, filter, out *Tensor, flipFilter bool, stride int) {
+	ConvolveWith(in, filter, out, ConvOptions{Stride: []int{stride},
+		Flip: flipFilter, Method: ConvDirect})
+}
@@ -0,0 +1,293 @@
+package matrix
+
+import (
+	"unsafe"
+)
+
+// isView reports whether m is a view, see Slice.
+func (m *Matrix) isView() bool {
+	return m.data == nil && m.rows > 0
+}
+
+// compact copies the data of a view into a new data block, so that m
+// no longer shares memory with its parent.
+func (m *Matrix) compact() {
+	if !m.isView() {
+		return
+	}
+	data := make(Vector, m.rows*m.cols)
+	for i := 0; i < m.rows; i++ {
+		copy(data[i*m.cols:(i+1)*m.cols], m.matrix[i])
+		m.matrix[i] = data[i*m.cols : (i+1)*m.cols]
+	}
+	m.data = data
+}
+
+// view returns a view of m with the given rows (each capped to its
+// length) and the metadata of columns [c0, c1).
+func (m *Matrix) view(rows []Vector, c0, c1 int) *Matrix {
+	v := &Matrix{
+		matrix:   rows,
+		rows:     len(rows),
+		cols:     c1 - c0,
+		relation: m.relation,
+		location: m.location,
+		sparse:   m.sparse,
+	}
+	v.attrName = make([]string, v.cols)
+	copy(v.attrName, m.attrName[c0:c1])
+	v.str_to_enum = make([]map[string]int, v.cols)
+	copy(v.str_to_enum, m.str_to_enum[c0:c1])
+	v.enum_to_str = make([]map[int]string, v.cols)
+	copy(v.enum_to_str, m.enum_to_str[c0:c1])
+	return v
+}
+
+// Slice returns the submatrix of m made of rows [r0, r1) and columns
+// [c0, c1) without copying any data.
+//
+// The result is a view: its rows are slices of the rows of m, so
+// writing to it writes to m. A view has no data block of its own:
+// ToVector returns a copy, and AddRows and AddCols detach the view by
+// copying its data. Views share the nominal value dictionaries with m,
+// so adding a nominal value through one is seen by the other. The
+// weights of the rows are copied, since the rows of a view can be
+// reordered without reordering m. Use Clone for an independent copy.
+func (m *Matrix) Slice(r0, r1, c0, c1 int) *Matrix {
+	Require(0 <= r0 && r0 <= r1 && r1 <= m.rows,
+		"Slice: invalid row range [%d, %d) for %d rows\n", r0, r1, m.rows)
+	Require(0 <= c0 && c0 < c1 && c1 <= m.cols,
+		"Slice: invalid column range [%d, %d) for %d columns\n", c0, c1, m.cols)
+	rows := make([]Vector, r1-r0)
+	for i := range rows {
+		rows[i] = m.matrix[r0+i][c0:c1:c1]
+	}
+	v := m.view(rows, c0, c1)
+	if m.weight != nil {
+		v.weight = append(Vector(nil), m.weight[r0:r1]...)
+	}
+	return v
+}
+
+// RowView returns the matrix made of the given rows of m, in that
+// order, without copying any data. A row may be repeated, e.g. for a
+// bootstrap sample. The result is a view as in Slice.
+func (m *Matrix) RowView(rows []int) *Matrix {
+	r := make([]Vector, len(rows))
+	for k, i := range rows {
+		Require(0 <= i && i < m.rows,
+			"RowView: index out of bound: r = %d\n", i)
+		r[k] = m.matrix[i][:m.cols:m.cols]
+	}
+	v := m.view(r, 0, m.cols)
+	if m.weight != nil {
+		v.weight = make(Vector, len(rows))
+		for k, i := range rows {
+			v.weight[k] = m.weight[i]
+		}
+	}
+	return v
+}
+
+// Clone returns a deep copy of m, including its metadata, that
+// shares nothing with m. The rows of the copy are stored in their
+// current order.
+func (m *Matrix) Clone() *Matrix {
+	c := NewMatrix(m.rows, m.cols, nil)
+	for i := 0; i < m.rows; i++ {
+		copy(c.matrix[i], m.matrix[i])
+	}
+	c.CopyMetadata(m)
+	c.relation = m.relation
+	c.sparse = m.sparse
+	if m.weight != nil {
+		c.weight = make(Vector, len(m.weight))
+		copy(c.weight, m.weight)
+	}
+	return c
+}
+
+// Range returns the indices start, start+step, ... that are less than
+// end (greater than end if step is negative). It is a convenient way
+// to build strided index sets for View.
+func Range(start, end, step int) []int {
+	Require(step != 0, "Range: step cannot be 0\n")
+	var r []int
+	for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+		r = append(r, i)
+	}
+	return r
+}
+
+// View is a zero-copy view of a Matrix through arbitrary sets of row
+// and column indices, e.g. every other row or a selection of
+// features. Reading and writing an element goes to the underlying
+// matrix.
+type View struct {
+	m          *Matrix
+	rows, cols []int
+}
+
+// View returns a view of m through the given row and column indices.
+// A nil index set selects all rows (columns). Indices may repeat.
+func (m *Matrix) View(rows, cols []int) *View {
+	if rows == nil {
+		rows = Range(0, m.rows, 1)
+	}
+	if cols == nil {
+		cols = Range(0, m.cols, 1)
+	}
+	for _, i := range rows {
+		Require(0 <= i && i < m.rows, "View: row index out of bound: %d\n", i)
+	}
+	for _, j := range cols {
+		Require(0 <= j && j < m.cols, "View: column index out of bound: %d\n", j)
+	}
+	return &View{m: m, rows: rows, cols: cols}
+}
+
+// Rows returns the number of rows of the view.
+func (v *View) Rows() int {
+	return len(v.rows)
+}
+
+// Cols returns the number of columns of the view.
+func (v *View) Cols() int {
+	return len(v.cols)
+}
+
+// Size returns the number of rows and columns of the view.
+func (v *View) Size() (int, int) {
+	return len(v.rows), len(v.cols)
+}
+
+// GetElem returns the element at row i and column j of the view.
+func (v *View) GetElem(i, j int) float64 {
+	return v.m.matrix[v.rows[i]][v.cols[j]]
+}
+
+// SetElem sets the element at row i and column j of the view, and
+// thus of the underlying matrix.
+func (v *View) SetElem(i, j int, val float64) {
+	v.m.matrix[v.rows[i]][v.cols[j]] = val
+}
+
+// Row copies row i of the view into dst, which is allocated if it is
+// too short, and returns it.
+func (v *View) Row(i int, dst Vector) Vector {
+	if len(dst) < len(v.cols) {
+		dst = make(Vector, len(v.cols))
+	}
+	row := v.m.matrix[v.rows[i]]
+	for k, j := range v.cols {
+		dst[k] = row[j]
+	}
+	return dst[:len(v.cols)]
+}
+
+// Col returns a copy of column j of the view.
+func (v *View) Col(j int) Vector {
+	c := make(Vector, len(v.rows))
+	for k, i := range v.rows {
+		c[k] = v.m.matrix[i][v.cols[j]]
+	}
+	return c
+}
+
+// View returns a view of the view, with indices relative to v.
+func (v *View) View(rows, cols []int) *View {
+	w := &View{m: v.m, rows: v.rows, cols: v.cols}
+	if rows != nil {
+		w.rows = make([]int, len(rows))
+		for k, i := range rows {
+			w.rows[k] = v.rows[i]
+		}
+	}
+	if cols != nil {
+		w.cols = make([]int, len(cols))
+		for k, j := range cols {
+			w.cols[k] = v.cols[j]
+		}
+	}
+	return w
+}
+
+// Matrix returns the view as a Matrix. If the columns of the view are
+// a contiguous increasing range, the result is a view of the
+// underlying matrix that shares its data, as RowView; otherwise the
+// data are copied, as Clone.
+func (v *View) Matrix() *Matrix {
+	contiguous := len(v.cols) > 0
+	for k := 1; k < len(v.cols); k++ {
+		if v.cols[k] != v.cols[k-1]+1 {
+			contiguous = false
+			break
+		}
+	}
+	if contiguous {
+		c0, c1 := v.cols[0], v.cols[len(v.cols)-1]+1
+		rows := make([]Vector, len(v.rows))
+		for k, i := range v.rows {
+			rows[k] = v.m.matrix[i][c0:c1:c1]
+		}
+		m := v.m.view(rows, c0, c1)
+		m.weight = v.weights()
+		return m
+	}
+	return v.Clone()
+}
+
+// Clone returns a copy of the view as a new Matrix with the metadata
+// of the selected columns.
+func (v *View) Clone() *Matrix {
+	c := NewMatrix(len(v.rows), len(v.cols), nil)
+	for k := range v.rows {
+		v.Row(k, c.matrix[k])
+	}
+	c.relation = v.m.relation
+	c.location = v.m.location
+	c.sparse = v.m.sparse
+	for k, j := range v.cols {
+		c.attrName[k] = v.m.attrName[j]
+		for s, e := range v.m.str_to_enum[j] {
+			c.str_to_enum[k][s] = e
+		}
+		for e, s := range v.m.enum_to_str[j] {
+			c.enum_to_str[k][e] = s
+		}
+	}
+	c.weight = v.weights()
+	return c
+}
+
+// weights returns the instance weights of the rows of the view.
+func (v *View) weights() Vector {
+	if v.m.weight == nil {
+		return nil
+	}
+	w := make(Vector, len(v.rows))
+	for k, i := range v.rows {
+		w[k] = v.m.weight[i]
+	}
+	return w
+}
+
+// overlaps reports whether a row in rows points into data.
+func overlaps(data Vector, rows []Vector) bool {
+	if len(data) == 0 {
+		return false
+	}
+	lo := uintptr(unsafe.Pointer(&data[0]))
+	hi := uintptr(unsafe.Pointer(&data[len(data)-1]))
+	for _, r := range rows {
+		if len(r) == 0 {
+			continue
+		}
+		p := uintptr(unsafe.Pointer(&r[0]))
+		q := uintptr(unsafe.Pointer(&r[len(r)-1]))
+		if p <= hi && q >= lo {
+			return true
+		}
+	}
+	return false
+}
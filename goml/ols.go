@@ -76,13 +76,22 @@ func testMRepNFoldCV(m, n int) {
 	fmt.Printf("RMSE are %v and row = %d\n", sse, features.Rows())
 }
 
-func mnist(numPeriod int) {
-	var features, labels, testFeatures, testLabels matrix.Matrix
+// loadIDX loads an MNIST file (as downloaded from
+// http://yann.lecun.com/exdb/mnist/) into a matrix with one image or
+// label per row.
+func loadIDX(fileName string) *matrix.Matrix {
+	t, err := matrix.LoadIDX(fileName)
+	if err != nil {
+		panic(err)
+	}
+	return t.ToMatrix()
+}
 
-	features.LoadARFF("/home/hoangngan/courses/MIS/machineLearning/mnist/train_feat.arff")
-	labels.LoadARFF("/home/hoangngan/courses/MIS/machineLearning/mnist/train_lab.arff")
-	testFeatures.LoadARFF("/home/hoangngan/courses/MIS/machineLearning/mnist/test_feat.arff")
-	testLabels.LoadARFF("/home/hoangngan/courses/MIS/machineLearning/mnist/test_lab.arff")
+func mnist(numPeriod int) {
+	features := loadIDX("./mnist/train-images-idx3-ubyte.gz")
+	labels := loadIDX("./mnist/train-labels-idx1-ubyte.gz")
+	testFeatures := loadIDX("./mnist/t10k-images-idx3-ubyte.gz")
+	testLabels := loadIDX("./mnist/t10k-labels-idx1-ubyte.gz")
 	features.Scale(1.0 / 256.0)
 	testFeatures.Scale(1.0 / 256.0)
 
@@ -112,7 +121,7 @@ func mnist(numPeriod int) {
 	for i := 0; i < numPeriod; i++ {
 		fmt.Printf("Training %2d:... ", i)
 		innerStart = time.Now()
-		n.Train(&features, mlabels)
+		n.Train(features, mlabels)
 		fmt.Printf("%5.2fs\tCounting Misclassifications:... ",
 			time.Since(innerStart).Seconds())
 		mis = 0
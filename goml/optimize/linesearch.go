@@ -0,0 +1,155 @@
+package optimize
+
+import (
+	"math"
+
+	"../matrix"
+)
+
+// LineSearch finds a step along a descent direction.
+type LineSearch interface {
+	// Search returns a step a > 0 along the descent direction dir from
+	// x, where f has the value f0 and the slope g0 = grad(f)*dir < 0,
+	// trying the step a0 first. It stores x + a*dir in xNew and the
+	// gradient of f there in gNew and returns f(xNew). The error is
+	// ErrLineSearch if no acceptable step is found.
+	Search(f Function, x, dir matrix.Vector, f0, g0, a0 float64,
+		xNew, gNew matrix.Vector) (a, fNew float64, err error)
+}
+
+// eval evaluates f at x + a*dir into xNew and gNew.
+func eval(f Function, x, dir matrix.Vector, a float64, xNew, gNew matrix.Vector) float64 {
+	for i := range xNew {
+		xNew[i] = x[i] + a*dir[i]
+	}
+	return f.ValueGrad(xNew, gNew)
+}
+
+// Backtracking is the Armijo line search: it shrinks the step until f
+// decreases by at least C1 times the decrease predicted by the slope.
+type Backtracking struct {
+	C1      float64 // sufficient decrease; 0 means 1e-4
+	Shrink  float64 // factor applied to the step; 0 means 0.5
+	MaxEval int     // evaluations before failing; 0 means 50
+}
+
+// Search implements LineSearch.
+func (b *Backtracking) Search(f Function, x, dir matrix.Vector, f0, g0, a0 float64,
+	xNew, gNew matrix.Vector) (float64, float64, error) {
+	c1, shrink, maxEval := b.C1, b.Shrink, b.MaxEval
+	if c1 <= 0 {
+		c1 = 1e-4
+	}
+	if shrink <= 0 || shrink >= 1 {
+		shrink = 0.5
+	}
+	if maxEval <= 0 {
+		maxEval = 50
+	}
+	if g0 >= 0 {
+		return 0, f0, ErrLineSearch
+	}
+	a := a0
+	for i := 0; i < maxEval; i++ {
+		fa := eval(f, x, dir, a, xNew, gNew)
+		if fa <= f0+c1*a*g0 {
+			return a, fa, nil
+		}
+		a *= shrink
+	}
+	return 0, f0, ErrLineSearch
+}
+
+// Wolfe is a line search for the strong Wolfe conditions: sufficient
+// decrease f(a) <= f0 + C1*a*g0 and small slope |g(a)| <= C2*|g0|. It
+// expands the step until it brackets such a step and then zooms in by
+// cubic interpolation (Nocedal and Wright, Algorithms 3.5 and 3.6).
+type Wolfe struct {
+	C1      float64 // 0 means 1e-4
+	C2      float64 // 0 means 0.9
+	MaxEval int     // evaluations before failing; 0 means 50
+}
+
+// Search implements LineSearch.
+func (w *Wolfe) Search(f Function, x, dir matrix.Vector, f0, g0, a0 float64,
+	xNew, gNew matrix.Vector) (float64, float64, error) {
+	c1, c2, maxEval := w.C1, w.C2, w.MaxEval
+	if c1 <= 0 {
+		c1 = 1e-4
+	}
+	if c2 <= 0 {
+		c2 = 0.9
+	}
+	if maxEval <= 0 {
+		maxEval = 50
+	}
+	if g0 >= 0 {
+		return 0, f0, ErrLineSearch
+	}
+	evals := 0
+	at := func(a float64) (float64, float64) {
+		evals++
+		fa := eval(f, x, dir, a, xNew, gNew)
+		return fa, dot(gNew, dir)
+	}
+
+	// zoom finds a step between lo, which satisfies the sufficient
+	// decrease, and hi
+	zoom := func(lo, flo, glo, hi, fhi, ghi float64) (float64, float64, error) {
+		for evals < maxEval {
+			a := interpolate(lo, flo, glo, hi, fhi, ghi)
+			fa, ga := at(a)
+			if fa > f0+c1*a*g0 || fa >= flo {
+				hi, fhi, ghi = a, fa, ga
+				continue
+			}
+			if math.Abs(ga) <= -c2*g0 {
+				return a, fa, nil
+			}
+			if ga*(hi-lo) >= 0 {
+				hi, fhi, ghi = lo, flo, glo
+			}
+			lo, flo, glo = a, fa, ga
+		}
+		return 0, f0, ErrLineSearch
+	}
+
+	prev, fprev, gprev := 0.0, f0, g0
+	a := a0
+	for evals < maxEval {
+		fa, ga := at(a)
+		if fa > f0+c1*a*g0 || (evals > 1 && fa >= fprev) {
+			return zoom(prev, fprev, gprev, a, fa, ga)
+		}
+		if math.Abs(ga) <= -c2*g0 {
+			return a, fa, nil
+		}
+		if ga >= 0 {
+			return zoom(a, fa, ga, prev, fprev, gprev)
+		}
+		prev, fprev, gprev = a, fa, ga
+		a *= 2
+	}
+	return 0, f0, ErrLineSearch
+}
+
+// interpolate returns the minimizer of the cubic that matches f and its
+// slope at a and b, kept at least 10% of the interval away from its
+// ends, or the midpoint if there is no such minimizer.
+func interpolate(a, fa, ga, b, fb, gb float64) float64 {
+	lo, hi := math.Min(a, b), math.Max(a, b)
+	margin := 0.1 * (hi - lo)
+	d1 := ga + gb - 3*(fa-fb)/(a-b)
+	disc := d1*d1 - ga*gb
+	if disc >= 0 {
+		d2 := math.Sqrt(disc)
+		if b < a {
+			d2 = -d2
+		}
+		t := b - (b-a)*(gb+d2-d1)/(gb-ga+2*d2)
+		if t >= lo+margin && t <= hi-margin {
+			return t
+		}
+	}
+	return (lo + hi) / 2
+}
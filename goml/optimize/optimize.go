@@ -0,0 +1,425 @@
+// Package optimize minimizes functions of a matrix.Vector, e.g. the
+// training error of a model as a function of its weights. It offers
+// L-BFGS and nonlinear conjugate gradient, which need the gradient,
+// Nelder-Mead, which does not, and backtracking and Wolfe line
+// searches.
+//
+// Every method stops for a documented reason, reported as the Status
+// of the Result, so a run is reproducible given the same starting
+// point and Settings.
+package optimize
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"../matrix"
+)
+
+// Function is an objective to minimize. ValueGrad returns f(x) and, if
+// grad is not nil, stores the gradient of f at x in grad. It must not
+// keep x or grad.
+type Function interface {
+	ValueGrad(x, grad matrix.Vector) float64
+}
+
+// Func adapts an ordinary function to Function.
+type Func func(x, grad matrix.Vector) float64
+
+// ValueGrad calls f(x, grad).
+func (f Func) ValueGrad(x, grad matrix.Vector) float64 {
+	return f(x, grad)
+}
+
+// Method is the signature shared by LBFGS, NonlinearCG and NelderMead.
+type Method func(f Function, x0 matrix.Vector, s *Settings) (*Result, error)
+
+// Settings holds the stopping criteria and the options of the methods.
+// The zero value (or a nil *Settings) uses the defaults.
+type Settings struct {
+	// MaxIter bounds the number of iterations; 0 means 1000.
+	MaxIter int
+
+	// MaxEval bounds the number of evaluations of the function; 0
+	// means no bound.
+	MaxEval int
+
+	// GradTol stops the gradient methods when the infinity norm of the
+	// gradient is at most GradTol; 0 means 1e-6.
+	GradTol float64
+
+	// FuncTol stops a method when an iteration decreases f by at most
+	// FuncTol*max(1, |f|); 0 means 1e-12. For Nelder-Mead it bounds the
+	// spread of f over the simplex instead.
+	FuncTol float64
+
+	// LineSearch is the line search of the gradient methods; nil means
+	// a Wolfe line search (with C2 = 0.9 for L-BFGS and 0.1 for
+	// nonlinear CG).
+	LineSearch LineSearch
+
+	// Memory is the number of corrections kept by L-BFGS; 0 means 10.
+	Memory int
+
+	// Step is the size of the initial simplex of Nelder-Mead along each
+	// axis, relative to max(1, |x0_i|); 0 means 0.05.
+	Step float64
+}
+
+func (s *Settings) values() Settings {
+	var v Settings
+	if s != nil {
+		v = *s
+	}
+	if v.MaxIter <= 0 {
+		v.MaxIter = 1000
+	}
+	if v.GradTol <= 0 {
+		v.GradTol = 1e-6
+	}
+	if v.FuncTol <= 0 {
+		v.FuncTol = 1e-12
+	}
+	if v.Memory <= 0 {
+		v.Memory = 10
+	}
+	if v.Step <= 0 {
+		v.Step = 0.05
+	}
+	return v
+}
+
+// Status tells why a method stopped.
+type Status int
+
+const (
+	GradientConverged Status = iota // the gradient is at most GradTol
+	FunctionConverged               // f decreased by at most FuncTol
+	IterationLimit                  // MaxIter iterations were done
+	EvaluationLimit                 // MaxEval evaluations were done
+	LineSearchFailed                // no step decreased f enough
+)
+
+var statusNames = [...]string{"GradientConverged", "FunctionConverged",
+	"IterationLimit", "EvaluationLimit", "LineSearchFailed"}
+
+func (s Status) String() string {
+	if s < 0 || int(s) >= len(statusNames) {
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+	return statusNames[s]
+}
+
+// Converged reports whether the status is a convergence criterion.
+func (s Status) Converged() bool {
+	return s == GradientConverged || s == FunctionConverged
+}
+
+// ErrLineSearch is returned with the last point when the line search
+// fails, e.g. because the gradient is inconsistent with the function.
+var ErrLineSearch = errors.New("optimize: line search failed")
+
+// Result is the result of a method: the best point found and how the
+// method got there.
+type Result struct {
+	X      matrix.Vector
+	F      float64
+	Grad   matrix.Vector // nil for Nelder-Mead
+	Iter   int
+	Evals  int
+	Status Status
+}
+
+// counter counts the evaluations of a function.
+type counter struct {
+	f     Function
+	evals int
+}
+
+func (c *counter) ValueGrad(x, grad matrix.Vector) float64 {
+	c.evals++
+	return c.f.ValueGrad(x, grad)
+}
+
+// normInf returns the largest absolute value of v.
+func normInf(v matrix.Vector) float64 {
+	n := 0.0
+	for _, x := range v {
+		n = math.Max(n, math.Abs(x))
+	}
+	return n
+}
+
+func dot(a, b matrix.Vector) float64 {
+	d := 0.0
+	for i, x := range a {
+		d += x * b[i]
+	}
+	return d
+}
+
+// descent holds the state shared by the gradient methods.
+type descent struct {
+	s          Settings
+	f          *counter
+	x, g       matrix.Vector
+	fx         float64
+	xNew, gNew matrix.Vector
+	res        *Result
+}
+
+func newDescent(f Function, x0 matrix.Vector, s *Settings, c2 float64) *descent {
+	d := &descent{s: s.values(), f: &counter{f: f}}
+	if d.s.LineSearch == nil {
+		d.s.LineSearch = &Wolfe{C2: c2}
+	}
+	n := len(x0)
+	d.x = append(matrix.Vector{}, x0...)
+	d.g = make(matrix.Vector, n)
+	d.xNew = make(matrix.Vector, n)
+	d.gNew = make(matrix.Vector, n)
+	d.fx = d.f.ValueGrad(d.x, d.g)
+	d.res = &Result{}
+	return d
+}
+
+// step searches along dir from x with the initial step a0, moves to the
+// new point and reports whether the method must stop.
+func (d *descent) step(dir matrix.Vector, a0 float64) (a float64, stop bool) {
+	g0 := dot(d.g, dir)
+	a, fNew, err := d.s.LineSearch.Search(d.f, d.x, dir, d.fx, g0, a0, d.xNew, d.gNew)
+	if err != nil {
+		d.res.Status = LineSearchFailed
+		return 0, true
+	}
+	fOld := d.fx
+	d.x, d.xNew = d.xNew, d.x
+	d.g, d.gNew = d.gNew, d.g
+	d.fx = fNew
+	d.res.Iter++
+	return a, d.converged(fOld)
+}
+
+// converged sets the status if a stopping criterion is met; fOld is
+// the value before the last iteration.
+func (d *descent) converged(fOld float64) bool {
+	switch {
+	case normInf(d.g) <= d.s.GradTol:
+		d.res.Status = GradientConverged
+	case fOld-d.fx <= d.s.FuncTol*math.Max(1, math.Abs(d.fx)):
+		d.res.Status = FunctionConverged
+	case d.res.Iter >= d.s.MaxIter:
+		d.res.Status = IterationLimit
+	case d.s.MaxEval > 0 && d.f.evals >= d.s.MaxEval:
+		d.res.Status = EvaluationLimit
+	default:
+		return false
+	}
+	return true
+}
+
+func (d *descent) result() (*Result, error) {
+	d.res.X, d.res.F, d.res.Grad, d.res.Evals = d.x, d.fx, d.g, d.f.evals
+	if d.res.Status == LineSearchFailed {
+		return d.res, ErrLineSearch
+	}
+	return d.res, nil
+}
+
+// LBFGS minimizes f from x0 by the limited-memory BFGS method, which
+// builds an approximation of the inverse Hessian from the last Memory
+// steps. It is the method of choice for smooth problems with many
+// variables, e.g. full-batch training. x0 is not modified. The error
+// is ErrLineSearch if the line search fails; the result then holds the
+// last point.
+func LBFGS(f Function, x0 matrix.Vector, s *Settings) (*Result, error) {
+	d := newDescent(f, x0, s, 0.9)
+	if normInf(d.g) <= d.s.GradTol {
+		d.res.Status = GradientConverged
+		return d.result()
+	}
+	n, m := len(x0), d.s.Memory
+	sHist := make([]matrix.Vector, 0, m)
+	yHist := make([]matrix.Vector, 0, m)
+	rho := make([]float64, 0, m)
+	alpha := make([]float64, m)
+	dir := make(matrix.Vector, n)
+	a0 := 1 / math.Max(1, d.g.Norm(2))
+	for {
+		// two-loop recursion: dir = -H*g
+		for i, x := range d.g {
+			dir[i] = -x
+		}
+		k := len(sHist)
+		for i := k - 1; i >= 0; i-- {
+			alpha[i] = rho[i] * dot(sHist[i], dir)
+			axpy(-alpha[i], yHist[i], dir)
+		}
+		if k > 0 {
+			dir.Scale(dot(sHist[k-1], yHist[k-1]) / dot(yHist[k-1], yHist[k-1]))
+		}
+		for i := 0; i < k; i++ {
+			b := rho[i] * dot(yHist[i], dir)
+			axpy(alpha[i]-b, sHist[i], dir)
+		}
+
+		xOld := append(matrix.Vector{}, d.x...)
+		gOld := append(matrix.Vector{}, d.g...)
+		if _, stop := d.step(dir, a0); stop {
+			return d.result()
+		}
+		a0 = 1
+
+		// store the correction if it keeps H positive definite
+		sk, yk := xOld, gOld
+		for i := range sk {
+			sk[i] = d.x[i] - sk[i]
+			yk[i] = d.g[i] - yk[i]
+		}
+		if sy := dot(sk, yk); sy > 1e-10*dot(yk, yk) {
+			if len(sHist) == m {
+				sHist, yHist, rho = sHist[1:], yHist[1:], rho[1:]
+			}
+			sHist = append(sHist, sk)
+			yHist = append(yHist, yk)
+			rho = append(rho, 1/sy)
+		}
+	}
+}
+
+// NonlinearCG minimizes f from x0 by the nonlinear conjugate gradient
+// method with the Polak-Ribiere+ update, restarted along the steepest
+// descent when the direction is not a descent direction. It needs less
+// memory than LBFGS but usually more evaluations.
+func NonlinearCG(f Function, x0 matrix.Vector, s *Settings) (*Result, error) {
+	d := newDescent(f, x0, s, 0.1)
+	if normInf(d.g) <= d.s.GradTol {
+		d.res.Status = GradientConverged
+		return d.result()
+	}
+	n := len(x0)
+	dir := make(matrix.Vector, n)
+	for i, x := range d.g {
+		dir[i] = -x
+	}
+	a0 := 1 / math.Max(1, d.g.Norm(2))
+	for {
+		gOld := append(matrix.Vector{}, d.g...)
+		slope := dot(gOld, dir)
+		a, stop := d.step(dir, a0)
+		if stop {
+			return d.result()
+		}
+		beta := (dot(d.g, d.g) - dot(d.g, gOld)) / dot(gOld, gOld)
+		if beta < 0 {
+			beta = 0
+		}
+		for i := range dir {
+			dir[i] = -d.g[i] + beta*dir[i]
+		}
+		newSlope := dot(d.g, dir)
+		if newSlope >= 0 {
+			for i, x := range d.g {
+				dir[i] = -x
+			}
+			newSlope = -dot(d.g, d.g)
+		}
+		// same first-order change as the last step
+		a0 = a * slope / newSlope
+	}
+}
+
+func axpy(alpha float64, x, y matrix.Vector) {
+	for i, v := range x {
+		y[i] += alpha * v
+	}
+}
+
+// NelderMead minimizes f from x0 by the Nelder-Mead simplex method,
+// which only evaluates f (grad is always nil) and suits small problems
+// whose gradient is unknown or noisy. It stops with FunctionConverged
+// when the values of f over the simplex differ by at most
+// FuncTol*max(1, |f|); GradTol is not used.
+func NelderMead(f Function, x0 matrix.Vector, s *Settings) (*Result, error) {
+	set := s.values()
+	c := &counter{f: f}
+	n := len(x0)
+	matrix.Require(n > 0, "NelderMead: empty starting point\n")
+
+	// initial simplex
+	pts := make([]matrix.Vector, n+1)
+	val := make([]float64, n+1)
+	for k := range pts {
+		pts[k] = append(matrix.Vector{}, x0...)
+		if k > 0 {
+			pts[k][k-1] += set.Step * math.Max(1, math.Abs(x0[k-1]))
+		}
+		val[k] = c.ValueGrad(pts[k], nil)
+	}
+	res := &Result{}
+	centroid := make(matrix.Vector, n)
+	trial := func(t float64, worst matrix.Vector) (matrix.Vector, float64) {
+		p := make(matrix.Vector, n)
+		for i := range p {
+			p[i] = centroid[i] + t*(worst[i]-centroid[i])
+		}
+		return p, c.ValueGrad(p, nil)
+	}
+	for {
+		// order the simplex: best first, worst last
+		for k := 1; k <= n; k++ {
+			for j := k; j > 0 && val[j] < val[j-1]; j-- {
+				val[j], val[j-1] = val[j-1], val[j]
+				pts[j], pts[j-1] = pts[j-1], pts[j]
+			}
+		}
+		switch {
+		case val[n]-val[0] <= set.FuncTol*math.Max(1, math.Abs(val[0])):
+			res.Status = FunctionConverged
+		case res.Iter >= set.MaxIter:
+			res.Status = IterationLimit
+		case set.MaxEval > 0 && c.evals >= set.MaxEval:
+			res.Status = EvaluationLimit
+		default:
+			res.Iter++
+			for i := range centroid {
+				centroid[i] = 0
+				for k := 0; k < n; k++ {
+					centroid[i] += pts[k][i]
+				}
+				centroid[i] /= float64(n)
+			}
+			xr, fr := trial(-1, pts[n])
+			switch {
+			case fr < val[0]:
+				if xe, fe := trial(-2, pts[n]); fe < fr {
+					pts[n], val[n] = xe, fe
+				} else {
+					pts[n], val[n] = xr, fr
+				}
+			case fr < val[n-1]:
+				pts[n], val[n] = xr, fr
+			default:
+				t := 0.5 // inside contraction
+				if fr < val[n] {
+					t = -0.5 // outside contraction
+				}
+				if xc, fc := trial(t, pts[n]); fc < math.Min(fr, val[n]) {
+					pts[n], val[n] = xc, fc
+				} else {
+					// shrink towards the best point
+					for k := 1; k <= n; k++ {
+						for i := range pts[k] {
+							pts[k][i] = pts[0][i] + 0.5*(pts[k][i]-pts[0][i])
+						}
+						val[k] = c.ValueGrad(pts[k], nil)
+					}
+				}
+			}
+			continue
+		}
+		res.X, res.F, res.Evals = pts[0], val[0], c.evals
+		return res, nil
+	}
+}
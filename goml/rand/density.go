@@ -0,0 +1,443 @@
+package rand
+
+import "math"
+
+// Log-densities (LogPDF), log-probabilities of discrete distributions
+// (LogPMF) and cumulative distribution functions (CDF) matching the
+// samplers of Rand. They take the same parameters as the samplers and
+// panic on the same invalid parameters; values outside the support
+// have a log-density of -Inf.
+
+// NormalLogPDF returns the log-density at x of a normal distribution
+// with mean mu and deviation sigma.
+func NormalLogPDF(x, mu, sigma float64) float64 {
+	if sigma <= 0 {
+		panic("rand: NormalLogPDF: deviation must be positive\n")
+	}
+	z := (x - mu) / sigma
+	return -z*z/2 - math.Log(sigma) - 0.5*math.Log(2*math.Pi)
+}
+
+// NormalCDF returns P(X <= x) for a normal distribution with mean mu
+// and deviation sigma.
+func NormalCDF(x, mu, sigma float64) float64 {
+	if sigma <= 0 {
+		panic("rand: NormalCDF: deviation must be positive\n")
+	}
+	return stdNormalCDF((x - mu) / sigma)
+}
+
+func stdNormalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
+
+// normalMass returns Φ(beta) - Φ(alpha) without cancellation in the
+// tails.
+func normalMass(alpha, beta float64) float64 {
+	if alpha > 0 {
+		return stdNormalCDF(-alpha) - stdNormalCDF(-beta)
+	}
+	return stdNormalCDF(beta) - stdNormalCDF(alpha)
+}
+
+// ExponentialLogPDF returns the log-density at x of an exponential
+// distribution with the given rate.
+func ExponentialLogPDF(x, rate float64) float64 {
+	if rate <= 0 {
+		panic("rand: ExponentialLogPDF: rate must be positive\n")
+	}
+	if x < 0 {
+		return math.Inf(-1)
+	}
+	return math.Log(rate) - rate*x
+}
+
+// ExponentialCDF returns P(X <= x) for an exponential distribution
+// with the given rate.
+func ExponentialCDF(x, rate float64) float64 {
+	if rate <= 0 {
+		panic("rand: ExponentialCDF: rate must be positive\n")
+	}
+	if x <= 0 {
+		return 0
+	}
+	return -math.Expm1(-rate * x)
+}
+
+// GammaLogPDF returns the log-density at x of a gamma distribution
+// with the given shape and scale.
+func GammaLogPDF(x, shape, scale float64) float64 {
+	if shape <= 0 || scale <= 0 {
+		panic("rand: GammaLogPDF: shape and scale must be positive\n")
+	}
+	switch {
+	case x < 0:
+		return math.Inf(-1)
+	case x == 0 && shape < 1:
+		return math.Inf(1)
+	case x == 0 && shape > 1:
+		return math.Inf(-1)
+	case x == 0:
+		return -math.Log(scale)
+	}
+	return (shape-1)*math.Log(x) - x/scale - lgamma(shape) - shape*math.Log(scale)
+}
+
+// GammaCDF returns P(X <= x) for a gamma distribution with the given
+// shape and scale.
+func GammaCDF(x, shape, scale float64) float64 {
+	if shape <= 0 || scale <= 0 {
+		panic("rand: GammaCDF: shape and scale must be positive\n")
+	}
+	return regGammaP(shape, x/scale)
+}
+
+// BetaLogPDF returns the log-density at x of a beta distribution with
+// shapes a and b.
+func BetaLogPDF(x, a, b float64) float64 {
+	if a <= 0 || b <= 0 {
+		panic("rand: BetaLogPDF: shapes must be positive\n")
+	}
+	if x < 0 || x > 1 {
+		return math.Inf(-1)
+	}
+	return xlogy(a-1, x) + xlogy(b-1, 1-x) - lbeta(a, b)
+}
+
+// BetaCDF returns P(X <= x) for a beta distribution with shapes a and
+// b.
+func BetaCDF(x, a, b float64) float64 {
+	if a <= 0 || b <= 0 {
+		panic("rand: BetaCDF: shapes must be positive\n")
+	}
+	return regBeta(x, a, b)
+}
+
+// DirichletLogPDF returns the log-density at the point x of the
+// simplex of a Dirichlet distribution with the given concentrations.
+// A multivariate distribution has no CDF of practical use.
+func DirichletLogPDF(x, alpha []float64) float64 {
+	if len(x) != len(alpha) || len(alpha) == 0 {
+		panic("rand: DirichletLogPDF: x and alpha sizes differ\n")
+	}
+	sumX, sumA, lp := 0.0, 0.0, 0.0
+	for i, a := range alpha {
+		if a <= 0 {
+			panic("rand: DirichletLogPDF: concentrations must be positive\n")
+		}
+		if x[i] < 0 {
+			return math.Inf(-1)
+		}
+		sumX += x[i]
+		sumA += a
+		lp += xlogy(a-1, x[i]) - lgamma(a)
+	}
+	if math.Abs(sumX-1) > 1e-9 {
+		return math.Inf(-1)
+	}
+	return lp + lgamma(sumA)
+}
+
+// BinomialLogPMF returns log P(X = k) for the number of successes in n
+// trials with success probability p.
+func BinomialLogPMF(k, n int, p float64) float64 {
+	if n < 0 || !(p >= 0 && p <= 1) {
+		panic("rand: BinomialLogPMF: invalid parameter\n")
+	}
+	if k < 0 || k > n {
+		return math.Inf(-1)
+	}
+	return lchoose(n, k) + xlogy(float64(k), p) + xlog1py(float64(n-k), -p)
+}
+
+// BinomialCDF returns P(X <= k) for the number of successes in n trials
+// with success probability p.
+func BinomialCDF(k, n int, p float64) float64 {
+	if n < 0 || !(p >= 0 && p <= 1) {
+		panic("rand: BinomialCDF: invalid parameter\n")
+	}
+	switch {
+	case k < 0:
+		return 0
+	case k >= n:
+		return 1
+	}
+	return regBeta(1-p, float64(n-k), float64(k+1))
+}
+
+// MultinomialLogPMF returns the log-probability of the category counts
+// for draws with the given category probabilities.
+func MultinomialLogPMF(counts []int, probabilities []float64) float64 {
+	if len(counts) != len(probabilities) {
+		panic("rand: MultinomialLogPMF: counts and probabilities sizes differ\n")
+	}
+	n, lp := 0, 0.0
+	for i, k := range counts {
+		if k < 0 {
+			return math.Inf(-1)
+		}
+		n += k
+		lp += xlogy(float64(k), probabilities[i]) - lgamma(float64(k)+1)
+	}
+	return lp + lgamma(float64(n)+1)
+}
+
+// StudentTLogPDF returns the log-density at t of a Student's t
+// distribution with nu degrees of freedom.
+func StudentTLogPDF(t, nu float64) float64 {
+	if nu <= 0 {
+		panic("rand: StudentTLogPDF: degrees of freedom must be positive\n")
+	}
+	return lgamma((nu+1)/2) - lgamma(nu/2) - 0.5*math.Log(nu*math.Pi) -
+		(nu+1)/2*math.Log1p(t*t/nu)
+}
+
+// StudentTCDF returns P(T <= t) for a Student's t distribution with nu
+// degrees of freedom.
+func StudentTCDF(t, nu float64) float64 {
+	if nu <= 0 {
+		panic("rand: StudentTCDF: degrees of freedom must be positive\n")
+	}
+	tail := 0.5 * regBeta(nu/(nu+t*t), nu/2, 0.5)
+	if t > 0 {
+		return 1 - tail
+	}
+	return tail
+}
+
+// LaplaceLogPDF returns the log-density at x of a Laplace distribution
+// with location mu and scale b.
+func LaplaceLogPDF(x, mu, b float64) float64 {
+	if b <= 0 {
+		panic("rand: LaplaceLogPDF: scale must be positive\n")
+	}
+	return -math.Log(2*b) - math.Abs(x-mu)/b
+}
+
+// LaplaceCDF returns P(X <= x) for a Laplace distribution with
+// location mu and scale b.
+func LaplaceCDF(x, mu, b float64) float64 {
+	if b <= 0 {
+		panic("rand: LaplaceCDF: scale must be positive\n")
+	}
+	if x < mu {
+		return 0.5 * math.Exp((x-mu)/b)
+	}
+	return 1 - 0.5*math.Exp(-(x-mu)/b)
+}
+
+// TruncatedNormalLogPDF returns the log-density at x of a normal
+// distribution with mean mu and deviation sigma restricted to [a, b].
+func TruncatedNormalLogPDF(x, mu, sigma, a, b float64) float64 {
+	if sigma <= 0 || !(a < b) {
+		panic("rand: TruncatedNormalLogPDF: invalid parameter\n")
+	}
+	if x < a || x > b {
+		return math.Inf(-1)
+	}
+	return NormalLogPDF(x, mu, sigma) - math.Log(normalMass((a-mu)/sigma, (b-mu)/sigma))
+}
+
+// TruncatedNormalCDF returns P(X <= x) for a normal distribution with
+// mean mu and deviation sigma restricted to [a, b].
+func TruncatedNormalCDF(x, mu, sigma, a, b float64) float64 {
+	if sigma <= 0 || !(a < b) {
+		panic("rand: TruncatedNormalCDF: invalid parameter\n")
+	}
+	switch {
+	case x <= a:
+		return 0
+	case x >= b:
+		return 1
+	}
+	alpha := (a - mu) / sigma
+	return normalMass(alpha, (x-mu)/sigma) / normalMass(alpha, (b-mu)/sigma)
+}
+
+// MVNormalLogPDF returns the log-density at x of a multivariate normal
+// distribution with the given mean and covariance L·Lᵀ, where l is the
+// Cholesky factor returned by Cholesky.
+func MVNormalLogPDF(x, mean []float64, l [][]float64) float64 {
+	if len(x) != len(mean) || len(l) != len(mean) {
+		panic("rand: MVNormalLogPDF: x, mean and covariance sizes differ\n")
+	}
+	// solve L·y = x - mean
+	y := make([]float64, len(x))
+	lp := -0.5 * float64(len(x)) * math.Log(2*math.Pi)
+	for i := range y {
+		s := x[i] - mean[i]
+		for j := 0; j < i; j++ {
+			s -= l[i][j] * y[j]
+		}
+		y[i] = s / l[i][i]
+		lp -= y[i]*y[i]/2 + math.Log(l[i][i])
+	}
+	return lp
+}
+
+// PoissonLogPMF returns log P(X = k) for a Poisson distribution with
+// mean mu.
+func PoissonLogPMF(k int, mu float64) float64 {
+	if mu <= 0 {
+		panic("rand: PoissonLogPMF: mean must be positive\n")
+	}
+	if k < 0 {
+		return math.Inf(-1)
+	}
+	return float64(k)*math.Log(mu) - mu - lgamma(float64(k)+1)
+}
+
+// PoissonCDF returns P(X <= k) for a Poisson distribution with mean
+// mu.
+func PoissonCDF(k int, mu float64) float64 {
+	if mu <= 0 {
+		panic("rand: PoissonCDF: mean must be positive\n")
+	}
+	if k < 0 {
+		return 0
+	}
+	return 1 - regGammaP(float64(k)+1, mu)
+}
+
+// Special functions
+
+func lgamma(x float64) float64 {
+	v, _ := math.Lgamma(x)
+	return v
+}
+
+func lbeta(a, b float64) float64 {
+	return lgamma(a) + lgamma(b) - lgamma(a+b)
+}
+
+func lchoose(n, k int) float64 {
+	return lgamma(float64(n)+1) - lgamma(float64(k)+1) - lgamma(float64(n-k)+1)
+}
+
+// xlogy returns x·log(y), which is 0 if x is 0.
+func xlogy(x, y float64) float64 {
+	if x == 0 {
+		return 0
+	}
+	return x * math.Log(y)
+}
+
+// xlog1py returns x·log(1+y), which is 0 if x is 0.
+func xlog1py(x, y float64) float64 {
+	if x == 0 {
+		return 0
+	}
+	return x * math.Log1p(y)
+}
+
+const (
+	specialEps   = 1e-15
+	specialTiny  = 1e-300
+	specialIters = 1000
+)
+
+// regGammaP returns the regularized lower incomplete gamma function
+// P(a, x), by its series for x < a+1 and by the continued fraction of
+// Q(a, x) = 1 - P(a, x) otherwise (Numerical Recipes, 6.2).
+func regGammaP(a, x float64) float64 {
+	switch {
+	case x <= 0:
+		return 0
+	case math.IsInf(x, 1):
+		return 1
+	}
+	front := math.Exp(a*math.Log(x) - x - lgamma(a))
+	if x < a+1 {
+		sum, del := 1/a, 1/a
+		for n := 1; n < specialIters; n++ {
+			del *= x / (a + float64(n))
+			sum += del
+			if math.Abs(del) < math.Abs(sum)*specialEps {
+				break
+			}
+		}
+		return sum * front
+	}
+	// modified Lentz's method
+	b := x + 1 - a
+	c := 1 / specialTiny
+	d := 1 / b
+	h := d
+	for i := 1; i < specialIters; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < specialTiny {
+			d = specialTiny
+		}
+		c = b + an/c
+		if math.Abs(c) < specialTiny {
+			c = specialTiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < specialEps {
+			break
+		}
+	}
+	return 1 - front*h
+}
+
+// regBeta returns the regularized incomplete beta function I_x(a, b)
+// by its continued fraction (Numerical Recipes, 6.4).
+func regBeta(x, a, b float64) float64 {
+	switch {
+	case x <= 0:
+		return 0
+	case x >= 1:
+		return 1
+	}
+	front := math.Exp(a*math.Log(x) + b*math.Log1p(-x) - lbeta(a, b))
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(x, a, b) / a
+	}
+	return 1 - front*betaFraction(1-x, b, a)/b
+}
+
+func betaFraction(x, a, b float64) float64 {
+	qab, qap, qam := a+b, a+1, a-1
+	c := 1.0
+	d := 1 - qab*x/qap
+	if math.Abs(d) < specialTiny {
+		d = specialTiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m < specialIters; m++ {
+		fm := float64(m)
+		m2 := 2 * fm
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < specialTiny {
+			d = specialTiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < specialTiny {
+			c = specialTiny
+		}
+		d = 1 / d
+		h *= d * c
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < specialTiny {
+			d = specialTiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < specialTiny {
+			c = specialTiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < specialEps {
+			break
+		}
+	}
+	return h
+}
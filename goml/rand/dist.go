@@ -0,0 +1,280 @@
+package rand
+
+import (
+	"errors"
+	"math"
+)
+
+// The log-densities and distribution functions of the distributions
+// below are in density.go.
+
+// Exponential returns a random value from an exponential distribution
+// with the given rate (the inverse of the mean).
+func (r *Rand) Exponential(rate float64) float64 {
+	if rate <= 0 {
+		panic("rand: Exponential: rate must be positive\n")
+	}
+	return -math.Log(1-r.Uniform()) / rate
+}
+
+// Gamma returns a random value from a gamma distribution with the given
+// shape k and scale θ, whose mean is kθ, using the method of Marsaglia
+// and Tsang.
+func (r *Rand) Gamma(shape, scale float64) float64 {
+	if shape <= 0 || scale <= 0 {
+		panic("rand: Gamma: shape and scale must be positive\n")
+	}
+	if shape < 1 {
+		// X·U^(1/k) with X ~ Gamma(k+1)
+		u := r.Uniform()
+		return r.Gamma(shape+1, scale) * math.Pow(u, 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		var x, v float64
+		for v <= 0 {
+			x = r.Normal()
+			v = 1 + c*x
+		}
+		v = v * v * v
+		u := r.Uniform()
+		if u < 1-0.0331*x*x*x*x ||
+			math.Log(u) < 0.5*x*x+d*(1-v+math.Log(v)) {
+			return d * v * scale
+		}
+	}
+}
+
+// Beta returns a random value from a beta distribution with shapes a
+// and b, whose mean is a/(a+b).
+func (r *Rand) Beta(a, b float64) float64 {
+	if a <= 0 || b <= 0 {
+		panic("rand: Beta: shapes must be positive\n")
+	}
+	for {
+		x := r.Gamma(a, 1)
+		y := r.Gamma(b, 1)
+		if x+y > 0 {
+			return x / (x + y)
+		}
+	}
+}
+
+// Dirichlet returns a random point of the probability simplex from a
+// Dirichlet distribution with the given concentrations.
+func (r *Rand) Dirichlet(alpha []float64) []float64 {
+	if len(alpha) == 0 {
+		panic("rand: Dirichlet: no concentrations\n")
+	}
+	x := make([]float64, len(alpha))
+	for {
+		sum := 0.0
+		for i, a := range alpha {
+			if a <= 0 {
+				panic("rand: Dirichlet: concentrations must be positive\n")
+			}
+			x[i] = r.Gamma(a, 1)
+			sum += x[i]
+		}
+		if sum > 0 {
+			for i := range x {
+				x[i] /= sum
+			}
+			return x
+		}
+	}
+}
+
+// Binomial returns the number of successes in n independent trials
+// with success probability p. Large n are reduced through the order
+// statistics of the uniform distribution (Knuth, TAOCP 3.4.1), so it
+// takes O(log n) time.
+func (r *Rand) Binomial(n int, p float64) int {
+	if n < 0 || !(p >= 0 && p <= 1) {
+		panic("rand: Binomial: invalid parameter\n")
+	}
+	k := 0
+	for n > 64 {
+		// the a-th smallest of n uniforms
+		a := 1 + n/2
+		b := n + 1 - a
+		x := r.Beta(float64(a), float64(b))
+		if x >= p {
+			n, p = a-1, p/x
+		} else {
+			k += a
+			n, p = b-1, (p-x)/(1-x)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if r.Uniform() < p {
+			k++
+		}
+	}
+	return k
+}
+
+// Multinomial returns the number of times each category is drawn in n
+// independent draws with the given category probabilities.
+func (r *Rand) Multinomial(n int, probabilities []float64) []int {
+	if n < 0 {
+		panic("rand: Multinomial: invalid parameter\n")
+	}
+	counts := make([]int, len(probabilities))
+	rest := 1.0
+	for i, p := range probabilities {
+		if p < 0 {
+			panic("rand: Multinomial: negative probability\n")
+		}
+		if n == 0 {
+			break
+		}
+		if i == len(probabilities)-1 {
+			counts[i] = n
+			break
+		}
+		q := 1.0
+		if p < rest {
+			q = p / rest
+		}
+		counts[i] = r.Binomial(n, q)
+		n -= counts[i]
+		rest -= p
+	}
+	return counts
+}
+
+// StudentT returns a random value from a Student's t distribution with
+// nu degrees of freedom.
+func (r *Rand) StudentT(nu float64) float64 {
+	if nu <= 0 {
+		panic("rand: StudentT: degrees of freedom must be positive\n")
+	}
+	return r.Normal() / math.Sqrt(r.Gamma(nu/2, 2)/nu)
+}
+
+// Laplace returns a random value from a Laplace distribution with
+// location mu and scale b.
+func (r *Rand) Laplace(mu, b float64) float64 {
+	if b <= 0 {
+		panic("rand: Laplace: scale must be positive\n")
+	}
+	for {
+		u := r.Uniform() - 0.5
+		if u != -0.5 {
+			return mu - b*math.Copysign(math.Log1p(-2*math.Abs(u)), u)
+		}
+	}
+}
+
+// TruncatedNormal returns a random value from a normal distribution
+// with mean mu and deviation sigma restricted to [a, b]; a may be
+// -Inf and b +Inf. Values far in the tails are drawn by exponential
+// rejection (Robert, 1995).
+func (r *Rand) TruncatedNormal(mu, sigma, a, b float64) float64 {
+	if sigma <= 0 || !(a < b) {
+		panic("rand: TruncatedNormal: invalid parameter\n")
+	}
+	alpha, beta := (a-mu)/sigma, (b-mu)/sigma
+	var z float64
+	if beta <= 0 {
+		z = -r.truncatedStdNormal(-beta, -alpha)
+	} else {
+		z = r.truncatedStdNormal(alpha, beta)
+	}
+	return math.Max(a, math.Min(b, mu+sigma*z))
+}
+
+// truncatedStdNormal draws from a standard normal distribution
+// restricted to [alpha, beta], with beta > 0.
+func (r *Rand) truncatedStdNormal(alpha, beta float64) float64 {
+	w := beta - alpha
+	switch {
+	case alpha < 0.5 && w > 2.5:
+		// the interval holds at least 30% of the mass
+		for {
+			if z := r.Normal(); z >= alpha && z <= beta {
+				return z
+			}
+		}
+	case alpha <= 0:
+		// uniform proposal, the density peaks at 0
+		for {
+			z := alpha + w*r.Uniform()
+			if r.Uniform() <= math.Exp(-z*z/2) {
+				return z
+			}
+		}
+	case w < 2.5 && alpha*w < 1:
+		// uniform proposal, the density peaks at alpha
+		for {
+			z := alpha + w*r.Uniform()
+			if r.Uniform() <= math.Exp((alpha-z)*(alpha+z)/2) {
+				return z
+			}
+		}
+	}
+	lambda := (alpha + math.Sqrt(alpha*alpha+4)) / 2
+	for {
+		z := alpha + r.Exponential(lambda)
+		if z <= beta && r.Uniform() <= math.Exp(-(z-lambda)*(z-lambda)/2) {
+			return z
+		}
+	}
+}
+
+// ErrNotPositiveDefinite is returned by Cholesky when the covariance
+// is not symmetric positive definite.
+var ErrNotPositiveDefinite = errors.New("rand: covariance is not positive definite")
+
+// Cholesky returns the lower triangular L with L·Lᵀ = cov, the form of
+// the covariance that MVNormal and MVNormalLogPDF take. Only the lower
+// triangle of cov is read.
+func Cholesky(cov [][]float64) ([][]float64, error) {
+	n := len(cov)
+	l := make([][]float64, n)
+	for i := range l {
+		if len(cov[i]) != n {
+			return nil, errors.New("rand: Cholesky: covariance is not square")
+		}
+		l[i] = make([]float64, n)
+		for j := 0; j <= i; j++ {
+			s := cov[i][j]
+			for k := 0; k < j; k++ {
+				s -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if !(s > 0) {
+					return nil, ErrNotPositiveDefinite
+				}
+				l[i][i] = math.Sqrt(s)
+			} else {
+				l[i][j] = s / l[j][j]
+			}
+		}
+	}
+	return l, nil
+}
+
+// MVNormal returns a random point from a multivariate normal
+// distribution with the given mean and covariance L·Lᵀ, where l is the
+// Cholesky factor returned by Cholesky.
+func (r *Rand) MVNormal(mean []float64, l [][]float64) []float64 {
+	if len(l) != len(mean) {
+		panic("rand: MVNormal: mean and covariance sizes differ\n")
+	}
+	z := make([]float64, len(mean))
+	for i := range z {
+		z[i] = r.Normal()
+	}
+	x := make([]float64, len(mean))
+	for i := range x {
+		s := mean[i]
+		for j := 0; j <= i; j++ {
+			s += l[i][j] * z[j]
+		}
+		x[i] = s
+	}
+	return x
+}
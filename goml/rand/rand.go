@@ -17,8 +17,19 @@ import (
 // several useful distributions. The only methods you will use in this class are
 // setSeed, next, uniform, and normal. I just left the other methods here
 // for completeness.
+//
+// NewStream returns a Rand that draws from a xoshiro256** generator
+// instead, which can be split into independent streams (see
+// stream.go).
 type Rand struct {
 	a, b uint64
+
+	// state of the xoshiro256** generator, used if xoshiro is set
+	s       [4]uint64
+	xoshiro bool
+
+	// key identifies the stream for Derive
+	key uint64
 }
 
 func NewRand(seed uint64) *Rand {
@@ -27,13 +38,22 @@ func NewRand(seed uint64) *Rand {
 	return &r
 }
 
+// SetSeed restarts the generator from seed.
 func (r *Rand) SetSeed(seed uint64) {
+	r.key = seed
+	if r.xoshiro {
+		r.seedXoshiro(seed)
+		return
+	}
 	r.b = 0xCA535ACA9535ACB2 + seed
 	r.a = 0x6CCF6660A66C35E7 + (seed << 24)
 }
 
 // Returns an unsigned pseudo-random 64-bit value
 func (r *Rand) next() uint64 {
+	if r.xoshiro {
+		return r.nextXoshiro()
+	}
 	r.a = 0x141F2B69*(r.a&0x3ffffffff) + (r.a >> 32)
 	r.b = 0xC2785A6B*(r.b&0x3ffffffff) + (r.b >> 32)
 	return r.a ^ r.b
@@ -80,6 +100,7 @@ func (r *Rand) Normal() float64 {
 
 // Returns a random value from a categorical distribution
 // with the specified vector of category probabilities.
+// Use NewAlias to draw many values with the same probabilities.
 func (r *Rand) Categorical(probabilities []float64) int {
 	d := r.Uniform()
 	for i := 0; i < len(probabilities); i++ {
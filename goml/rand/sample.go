@@ -0,0 +1,259 @@
+package rand
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Shuffle permutes n items at random by the Fisher–Yates algorithm,
+// calling swap(i, j) to exchange items i and j (possibly with i == j).
+// The draws are the same as for Perm, so that shuffling the identity
+// with the same seed gives Perm(n).
+func (r *Rand) Shuffle(n int, swap func(i, j int)) {
+	for i := n; i > 1; i-- {
+		swap(i-1, int(r.Next(uint64(i))))
+	}
+}
+
+// Perm returns a random permutation of 0, ..., n-1.
+func (r *Rand) Perm(n int) []int {
+	p := make([]int, n)
+	for i := range p {
+		p[i] = i
+	}
+	r.Shuffle(n, func(i, j int) {
+		p[i], p[j] = p[j], p[i]
+	})
+	return p
+}
+
+// Sample returns k distinct indices drawn uniformly from 0, ..., n-1,
+// in random order. It takes O(k) time and memory.
+func (r *Rand) Sample(n, k int) []int {
+	if k < 0 || k > n {
+		panic("rand: Sample: k must be in [0, n]\n")
+	}
+	// partial Fisher–Yates on a sparse permutation
+	moved := make(map[int]int, k)
+	at := func(i int) int {
+		if v, ok := moved[i]; ok {
+			return v
+		}
+		return i
+	}
+	s := make([]int, k)
+	for i := 0; i < k; i++ {
+		j := i + int(r.Next(uint64(n-i)))
+		s[i] = at(j)
+		moved[j] = at(i)
+	}
+	return s
+}
+
+// Bootstrap returns n indices drawn uniformly with replacement from 0,
+// ..., n-1.
+func (r *Rand) Bootstrap(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = int(r.Next(uint64(n)))
+	}
+	return s
+}
+
+// StratifiedBootstrap returns a bootstrap sample of the indices of
+// labels that keeps the number of rows of each label: index i of the
+// result is drawn with replacement among the rows with the label of
+// row i. labels is typically a column of nominal codes.
+func (r *Rand) StratifiedBootstrap(labels []float64) []int {
+	strata := make(map[float64][]int)
+	for i, l := range labels {
+		strata[l] = append(strata[l], i)
+	}
+	s := make([]int, len(labels))
+	for i, l := range labels {
+		rows := strata[l]
+		s[i] = rows[r.Next(uint64(len(rows)))]
+	}
+	return s
+}
+
+// WeightedSample returns k distinct indices of weights drawn without
+// replacement, each draw picking the remaining indices with
+// probability proportional to their weights (Efraimidis and Spirakis,
+// 2006). Indices of zero weight are only drawn if fewer than k have a
+// positive weight.
+func (r *Rand) WeightedSample(weights []float64, k int) []int {
+	if k < 0 || k > len(weights) {
+		panic("rand: WeightedSample: k must be in [0, len(weights)]\n")
+	}
+	// keep the k largest keys log(u)/w in a min-heap
+	h := make(keyHeap, 0, k)
+	for i, w := range weights {
+		if !(w >= 0) || math.IsInf(w, 1) {
+			panic("rand: WeightedSample: invalid weight\n")
+		}
+		key := math.Inf(-1)
+		if w > 0 {
+			key = math.Log(1-r.Uniform()) / w
+		}
+		if len(h) < k {
+			heap.Push(&h, weightKey{i, key})
+		} else if k > 0 && key > h[0].key {
+			h[0] = weightKey{i, key}
+			heap.Fix(&h, 0)
+		}
+	}
+	s := make([]int, k)
+	for i := k - 1; i >= 0; i-- {
+		s[i] = heap.Pop(&h).(weightKey).index
+	}
+	return s
+}
+
+type weightKey struct {
+	index int
+	key   float64
+}
+
+type keyHeap []weightKey
+
+func (h keyHeap) Len() int            { return len(h) }
+func (h keyHeap) Less(i, j int) bool  { return h[i].key < h[j].key }
+func (h keyHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *keyHeap) Push(x interface{}) { *h = append(*h, x.(weightKey)) }
+func (h *keyHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// WeightedChoice returns k indices of weights drawn with replacement
+// with probabilities proportional to the weights. It builds an Alias
+// table, so it takes O(len(weights) + k) time.
+func (r *Rand) WeightedChoice(weights []float64, k int) []int {
+	a := NewAlias(weights)
+	s := make([]int, k)
+	for i := range s {
+		s[i] = a.Draw(r)
+	}
+	return s
+}
+
+// Alias draws from a categorical distribution in constant time, after
+// a linear setup, by the alias method of Walker as refined by Vose.
+// Use it instead of Rand.Categorical to draw many times from the same
+// probabilities.
+type Alias struct {
+	prob  []float64
+	alias []int
+}
+
+// NewAlias returns an Alias table for the categorical distribution
+// with probabilities proportional to weights, which need not sum to 1.
+func NewAlias(weights []float64) *Alias {
+	n := len(weights)
+	sum := 0.0
+	for _, w := range weights {
+		if !(w >= 0) || math.IsInf(w, 1) {
+			panic("rand: NewAlias: invalid weight\n")
+		}
+		sum += w
+	}
+	if !(sum > 0) {
+		panic("rand: NewAlias: weights sum to zero\n")
+	}
+	a := &Alias{prob: make([]float64, n), alias: make([]int, n)}
+	scaled := make([]float64, n)
+	var small, large []int
+	for i, w := range weights {
+		scaled[i] = w * float64(n) / sum
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		s, l := small[len(small)-1], large[len(large)-1]
+		small = small[:len(small)-1]
+		a.prob[s], a.alias[s] = scaled[s], l
+		scaled[l] -= 1 - scaled[s]
+		if scaled[l] < 1 {
+			large = large[:len(large)-1]
+			small = append(small, l)
+		}
+	}
+	// what is left is 1 up to rounding
+	for _, i := range append(small, large...) {
+		a.prob[i], a.alias[i] = 1, i
+	}
+	return a
+}
+
+// Len returns the number of categories of a.
+func (a *Alias) Len() int {
+	return len(a.prob)
+}
+
+// Draw returns a random category of a drawn with r.
+func (a *Alias) Draw(r *Rand) int {
+	i := int(r.Next(uint64(len(a.prob))))
+	if r.Uniform() < a.prob[i] {
+		return i
+	}
+	return a.alias[i]
+}
+
+// Reservoir keeps a uniform random sample of k items of a stream of
+// unknown length (Vitter's algorithm R). It only deals with positions:
+// the caller stores the items in a slice of length k,
+//
+//	res := rand.NewReservoir(r, k)
+//	for item := range stream {
+//		if slot := res.Offer(); slot >= 0 {
+//			sample[slot] = item
+//		}
+//	}
+//	sample = sample[:res.Len()]
+type Reservoir struct {
+	r    *Rand
+	k    int
+	seen int
+}
+
+// NewReservoir returns a Reservoir of k items drawn with r.
+func NewReservoir(r *Rand, k int) *Reservoir {
+	if k < 0 {
+		panic("rand: NewReservoir: negative size\n")
+	}
+	return &Reservoir{r: r, k: k}
+}
+
+// Offer offers the next item of the stream and returns the slot in
+// [0, k) where it must be stored, replacing the item there, or -1 if it
+// is not part of the sample.
+func (s *Reservoir) Offer() int {
+	s.seen++
+	if s.seen <= s.k {
+		return s.seen - 1
+	}
+	if j := int(s.r.Next(uint64(s.seen))); j < s.k {
+		return j
+	}
+	return -1
+}
+
+// Seen returns the number of items offered so far.
+func (s *Reservoir) Seen() int {
+	return s.seen
+}
+
+// Len returns the number of items in the sample, k once k items were
+// offered.
+func (s *Reservoir) Len() int {
+	if s.seen < s.k {
+		return s.seen
+	}
+	return s.k
+}
@@ -0,0 +1,177 @@
+package rand
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Streams
+//
+// NewRand keeps its historical generator so that seeded results do not
+// change. NewStream returns a Rand backed by xoshiro256** (Blackman and
+// Vigna), which has a period of 2^256-1 and can jump ahead, so that a
+// single root seed gives each worker, fold or layer its own stream:
+//
+//	root := rand.NewStream(42)
+//	for fold := 0; fold < n; fold++ {
+//		r := root.Derive(uint64(fold)) // the same for every run
+//		...
+//	}
+//
+// Every method of Rand works on both kinds of generator.
+
+// NewStream returns a xoshiro256** generator seeded with seed.
+func NewStream(seed uint64) *Rand {
+	r := Rand{xoshiro: true}
+	r.SetSeed(seed)
+	return &r
+}
+
+// IsStream reports whether r is a xoshiro256** generator, i.e. it was
+// made by NewStream, Split or Derive.
+func (r *Rand) IsStream() bool {
+	return r.xoshiro
+}
+
+// splitmix64 advances *x and returns the next output of the SplitMix64
+// generator, used to expand seeds and keys into states.
+func splitmix64(x *uint64) uint64 {
+	*x += 0x9E3779B97F4A7C15
+	z := *x
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+func (r *Rand) seedXoshiro(seed uint64) {
+	for i := range r.s {
+		r.s[i] = splitmix64(&seed)
+	}
+}
+
+func rotl(x uint64, k uint) uint64 {
+	return x<<k | x>>(64-k)
+}
+
+func (r *Rand) nextXoshiro() uint64 {
+	s := &r.s
+	result := rotl(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = rotl(s[3], 45)
+	return result
+}
+
+var (
+	jump     = [4]uint64{0x180EC6D33CFD0ABA, 0xD5A61266F0C9392C, 0xA9582618E03FC9AA, 0x39ABDC4529B1661C}
+	longJump = [4]uint64{0x76E15D3EFEFDCBBF, 0xC5004E441C522FB3, 0x77710069854EE241, 0x39109BB02ACBE635}
+)
+
+func (r *Rand) jumpBy(poly *[4]uint64) {
+	if !r.xoshiro {
+		panic("rand: Jump: not a stream, use NewStream\n")
+	}
+	var s [4]uint64
+	for _, p := range poly {
+		for b := uint(0); b < 64; b++ {
+			if p&(1<<b) != 0 {
+				s[0] ^= r.s[0]
+				s[1] ^= r.s[1]
+				s[2] ^= r.s[2]
+				s[3] ^= r.s[3]
+			}
+			r.nextXoshiro()
+		}
+	}
+	r.s = s
+}
+
+// Jump advances r by 2^128 draws, as if next had been called 2^128
+// times. It panics if r is not a stream.
+func (r *Rand) Jump() {
+	r.jumpBy(&jump)
+}
+
+// LongJump advances r by 2^192 draws. It can give 2^64 starting points,
+// e.g. one per machine, each of which can be split with Jump.
+func (r *Rand) LongJump() {
+	r.jumpBy(&longJump)
+}
+
+// Split returns a new stream that continues the sequence of r and jumps
+// r ahead by 2^128 draws, so that neither overlaps the other for 2^128
+// draws. The result depends on how much r was used before; use Derive
+// for streams that only depend on the seed. If r is not a stream, the
+// new stream is seeded from r.
+func (r *Rand) Split() *Rand {
+	if !r.xoshiro {
+		return NewStream(r.next())
+	}
+	c := *r
+	c.key = deriveKey(r.key, r.next())
+	r.Jump()
+	return &c
+}
+
+// Derive returns a stream that only depends on the seed of r (and the
+// keys it was itself derived with) and the given keys, not on how much
+// r was used. Different key paths give statistically independent
+// streams, e.g. root.Derive(rep, fold) for repetition rep and fold
+// fold of a cross-validation.
+func (r *Rand) Derive(keys ...uint64) *Rand {
+	key := r.key
+	for _, k := range keys {
+		key = deriveKey(key, k)
+	}
+	return NewStream(key)
+}
+
+// deriveKey mixes k into key, so that the paths (a, b) and (b, a) give
+// different keys.
+func deriveKey(key, k uint64) uint64 {
+	x := key
+	h := splitmix64(&x)
+	x = h ^ k
+	return splitmix64(&x)
+}
+
+const (
+	stateLegacy byte = 1
+	stateStream byte = 2
+	stateLen         = 1 + 7*8
+)
+
+// MarshalBinary saves the state of r, so that UnmarshalBinary can
+// restart the sequence where it was, e.g. when resuming training from
+// a checkpoint.
+func (r *Rand) MarshalBinary() ([]byte, error) {
+	b := make([]byte, stateLen)
+	b[0] = stateLegacy
+	if r.xoshiro {
+		b[0] = stateStream
+	}
+	for i, v := range [7]uint64{r.a, r.b, r.s[0], r.s[1], r.s[2], r.s[3], r.key} {
+		binary.LittleEndian.PutUint64(b[1+8*i:], v)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary restores a state saved by MarshalBinary.
+func (r *Rand) UnmarshalBinary(b []byte) error {
+	if len(b) != stateLen || (b[0] != stateLegacy && b[0] != stateStream) {
+		return errors.New("rand: UnmarshalBinary: invalid state")
+	}
+	var v [7]uint64
+	for i := range v {
+		v[i] = binary.LittleEndian.Uint64(b[1+8*i:])
+	}
+	r.xoshiro = b[0] == stateStream
+	r.a, r.b = v[0], v[1]
+	copy(r.s[:], v[2:6])
+	r.key = v[6]
+	return nil
+}